@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"code/crawler"
+)
+
+// loadReport загружает предыдущий отчёт из файла или по http(s) URL.
+// URL загружается с настройками клиента обхода (прокси, заголовки,
+// авторизация, --timeout).
+func loadReport(ctx context.Context, source string, opts crawler.Options) (*crawler.Report, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		prev, err := crawler.FetchReport(ctx, opts, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch prior report: %w", err)
+		}
+		return prev, nil
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open prior report: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return crawler.LoadReport(file)
+}
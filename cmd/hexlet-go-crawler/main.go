@@ -2,31 +2,43 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"net/http"
+	"io"
+	"net"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"code/crawler"
 )
 
-func main() {
-	var (
-		depth       = flag.Int("depth", 10, "crawl depth")
-		retries     = flag.Int("retries", 1, "number of retries for failed requests")
-		delayStr    = flag.String("delay", "0s", "delay between requests")
-		timeout     = flag.Duration("timeout", 15*time.Second, "per-request timeout")
-		rps         = flag.Int("rps", 0, "limit requests per second (overrides delay)")
-		userAgent   = flag.String("user-agent", "", "custom user agent")
-		concurrency = flag.Int("workers", 4, "number of concurrent workers")
-		indent      = flag.Bool("indent", true, "indent JSON output")
-		help        = flag.Bool("help", false, "show help")
-		h           = flag.Bool("h", false, "show help")
-	)
+// headerFlags собирает повторяющиеся флаги --header "Key: Value"
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	parts := make([]string, 0, len(h))
+	for key, value := range h {
+		parts = append(parts, key+": "+value)
+	}
+	return strings.Join(parts, ", ")
+}
 
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `NAME:
+func (h headerFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, ":")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("expected \"Key: Value\", got %q", value)
+	}
+	h[key] = strings.TrimSpace(val)
+	return nil
+}
+
+const usage = `NAME:
    hexlet-go-crawler - analyze a website structure
 
 USAGE:
@@ -36,28 +48,141 @@ COMMANDS:
    help, h  Shows a list of commands or help for one command
 
 GLOBAL OPTIONS:
-   --depth value       crawl depth (default: 10)
-   --retries value     number of retries for failed requests (default: 1)
-   --delay value       delay between requests (example: 200ms, 1s) (default: 0s)
-   --timeout value     per-request timeout (default: 15s)
-   --rps value         limit requests per second (overrides delay) (default: 0)
-   --user-agent value  custom user agent
-   --workers value     number of concurrent workers (default: 4)
-   --help, -h          show help
-`)
+   --depth value             crawl depth (default: 10)
+   --retries value           number of retries for failed requests (default: 1)
+   --delay value             delay between requests (example: 200ms, 1s) (default: 0s)
+   --timeout value           per-request timeout (default: 15s)
+   --rps value               limit requests per second (overrides delay) (default: 0)
+   --user-agent value        custom user agent
+   --workers value           number of concurrent workers (default: 4)
+   --header value            custom request header "Key: Value" (repeatable)
+   --basic-auth value        basic auth credentials user:pass
+   --bearer value            bearer token for Authorization header
+   --max-redirects value     maximum redirects to follow per page, 0 to disable (default: 10)
+   --dry-run                 check page statuses with HEAD requests only (no links or assets)
+   --compare value           print only changes against a prior report (file path or URL)
+   --sitemap value           seed the crawl with URLs from a sitemap or sitemap index
+   --retry-backoff value     base delay before a retry, doubled on each attempt (default: 100ms)
+   --max-hosts value         maximum number of hosts requested concurrently (default: 0, unlimited)
+   --max-requests value      hard cap on total HTTP requests; the crawl stops when reached (default: 0, unlimited)
+   --path-prefix value       crawl only pages whose path starts with this prefix (e.g. /docs/)
+   --confine                 crawl only pages under the directory of the root URL
+   --max-body-bytes value    maximum bytes read from a response body (default: 10485760)
+   --archive-dir value       save the HTML of fetched pages to this directory
+   --format value            output format: json, ndjson (one page per line, then a summary line), html or dot (Graphviz graph of internal links) (default: json)
+   --include-subdomains      crawl subdomains of the root domain (blog.example.com, shop.example.com) as the same site
+   --output value, -o value  write the report to a file instead of stdout
+   --seeds value             file with additional start URLs, one per line (# starts a comment)
+   --head-first              send HEAD before GET and skip downloading non-HTML or oversized pages
+   --sort-assets value       order of page assets: document, type or url (default: document)
+   --not-broken value        comma-separated HTTP statuses not counted as broken links (e.g. 401,403)
+   --crawl-timeout value     stop the whole crawl after this duration and report the pages done so far (default: 0s, unlimited)
+   --max-url-length value    skip links whose URL is longer than this, listed in skipped_urls (default: 0, unlimited)
+   --max-query-params value  skip links with more query parameters than this, listed in skipped_urls (default: 0, unlimited)
+   --allowed-hosts value     comma-separated host patterns crawled as part of the site (e.g. *.example.com,cdn.example.org)
+   --capture-headers value   comma-separated response headers recorded per page (e.g. Content-Security-Policy,Cache-Control)
+   --resolve value           comma-separated host=IP pairs connected to without DNS (e.g. example.com=10.0.0.5)
+   --insecure                skip TLS certificate verification (e.g. self-signed certificates on internal hosts)
+   --client-cert value       PEM file with a client certificate for mutual TLS (requires --client-key)
+   --client-key value        PEM file with the private key of --client-cert
+   --proxy value             proxy URL for all requests: http://, https:// or socks5:// (default: from environment)
+   --all-links               record every outgoing link of a page with internal/external counts
+   --cookie-jar              keep cookies set by the site during the crawl and send them on later requests
+   --start-jitter value      random pause of up to this duration before each worker's first request (default: 0s)
+   --single-page             audit only the given URL: check its links and assets without crawling further (overrides --depth)
+   --cache-ttl value         reuse GET/HEAD responses for repeated URLs within this duration instead of refetching (default: 0s, no cache)
+   --include-noscript        also extract links and assets from <noscript> blocks
+   --assets-same-domain      check only assets hosted on the page's domain, skipping CDN and third-party assets
+   --connect-timeout value   timeout for establishing a connection: TCP connect and, separately, the TLS handshake (default: 0s, transport default)
+   --header-timeout value    timeout for response headers after the request is sent; body reads are limited only by --timeout (default: 0s, unlimited)
+   --parse-css               also check fonts and images referenced from stylesheets via url() and @import
+   --help, -h                show help
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run разбирает аргументы, запускает анализ и печатает результат.
+// Возвращает код завершения процесса.
+func run(arguments []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("hexlet-go-crawler", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	headers := headerFlags{}
+	flags.Var(headers, "header", "custom request header (repeatable)")
+
+	var (
+		depth       = flags.Int("depth", 10, "crawl depth")
+		retries     = flags.Int("retries", 1, "number of retries for failed requests")
+		delayStr    = flags.String("delay", "0s", "delay between requests")
+		timeout     = flags.Duration("timeout", 15*time.Second, "per-request timeout")
+		rps         = flags.Int("rps", 0, "limit requests per second (overrides delay)")
+		userAgent   = flags.String("user-agent", "", "custom user agent")
+		concurrency = flags.Int("workers", 4, "number of concurrent workers")
+		indent      = flags.Bool("indent", true, "indent JSON output")
+		basicAuth   = flags.String("basic-auth", "", "basic auth credentials user:pass")
+		bearer      = flags.String("bearer", "", "bearer token for Authorization header")
+		redirects   = flags.Int("max-redirects", 10, "maximum redirects to follow per page (0 to disable)")
+		dryRun      = flags.Bool("dry-run", false, "check page statuses with HEAD requests only")
+		compare     = flags.String("compare", "", "prior report (file path or URL) to print changes against")
+		sitemapURL  = flags.String("sitemap", "", "sitemap.xml or sitemap index URL to seed the crawl")
+		backoff     = flags.Duration("retry-backoff", 100*time.Millisecond, "base delay before a retry, doubled on each attempt")
+		maxHosts    = flags.Int("max-hosts", 0, "maximum number of hosts requested concurrently")
+		maxRequests = flags.Int("max-requests", 0, "hard cap on total HTTP requests")
+		pathPrefix  = flags.String("path-prefix", "", "crawl only pages whose path starts with this prefix")
+		confine     = flags.Bool("confine", false, "crawl only pages under the directory of the root URL")
+		maxBody     = flags.Int64("max-body-bytes", crawler.DefaultMaxBodyBytes, "maximum bytes read from a response body")
+		archiveDir  = flags.String("archive-dir", "", "save the HTML of fetched pages to this directory")
+		format      = flags.String("format", "json", "output format: json, ndjson, html or dot")
+		subdomains  = flags.Bool("include-subdomains", false, "crawl subdomains of the root domain as the same site")
+		output      = flags.String("output", "", "write the report to a file instead of stdout")
+		o           = flags.String("o", "", "write the report to a file instead of stdout")
+		seedsFile   = flags.String("seeds", "", "file with additional start URLs, one per line")
+		headFirst   = flags.Bool("head-first", false, "send HEAD before GET and skip downloading non-HTML or oversized pages")
+		sortAssets  = flags.String("sort-assets", "document", "order of page assets: document, type or url")
+		notBroken   = flags.String("not-broken", "", "comma-separated HTTP statuses not counted as broken links")
+		maxDuration = flags.Duration("crawl-timeout", 0, "stop the whole crawl after this duration")
+		maxURLLen   = flags.Int("max-url-length", 0, "skip links whose URL is longer than this")
+		maxParams   = flags.Int("max-query-params", 0, "skip links with more query parameters than this")
+		allowed     = flags.String("allowed-hosts", "", "comma-separated host patterns crawled as part of the site")
+		capture     = flags.String("capture-headers", "", "comma-separated response headers recorded per page")
+		resolve     = flags.String("resolve", "", "comma-separated host=IP pairs connected to without DNS")
+		insecure    = flags.Bool("insecure", false, "skip TLS certificate verification")
+		clientCert  = flags.String("client-cert", "", "PEM file with a client certificate for mutual TLS")
+		clientKey   = flags.String("client-key", "", "PEM file with the private key of --client-cert")
+		proxy       = flags.String("proxy", "", "proxy URL for all requests: http, https or socks5")
+		allLinks    = flags.Bool("all-links", false, "record every outgoing link of a page with internal/external counts")
+		cookieJar   = flags.Bool("cookie-jar", false, "keep cookies set by the site during the crawl")
+		jitter      = flags.Duration("start-jitter", 0, "random pause before each worker's first request")
+		singlePage  = flags.Bool("single-page", false, "audit only the given URL without crawling further")
+		cacheTTL    = flags.Duration("cache-ttl", 0, "reuse responses for repeated URLs within this duration")
+		noscript    = flags.Bool("include-noscript", false, "also extract links and assets from <noscript> blocks")
+		ownAssets   = flags.Bool("assets-same-domain", false, "check only assets hosted on the page's domain")
+		dialTimeout = flags.Duration("connect-timeout", 0, "timeout for establishing a connection")
+		hdrTimeout  = flags.Duration("header-timeout", 0, "timeout for response headers")
+		parseCSS    = flags.Bool("parse-css", false, "also check assets referenced from stylesheets")
+		help        = flags.Bool("help", false, "show help")
+		h           = flags.Bool("h", false, "show help")
+	)
+
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
 	}
 
-	flag.Parse()
+	if err := flags.Parse(arguments); err != nil {
+		return 0
+	}
 
 	if *help || *h {
-		flag.Usage()
-		os.Exit(0)
+		flags.Usage()
+		return 0
 	}
 
-	args := flag.Args()
+	args := flags.Args()
 	if len(args) == 0 {
-		flag.Usage()
-		os.Exit(0)
+		flags.Usage()
+		return 0
 	}
 
 	urlStr := args[0]
@@ -65,8 +190,52 @@ GLOBAL OPTIONS:
 	// Парсим delay
 	delay, err := time.ParseDuration(*delayStr)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: invalid delay format: %v\n", err)
-		os.Exit(0)
+		fmt.Fprintf(stderr, "Error: invalid delay format: %v\n", err)
+		return 0
+	}
+
+	if *basicAuth != "" && *bearer != "" {
+		fmt.Fprintln(stderr, "Error: --basic-auth and --bearer cannot be used together")
+		return 0
+	}
+
+	if *format != "json" && *format != "html" && *format != "dot" && *format != "ndjson" {
+		fmt.Fprintf(stderr, "Error: unknown --format %q, expected json, ndjson, html or dot\n", *format)
+		return 0
+	}
+	if *format != "json" && *compare != "" {
+		fmt.Fprintf(stderr, "Error: --format %s cannot be used with --compare\n", *format)
+		return 0
+	}
+
+	basicUser, basicPass, ok := strings.Cut(*basicAuth, ":")
+	if *basicAuth != "" && (!ok || basicUser == "") {
+		fmt.Fprintln(stderr, "Error: invalid --basic-auth format, expected user:pass")
+		return 0
+	}
+
+	var seeds []string
+	if *seedsFile != "" {
+		seeds, err = readSeeds(*seedsFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 0
+		}
+	}
+
+	hostOverrides, err := parseHostOverrides(*resolve)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 0
+	}
+
+	var isBrokenStatus func(int) bool
+	if *notBroken != "" {
+		isBrokenStatus, err = brokenStatusExcept(*notBroken)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 0
+		}
 	}
 
 	// Если rps установлен, переопределяем delay
@@ -76,25 +245,220 @@ GLOBAL OPTIONS:
 
 	// Создаем опции
 	opts := crawler.Options{
-		URL:         urlStr,
-		Depth:       *depth,
-		Retries:     *retries,
-		Delay:       delay,
-		Timeout:     *timeout,
-		UserAgent:   *userAgent,
-		Concurrency: *concurrency,
-		IndentJSON:  *indent,
-		HTTPClient:  &http.Client{},
+		URL:                   urlStr,
+		Depth:                 *depth,
+		Retries:               *retries,
+		Delay:                 delay,
+		Timeout:               *timeout,
+		UserAgent:             *userAgent,
+		Concurrency:           *concurrency,
+		IndentJSON:            *indent,
+		Headers:               headers,
+		BasicAuthUser:         basicUser,
+		BasicAuthPass:         basicPass,
+		BearerToken:           *bearer,
+		MaxRedirects:          *redirects,
+		DryRun:                *dryRun,
+		SitemapURL:            *sitemapURL,
+		RetryBackoff:          *backoff,
+		MaxConcurrentHosts:    *maxHosts,
+		MaxRequests:           *maxRequests,
+		PathPrefix:            *pathPrefix,
+		ConfineToSeedPath:     *confine,
+		MaxBodyBytes:          *maxBody,
+		ArchiveDir:            *archiveDir,
+		IncludeSubdomains:     *subdomains,
+		SeedURLs:              seeds,
+		HeadFirst:             *headFirst,
+		SortAssets:            *sortAssets,
+		IsBrokenStatus:        isBrokenStatus,
+		CrawlTimeout:          *maxDuration,
+		MaxURLLength:          *maxURLLen,
+		MaxQueryParams:        *maxParams,
+		RecordSkippedURLs:     true,
+		AllowedHosts:          splitList(*allowed),
+		CaptureHeaders:        splitList(*capture),
+		HostOverrides:         hostOverrides,
+		InsecureSkipVerify:    *insecure,
+		ClientCert:            *clientCert,
+		ClientKey:             *clientKey,
+		Proxy:                 *proxy,
+		UseCookieJar:          *cookieJar,
+		StartJitter:           *jitter,
+		SinglePage:            *singlePage,
+		CacheTTL:              *cacheTTL,
+		IncludeNoscript:       *noscript,
+		AssetsSameDomainOnly:  *ownAssets,
+		IncludeAllLinks:       *allLinks || *format == "dot",
+		ConnectTimeout:        *dialTimeout,
+		ResponseHeaderTimeout: *hdrTimeout,
+		ParseCSS:              *parseCSS,
+	}
+
+	// SIGINT/SIGTERM отменяют обход: уже начатые страницы дообрабатываются
+	// и частичный отчёт выводится как обычно. Повторный сигнал завершает
+	// процесс сразу — после первого восстанавливается обработка по умолчанию.
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	go func() {
+		<-ctx.Done()
+		stopSignals()
+	}()
+
+	// Предыдущий отчёт загружаем до обхода, чтобы не тратить время на заведомо неудачное сравнение
+	var prevReport *crawler.Report
+	if *compare != "" {
+		prevReport, err = loadReport(ctx, *compare, opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 0
+		}
+	}
+
+	var out io.Writer = stdout
+	if path := firstNonEmpty(*output, *o); path != "" {
+		file := &lazyFile{path: path}
+		defer func() {
+			if err := file.Close(); err != nil {
+				fmt.Fprintf(stderr, "Error: %v\n", err)
+			}
+		}()
+		out = file
+	}
+
+	// Полный JSON-отчёт кодируется сразу в вывод, без промежуточного буфера
+	if prevReport == nil && *format == "json" {
+		if err := crawler.AnalyzeTo(ctx, opts, out); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+		}
+		return 0
+	}
+
+	// Строки страниц выводятся по мере обхода
+	if *format == "ndjson" {
+		if err := crawler.AnalyzeNDJSON(ctx, opts, out); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+		}
+		return 0
 	}
 
 	// Запускаем анализ
-	report, err := crawler.Analyze(context.Background(), opts)
+	report, err := crawler.Analyze(ctx, opts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(0)
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 0
+	}
+
+	if prevReport != nil {
+		report, err = diffReport(prevReport, report, *indent)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 0
+		}
+	}
+
+	switch *format {
+	case "html":
+		report, err = crawler.RenderHTML(report)
+	case "dot":
+		report, err = crawler.RenderDOT(report)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 0
 	}
 
 	// Выводим результат
-	fmt.Println(string(report))
-	os.Exit(0)
+	if _, err := fmt.Fprintln(out, string(report)); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+	}
+	return 0
+}
+
+// readSeeds читает стартовые URL из файла: по одному в строке,
+// пустые строки и строки, начинающиеся с #, пропускаются
+func readSeeds(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seeds: %w", err)
+	}
+
+	var seeds []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, line)
+	}
+	return seeds, nil
+}
+
+// splitList разбирает список через запятую, отбрасывая пустые элементы
+func splitList(list string) []string {
+	var items []string
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			items = append(items, field)
+		}
+	}
+	return items
+}
+
+// brokenStatusExcept разбирает список статусов через запятую и возвращает
+// предикат: битые все статусы вне 200–399, кроме перечисленных
+func brokenStatusExcept(list string) (func(int) bool, error) {
+	excluded := map[int]bool{}
+	for _, field := range strings.Split(list, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid --not-broken status %q", strings.TrimSpace(field))
+		}
+		excluded[code] = true
+	}
+	return func(statusCode int) bool {
+		return crawler.DefaultIsBrokenStatus(statusCode) && !excluded[statusCode]
+	}, nil
+}
+
+// parseHostOverrides разбирает список пар host=IP через запятую
+func parseHostOverrides(list string) (map[string]string, error) {
+	items := splitList(list)
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	overrides := make(map[string]string, len(items))
+	for _, item := range items {
+		host, ip, ok := strings.Cut(item, "=")
+		host, ip = strings.TrimSpace(host), strings.TrimSpace(ip)
+		if !ok || host == "" || net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid --resolve entry %q: expected host=IP", item)
+		}
+		overrides[host] = ip
+	}
+	return overrides, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// diffReport сравнивает текущий JSON-отчёт с предыдущим и кодирует только изменения
+func diffReport(prev *crawler.Report, current []byte, indent bool) ([]byte, error) {
+	var curr crawler.Report
+	if err := json.Unmarshal(current, &curr); err != nil {
+		return nil, err
+	}
+
+	diff := crawler.DiffReports(prev, &curr)
+	if indent {
+		return json.MarshalIndent(diff, "", "  ")
+	}
+	return json.Marshal(diff)
 }
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCompare(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body><a href="/new">New</a></body></html>`))
+		case "/new":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// Первый прогон — полный отчёт
+	var full bytes.Buffer
+	run([]string{"--depth", "0", server.URL}, &full, os.Stderr)
+
+	var fullReport map[string]interface{}
+	if err := json.Unmarshal(full.Bytes(), &fullReport); err != nil {
+		t.Fatalf("expected full JSON report, got error: %v", err)
+	}
+	if _, ok := fullReport["pages"]; !ok {
+		t.Fatalf("expected full report to contain pages")
+	}
+
+	prevPath := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(prevPath, full.Bytes(), 0o600); err != nil {
+		t.Fatalf("failed to write prior report: %v", err)
+	}
+
+	// Второй прогон глубже — появляется новая страница
+	var out bytes.Buffer
+	run([]string{"--depth", "2", "--compare", prevPath, server.URL}, &out, os.Stderr)
+
+	var diff map[string][]interface{}
+	if err := json.Unmarshal(out.Bytes(), &diff); err != nil {
+		t.Fatalf("expected diff JSON, got error: %v\n%s", err, out.String())
+	}
+
+	if _, ok := diff["pages"]; ok {
+		t.Error("diff output should not contain the full page list")
+	}
+	if len(diff["new_pages"]) != 1 || diff["new_pages"][0] != server.URL+"/new" {
+		t.Errorf("expected only /new as a new page, got %v", diff["new_pages"])
+	}
+	for _, key := range []string{"removed_pages", "status_changes", "new_broken_links", "fixed_broken_links"} {
+		if len(diff[key]) != 0 {
+			t.Errorf("expected no %s, got %v", key, diff[key])
+		}
+	}
+}
+
+func TestRunCompareURLUsesCrawlHeaders(t *testing.T) {
+	var prior bytes.Buffer
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/report.json" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(prior.Bytes())
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html></html>`))
+	}))
+	defer server.Close()
+
+	run([]string{"--depth", "0", "--header", "X-Token: secret", server.URL}, &prior, os.Stderr)
+
+	var out, errOut bytes.Buffer
+	run([]string{"--depth", "0", "--header", "X-Token: secret", "--compare", server.URL + "/report.json", server.URL}, &out, &errOut)
+
+	var diff map[string][]interface{}
+	if err := json.Unmarshal(out.Bytes(), &diff); err != nil {
+		t.Fatalf("expected diff JSON, got error: %v\n%s", err, errOut.String())
+	}
+	if len(diff["new_pages"]) != 0 || len(diff["removed_pages"]) != 0 {
+		t.Errorf("expected no changes against the same site, got %v", diff)
+	}
+}
+
+func TestRunFormatHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>Home</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	run([]string{"--depth", "0", "--format", "html", server.URL}, &out, os.Stderr)
+
+	html := out.String()
+	if !strings.HasPrefix(html, "<!DOCTYPE html>") {
+		t.Fatalf("expected HTML report, got %q", html)
+	}
+	if !strings.Contains(html, "Home") || !strings.Contains(html, server.URL) {
+		t.Errorf("expected report to list the root page")
+	}
+
+	var stderr bytes.Buffer
+	out.Reset()
+	run([]string{"--format", "xml", server.URL}, &out, &stderr)
+	if out.Len() != 0 || !strings.Contains(stderr.String(), "unknown --format") {
+		t.Errorf("expected unknown format to be rejected, got stdout %q stderr %q", out.String(), stderr.String())
+	}
+}
+
+func TestRunFormatDOT(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><a href="/about">About</a></body></html>`))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	run([]string{"--depth", "1", "--format", "dot", server.URL}, &out, os.Stderr)
+
+	dot := out.String()
+	if !strings.HasPrefix(dot, "digraph crawl {") {
+		t.Fatalf("expected DOT graph, got %q", dot)
+	}
+	// Ссылки собираются автоматически, без --all-links
+	if edge := `-> "` + server.URL + `/about";`; !strings.Contains(dot, edge) {
+		t.Errorf("expected an edge to /about, got %q", dot)
+	}
+}
+
+func TestRunOutputFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body>ok</body></html>`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+
+	var out bytes.Buffer
+	run([]string{"--depth", "0", "--output", path, server.URL}, &out, os.Stderr)
+	if out.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got %q", out.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected report file: %v", err)
+	}
+	var report map[string]interface{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("expected JSON report in file, got error: %v", err)
+	}
+
+	// Неверный URL — файл не создаётся
+	badPath := filepath.Join(dir, "bad.json")
+	var stderr bytes.Buffer
+	run([]string{"-o", badPath, "://bad"}, &out, &stderr)
+	if _, err := os.Stat(badPath); !os.IsNotExist(err) {
+		t.Errorf("expected no file for an invalid URL, got %v", err)
+	}
+	if stderr.Len() == 0 {
+		t.Errorf("expected error on stderr")
+	}
+}
+
+func TestReadSeeds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seeds.txt")
+	content := "# entry points\nhttps://example.com/a\n\n  https://example.com/b  \r\n# https://example.com/skipped\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	seeds, err := readSeeds(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(seeds, ","); got != "https://example.com/a,https://example.com/b" {
+		t.Fatalf("unexpected seeds %s", got)
+	}
+
+	if _, err := readSeeds(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("expected error for missing seeds file")
+	}
+}
+
+func TestBrokenStatusExcept(t *testing.T) {
+	isBroken, err := brokenStatusExcept("401, 403")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for status, want := range map[int]bool{200: false, 301: false, 401: false, 403: false, 404: true, 500: true} {
+		if got := isBroken(status); got != want {
+			t.Errorf("isBroken(%d) = %v, want %v", status, got, want)
+		}
+	}
+
+	if _, err := brokenStatusExcept("401,abc"); err == nil {
+		t.Error("expected error for invalid status")
+	}
+}
+
+func TestParseHostOverrides(t *testing.T) {
+	overrides, err := parseHostOverrides("example.com=127.0.0.1, www.example.com = ::1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(overrides) != 2 || overrides["example.com"] != "127.0.0.1" || overrides["www.example.com"] != "::1" {
+		t.Errorf("unexpected overrides: %v", overrides)
+	}
+
+	if overrides, err := parseHostOverrides(""); err != nil || overrides != nil {
+		t.Errorf("expected no overrides for an empty list, got %v, %v", overrides, err)
+	}
+	for _, bad := range []string{"example.com", "example.com=staging", "=127.0.0.1"} {
+		if _, err := parseHostOverrides(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestRunFormatNDJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><a href="/about">About</a></body></html>`))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	run([]string{"--depth", "1", "--format", "ndjson", "--indent", server.URL}, &out, os.Stderr)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 2 page lines and a summary line, got %q", out.String())
+	}
+	types := []string{}
+	for _, line := range lines {
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &object); err != nil {
+			t.Fatalf("expected valid JSON per line, got %q: %v", line, err)
+		}
+		types = append(types, object["type"].(string))
+	}
+	if strings.Join(types, ",") != "page,page,summary" {
+		t.Errorf("expected page lines followed by summary, got %v", types)
+	}
+}
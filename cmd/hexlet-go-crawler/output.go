@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// lazyFile создаёт файл --output только при первой записи, чтобы при ошибке
+// (неверный URL, недоступный корень) не оставался пустой файл отчёта
+type lazyFile struct {
+	path string
+	file *os.File
+}
+
+func (f *lazyFile) Write(p []byte) (int, error) {
+	if f.file == nil {
+		file, err := os.Create(f.path)
+		if err != nil {
+			return 0, err
+		}
+		f.file = file
+	}
+	return f.file.Write(p)
+}
+
+// Close закрывает файл, если он был создан
+func (f *lazyFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
+
+var _ io.WriteCloser = (*lazyFile)(nil)
@@ -2,103 +2,446 @@ package crawler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"code/internal/archive"
 	"code/internal/checker"
 	"code/internal/httputil"
 	"code/internal/parser"
 	"code/internal/report"
 	"code/internal/seo"
+	"code/internal/sitemap"
 	"code/internal/state"
 	"code/internal/urlutil"
 )
 
+// Analyze обходит сайт и возвращает JSON-отчёт
 func Analyze(ctx context.Context, opts Options) ([]byte, error) {
-	normalizeOptions(&opts)
-
-	rootURL, err := urlutil.ParseAndValidateURL(opts.URL)
+	reportBuilder, err := analyze(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
+	return reportBuilder.Encode(opts.IndentJSON)
+}
 
-	rateLimiter := httputil.NewRateLimiter(ctx, opts.Delay)
-
-	fetcherCfg := httputil.FetcherConfig{
-		Client:     opts.HTTPClient,
-		UserAgent:  opts.UserAgent,
-		Timeout:    opts.Timeout,
-		MaxRetries: opts.Retries,
+// AnalyzeTo обходит сайт и кодирует JSON-отчёт сразу в w, не собирая его
+// целиком в памяти. Если обход завершился ошибкой, в w ничего не пишется.
+func AnalyzeTo(ctx context.Context, opts Options, w io.Writer) error {
+	reportBuilder, err := analyze(ctx, opts)
+	if err != nil {
+		return err
 	}
-	fetcher := httputil.NewFetcher(fetcherCfg, rateLimiter)
-
-	crawlState := state.NewCrawlState(rootURL, opts.Concurrency, rateLimiter)
-	htmlParser := parser.NewHTMLParser()
-	seoExtractor := seo.NewExtractor()
-	linkChecker := checker.NewLinkChecker(fetcher, opts.Concurrency)
-	assetChecker := checker.NewAssetChecker(fetcher, htmlParser, opts.Concurrency)
-	reportBuilder := report.NewBuilder(rootURL, opts.Depth)
+	return reportBuilder.EncodeTo(w, opts.IndentJSON)
+}
 
-	crawler := &Crawler{
-		state:         crawlState,
-		fetcher:       fetcher,
-		parser:        htmlParser,
-		seoExtractor:  seoExtractor,
-		linkChecker:   linkChecker,
-		assetChecker:  assetChecker,
-		reportBuilder: reportBuilder,
-		maxDepth:      opts.Depth,
+// AnalyzeNDJSON обходит сайт и пишет отчёт в w в формате NDJSON: каждая
+// страница — отдельная строка JSON с "type": "page", выводимая сразу после
+// обработки, последняя строка — сводка отчёта с "type": "summary".
+// Options.PageSink заменяется; IndentJSON не действует. Если обход
+// завершился ошибкой, уже выведенные строки страниц остаются в w.
+func AnalyzeNDJSON(ctx context.Context, opts Options, w io.Writer) error {
+	opts.PageSink = report.NewNDJSONSink(w)
+	reportBuilder, err := analyze(ctx, opts)
+	if err != nil {
+		return err
 	}
+	return reportBuilder.EncodeNDJSONSummary(w)
+}
 
-	crawler.Run(ctx)
+// analyze выполняет обход и возвращает заполненный построитель отчёта
+func analyze(ctx context.Context, opts Options) (*report.Builder, error) {
+	crawler, err := NewCrawler(opts)
+	if err != nil {
+		return nil, err
+	}
+	return crawler.run(ctx)
+}
 
-	return reportBuilder.Encode(opts.IndentJSON)
+// parseSeedURLs проверяет Options.SeedURLs: каждый URL должен быть корректным
+// и относиться к тому же сайту, что и Options.URL
+func parseSeedURLs(seeds []string, rootURL *url.URL, opts *Options) ([]*url.URL, error) {
+	parsed := make([]*url.URL, 0, len(seeds))
+	for _, seed := range seeds {
+		seedURL, err := urlutil.ParseAndValidateURL(seed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed URL %q: %w", seed, err)
+		}
+		if !opts.isSiteURL(seedURL, rootURL) {
+			return nil, fmt.Errorf("seed URL %q is not on the same site as %s", seed, rootURL)
+		}
+		parsed = append(parsed, seedURL)
+	}
+	return parsed, nil
 }
 
+// Crawler обходит один сайт. Создаётся через NewCrawler и может запускаться
+// повторно: HTTP-клиент, разобранные опции и парсеры переиспользуются, а
+// очередь, посещённые URL, кэш ассетов и отчёт создаются заново на каждый Run.
 type Crawler struct {
+	// Общее для всех запусков
+	opts         Options
+	rootURL      *url.URL
+	seedURLs     []*url.URL
+	parser       *parser.HTMLParser
+	seoExtractor *seo.Extractor
+	archiver     *archive.Archiver
+	maxDepth     int
+	// responseCache — кэш ответов (CacheTTL), общий для всех запусков Run
+	responseCache *httputil.ResponseCache
+
+	// runMu не даёт запускать обходы одного Crawler одновременно
+	runMu sync.Mutex
+
+	// Состояние текущего запуска
 	state         *state.CrawlState
 	fetcher       *httputil.Fetcher
-	parser        *parser.HTMLParser
-	seoExtractor  *seo.Extractor
 	linkChecker   *checker.LinkChecker
 	assetChecker  *checker.AssetChecker
 	reportBuilder *report.Builder
-	maxDepth      int
+
+	rootErrMu sync.Mutex
+	rootErr   error
+
+	processed atomic.Int64
+	// running — сколько воркеров ещё не завершилось; workerDone будит crawl
+	// при завершении воркера (см. waitForURLs)
+	running    atomic.Int64
+	workerDone chan struct{}
+	// launched — сколько страниц отдано воркерам (для StartJitter)
+	launched atomic.Int64
+	// sessionIDPages — ключи страниц, найденных по ссылкам с идентификатором сессии
+	sessionIDPages *state.VisitedSet
+	// contentIndex — хеши содержимого обойдённых страниц (DedupeByContent)
+	contentIndex *state.ContentIndex
+	// externalLinks — внешние ссылки, уже отданные на проверку (ValidateExternalLinks)
+	externalLinks *state.VisitedSet
+	// certHosts — хосты, сертификаты которых уже записаны в отчёт
+	certHosts *state.VisitedSet
+
+	// previousPages и currentPages — страницы прошлого и текущего запуска
+	// по ключу URL (только с ConditionalStore), для ответов 304
+	knownMu       sync.Mutex
+	previousPages map[string]knownPage
+	currentPages  map[string]knownPage
+
+	// workCtx — контекст обработки страниц, не зависящий от отмены контекста crawl
+	workCtx context.Context
+	// crawlCtx — контекст crawl: после его отмены воркеры не ждут места в очереди
+	crawlCtx context.Context
+	// cancel отменяет контекст обхода; stopped — обход остановлен досрочно
+	cancel  context.CancelFunc
+	stopped atomic.Bool
+}
+
+// NewCrawler проверяет опции и готовит Crawler к запуску: разбирает URL и
+// шаблоны фильтров, создаёт HTTP-клиент (если не задан HTTPClient) и парсеры
+func NewCrawler(opts Options) (*Crawler, error) {
+	if err := normalizeOptions(&opts); err != nil {
+		return nil, err
+	}
+
+	rootURL, err := urlutil.ParseAndValidateURL(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	seedURLs, err := parseSeedURLs(opts.SeedURLs, rootURL, &opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SinglePage {
+		seedURLs = nil
+	}
+	if opts.PathPrefix == "" && opts.ConfineToSeedPath {
+		opts.PathPrefix = seedPathPrefix(rootURL)
+	}
+
+	c := &Crawler{
+		opts:         opts,
+		rootURL:      rootURL,
+		seedURLs:     seedURLs,
+		parser:       parser.NewHTMLParser(),
+		seoExtractor: seo.NewExtractor(),
+		maxDepth:     opts.Depth,
+	}
+	if opts.ArchiveDir != "" {
+		c.archiver = archive.NewArchiver(opts.ArchiveDir)
+	}
+	c.parser.SetIncludeNoscript(opts.IncludeNoscript)
+	if opts.CacheTTL > 0 {
+		c.responseCache = httputil.NewResponseCache(opts.CacheTTL)
+	}
+	return c, nil
+}
+
+// Run обходит сайт и возвращает отчёт. Каждый запуск начинается с пустой
+// очереди, множества посещённых URL и кэша ассетов, поэтому страницы
+// прошлых запусков в отчёт не попадают. Одновременные вызовы Run
+// выполняются по очереди.
+func (c *Crawler) Run(ctx context.Context) (*Report, error) {
+	reportBuilder, err := c.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return reportBuilder.Report(), nil
+}
+
+// run выполняет один обход и возвращает заполненный построитель отчёта
+func (c *Crawler) run(ctx context.Context) (*report.Builder, error) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	opts := c.opts
+
+	// Отмена внешнего ctx (например, по SIGINT) прекращает выдачу новых URL,
+	// но уже начатые страницы дообрабатываются в workCtx и попадают в отчёт.
+	// Внутренняя остановка (например, по лимиту запросов) отменяет оба контекста.
+	parent := ctx
+	workCtx, stopWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopWork()
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	rateLimiter := httputil.NewRateLimiter(workCtx, opts.Delay)
+	stats := httputil.NewStats()
+
+	fetcherCfg := httputil.FetcherConfig{
+		Client:             opts.HTTPClient,
+		UserAgent:          opts.UserAgent,
+		Timeout:            opts.Timeout,
+		MaxRetries:         opts.Retries,
+		Headers:            opts.Headers,
+		Cookies:            opts.Cookies,
+		BasicAuthUser:      opts.BasicAuthUser,
+		BasicAuthPass:      opts.BasicAuthPass,
+		BearerToken:        opts.BearerToken,
+		Stats:              stats,
+		MaxRedirects:       opts.MaxRedirects,
+		DryRun:             opts.DryRun,
+		RetryBackoff:       opts.RetryBackoff,
+		MaxConcurrentHosts: opts.MaxConcurrentHosts,
+		MaxBodyBytes:       opts.MaxBodyBytes,
+		Logger:             opts.Logger,
+		CaptureHeaders:     opts.CaptureHeaders,
+		Cache:              c.responseCache,
+	}
+	c.fetcher = httputil.NewFetcher(fetcherCfg, rateLimiter)
+
+	c.state = state.NewCrawlState(c.rootURL, opts.Concurrency, rateLimiter)
+	c.state.Queue.SetLimit(opts.MaxQueueSize, opts.QueueFullPolicy)
+	c.linkChecker = checker.NewLinkChecker(c.fetcher, opts.Concurrency)
+	c.linkChecker.SetBrokenStatus(opts.IsBrokenStatus)
+	c.assetChecker = checker.NewAssetChecker(c.fetcher, c.parser, opts.Concurrency)
+	c.assetChecker.SetCacheTTL(opts.AssetCacheTTL)
+	c.assetChecker.SetFilter(opts.ShouldCheckAsset)
+	c.assetChecker.SetSameDomainOnly(opts.AssetsSameDomainOnly)
+	c.assetChecker.SetCacheKey(opts.CanonicalizeURL)
+	c.assetChecker.SetOrder(opts.SortAssets)
+	c.assetChecker.SetMaxAssets(opts.MaxAssetsPerPage)
+	c.assetChecker.SetParseCSS(opts.ParseCSS)
+	c.reportBuilder = report.NewBuilder(c.rootURL, opts.Depth)
+	if opts.PageSink != nil {
+		c.reportBuilder.SetSink(opts.PageSink)
+	}
+	c.sessionIDPages = state.NewVisitedSet()
+	c.contentIndex = state.NewContentIndex()
+	c.externalLinks = state.NewVisitedSet()
+	c.certHosts = state.NewVisitedSet()
+	c.rootErr = nil
+	c.processed.Store(0)
+	c.running.Store(0)
+	c.workerDone = make(chan struct{}, 1)
+	c.launched.Store(0)
+	if opts.ConditionalStore != nil {
+		c.previousPages = c.currentPages
+		c.currentPages = make(map[string]knownPage)
+	}
+	c.stopped.Store(false)
+
+	c.workCtx = workCtx
+	c.crawlCtx = ctx
+	c.cancel = func() {
+		stop()
+		stopWork()
+	}
+	if opts.MaxRequests > 0 {
+		stats.SetLimit(int64(opts.MaxRequests), func() {
+			c.stop(StopReasonMaxRequests)
+		})
+	}
+
+	// Таймаут обхода отменяет и выдачу URL, и загружаемые страницы. Отмена
+	// внешнего ctx сюда не относится: её обрабатывает StopReasonCanceled.
+	if opts.CrawlTimeout > 0 {
+		timeoutCtx, cancelTimeout := context.WithTimeout(parent, opts.CrawlTimeout)
+		defer cancelTimeout()
+		stopOnTimeout := context.AfterFunc(timeoutCtx, func() {
+			if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+				c.stop(StopReasonTimeout)
+			}
+		})
+		defer stopOnTimeout()
+	}
+
+	startedAt := time.Now()
+	c.reportBuilder.SetStartedAt(startedAt)
+
+	// Дополнительные стартовые точки: повторы отсеет множество посещённых
+	for _, seedURL := range c.seedURLs {
+		c.enqueue([]state.URLWithDepth{{URL: c.normalizeURL(seedURL), Depth: 0}}, true)
+	}
+
+	if opts.SitemapURL != "" && !opts.SinglePage {
+		locs, err := sitemap.NewLoader(c.fetcher, opts.Concurrency).Load(ctx, opts.SitemapURL)
+		if err != nil {
+			return nil, err
+		}
+		// URL из sitemap — такие же стартовые точки, как и корень (глубина 0)
+		c.enqueueInternalLinks(locs, nil, 0)
+	}
+
+	c.crawl(ctx)
+	if parent.Err() != nil {
+		c.stop(StopReasonCanceled)
+	}
+	c.markSessionIDPages()
+	c.reportBuilder.SetTotalRequests(stats.Requests())
+	c.reportBuilder.SetTotalRedirects(stats.Redirects())
+	c.reportBuilder.SetStats(stats.Snapshot())
+
+	if err := c.rootError(); err != nil {
+		return nil, err
+	}
+	if err := c.reportBuilder.SinkErr(); err != nil {
+		return nil, fmt.Errorf("page sink: %w", err)
+	}
+
+	return c.reportBuilder, nil
+}
+
+// stop досрочно останавливает обход и записывает причину в отчёт
+func (c *Crawler) stop(reason string) {
+	if !c.stopped.CompareAndSwap(false, true) {
+		return
+	}
+	c.reportBuilder.SetStopReason(reason)
+	if reason == StopReasonTimeout {
+		c.reportBuilder.SetTimedOut()
+	}
+	c.cancel()
 }
 
-func (c *Crawler) Run(ctx context.Context) {
+// crawl обходит очередь, пока она не опустеет или не будет отменён ctx.
+// После отмены новые URL не выдаются, но crawl дожидается уже запущенных
+// воркеров, чтобы их страницы попали в отчёт.
+func (c *Crawler) crawl(ctx context.Context) {
 	for ctx.Err() == nil {
+		// Слот воркера занимается до выдачи URL: пока все воркеры заняты,
+		// URL остаются в очереди, и менее глубокие ссылки, найденные
+		// воркерами, успевают встать в ней раньше более глубоких.
+		// Пока ждём свободный слот, обход могли отменить — тогда выходим.
+		select {
+		case c.state.Semaphore <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
 		item := c.state.Queue.Dequeue()
 
-		// Если очередь пуста, ждём завершения всех воркеров
+		// Если очередь пуста, ждём новых URL или завершения всех воркеров
 		if item == nil {
-			c.state.WG.Wait()
+			<-c.state.Semaphore
+			c.waitForURLs()
 
-			// Проверяем очередь снова — воркеры могли добавить новые URL
+			// Пусто и после ожидания — воркеры завершились, обход окончен
 			if c.state.Queue.IsEmpty() {
 				break
 			}
 			continue
 		}
 
+		if logger := c.opts.Logger; logger != nil {
+			logger.Debug("url dequeued", "url", item.URL, "depth", item.Depth)
+		}
 		c.processURLWithWorker(ctx, item.URL, item.Depth)
 	}
 
 	c.state.WG.Wait()
 }
 
+// processURLWithWorker запускает обработку URL в воркере. Слот воркера
+// (state.Semaphore) уже занят вызывающим; воркер освобождает его по завершении.
 func (c *Crawler) processURLWithWorker(ctx context.Context, urlStr string, depth int) {
-	c.state.WG.Add(1)
-	c.state.Semaphore <- struct{}{}
+	workCtx := c.workCtx
+	if workCtx == nil {
+		workCtx = ctx
+	}
 
+	c.state.WG.Add(1)
+	c.running.Add(1)
 	go func() {
 		defer c.state.WG.Done()
 		defer func() { <-c.state.Semaphore }()
+		defer func() {
+			c.running.Add(-1)
+			select {
+			case c.workerDone <- struct{}{}:
+			default:
+			}
+		}()
 
-		c.processSingleURL(ctx, urlStr, depth)
+		// Первые Concurrency страниц — первые запросы воркеров
+		if c.launched.Add(1) <= int64(c.opts.Concurrency) && !c.waitStartJitter(ctx) {
+			return
+		}
+		c.processSingleURL(workCtx, urlStr, depth)
 	}()
 }
 
+// waitForURLs ждёт, пока в очереди появятся URL или завершатся все воркеры.
+// Воркер, ждущий места в очереди (QueueFullBlock), не завершается, поэтому
+// ждать только завершения воркеров нельзя.
+func (c *Crawler) waitForURLs() {
+	for {
+		changed := c.state.Queue.Changed()
+		if c.running.Load() == 0 || !c.state.Queue.IsEmpty() {
+			return
+		}
+		select {
+		case <-changed:
+		case <-c.workerDone:
+		}
+	}
+}
+
+// waitStartJitter выдерживает случайную паузу из [0, StartJitter).
+// Возвращает false, если обход отменили во время паузы.
+func (c *Crawler) waitStartJitter(ctx context.Context) bool {
+	if c.opts.StartJitter <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(rand.N(c.opts.StartJitter))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 func (c *Crawler) processSingleURL(ctx context.Context, urlStr string, depth int) {
 	select {
 	case <-ctx.Done():
@@ -106,44 +449,158 @@ func (c *Crawler) processSingleURL(ctx context.Context, urlStr string, depth int
 	default:
 	}
 
-	if c.state.Visited.Contains(urlStr) {
+	key := c.urlKeyString(urlStr)
+	if c.state.Visited.Contains(key) {
 		return
 	}
 
-	c.state.Visited.Add(urlStr)
+	c.state.Visited.Add(key)
 
 	page := report.Page{
 		URL:   urlStr,
 		Depth: depth,
 	}
 
-	result := c.fetcher.Fetch(ctx, urlStr)
+	validators := c.storedValidators(key, urlStr)
+	result, headOnly := c.fetchPage(httputil.WithValidators(ctx, urlStr, validators), urlStr)
+	page.HeadOnly = headOnly
+	if logger := c.opts.Logger; logger != nil {
+		if result.Error != nil {
+			logger.Info("page fetch failed", "url", urlStr, "depth", depth, "error", result.Error)
+		} else {
+			logger.Info("page fetched", "url", urlStr, "depth", depth, "status", result.StatusCode)
+		}
+	}
 	page.HTTPStatus = result.StatusCode
+	page.RedirectChain = result.RedirectChain
+	page.RedirectTarget = result.RedirectTarget
+	page.ContentType = result.ContentType
+	page.Charset = result.Charset
+	page.Truncated = result.Truncated
+	page.Headers = result.Headers
+	page.Attempts = result.Attempts
+	c.recordCertificate(result)
+	if c.opts.RecordTechStack {
+		page.Server = result.Server
+		page.PoweredBy = result.PoweredBy
+	}
 
 	if result.Error != nil {
+		// Страница не загружена из-за досрочной остановки — это не ошибка сайта
+		if c.stopped.Load() && (errors.Is(result.Error, httputil.ErrRequestLimit) || ctx.Err() != nil) {
+			return
+		}
+
 		page.Error = result.Error.Error()
+		page.ErrorKind = result.ErrorKind
 		report.SetPageStatus(&page)
+		if errors.Is(result.Error, httputil.ErrRedirectLoop) {
+			page.Status = report.StatusRedirectLoop
+		}
 		page.DiscoveredAt = time.Now().UTC().Format(time.RFC3339)
 		page.SEO = &seo.SEO{}
 		page.BrokenLinks = []checker.BrokenLink{}
 		page.Assets = []checker.Asset{}
-		c.reportBuilder.AddPage(page)
+		c.addPage(page)
 		return
 	}
 
 	report.SetPageStatus(&page)
+	page.ETag = result.Validators.ETag
+	page.LastModified = result.Validators.LastModified
+
+	if page.Status == report.StatusNotModified {
+		c.reuseUnchangedPage(&page, key, validators)
+		return
+	}
+	if store := c.opts.ConditionalStore; store != nil && page.Status == "ok" && (page.ETag != "" || page.LastModified != "") {
+		store.Set(urlStr, page.ETag, page.LastModified)
+	}
+
+	// SeedURLs и URL из sitemap тоже имеют глубину 0, но проверяется только корень
+	if key == c.urlKey(c.rootURL) && !c.checkRootContentType(result) {
+		return
+	}
+
+	if page.Status == "ok" && c.opts.isSoft404(result.HTMLContent) {
+		page.Status = report.StatusSoft404
+	}
 
+	if c.archiver != nil && len(result.Body) > 0 {
+		// Архивируется тело как получено, а не перекодированный HTMLContent.
+		// Ошибка записи архива не прерывает обход, а попадает в предупреждения страницы
+		if path, err := c.archiver.Save(c.urlKeyString(urlStr), result.Body); err != nil {
+			page.Warnings = append(page.Warnings, err.Error())
+		} else {
+			page.ArchivePath = path
+		}
+	}
+
+	if c.opts.VerifyStability && result.HTMLContent != "" {
+		page.Unstable = c.isUnstable(ctx, result)
+	}
+
+	if c.opts.DedupeByContent && result.HTMLContent != "" && page.Status == "ok" {
+		// Ссылки дубликата уже найдены на первой странице и не обходятся повторно
+		if first, seen := c.contentIndex.FirstOrAdd(contentHash(result.HTMLContent), urlStr); seen {
+			page.Status = report.StatusDuplicate
+			page.CanonicalOf = first
+		}
+	}
+
+	var links []string
 	if result.HTMLContent != "" {
-		pageURL, _ := url.Parse(urlStr)
+		// Ссылки разрешаются относительно адреса после редиректов
+		pageURL, _ := url.Parse(result.FinalURL)
+		htmlContent := result.HTMLContent
+		if c.opts.PreprocessHTML != nil {
+			htmlContent = c.opts.PreprocessHTML(result.FinalURL, htmlContent)
+		}
+
+		// Время разбора HTML (без сетевых проверок ссылок и ассетов)
+		parseStarted := time.Now()
+		page.SEO = c.seoExtractor.Extract(htmlContent, pageURL)
+		links = c.parser.ExtractLinks(htmlContent, pageURL)
+		if c.opts.FollowMetaRefresh {
+			// Переход по мета-обновлению — такая же ссылка страницы
+			if target := c.parser.ExtractMetaRefresh(htmlContent, pageURL); target != "" {
+				page.MetaRefresh = target
+				links = append(links, target)
+			}
+		}
+		if c.opts.DetectDuplicateIDs {
+			page.DuplicateIDs = c.parser.ExtractDuplicateIDs(htmlContent)
+		}
+		page.ParseTimeMs = time.Since(parseStarted).Milliseconds()
 
-		page.SEO = c.seoExtractor.Extract(result.HTMLContent)
-		links := c.parser.ExtractLinks(result.HTMLContent, pageURL)
 		page.BrokenLinks, page.DiscoveredAt = c.linkChecker.CheckLinks(ctx, links)
-		page.Assets = c.assetChecker.CheckAssets(ctx, result.HTMLContent, pageURL)
+		for i := range page.BrokenLinks {
+			page.BrokenLinks[i].FoundOn = urlStr
+		}
+		if c.opts.RecordExternalDomains {
+			page.ExternalDomains = c.externalDomains(links)
+		}
+		if c.opts.ValidateExternalLinks {
+			c.validateExternalLinks(ctx, links, urlStr)
+		}
+		if c.opts.IncludeAllLinks {
+			c.recordLinks(&page, links)
+		}
+		page.Assets, page.AssetsTruncated = c.assetChecker.CheckAssets(ctx, htmlContent, pageURL)
+		// Результаты проверок кэшируются между страницами, а ссылающаяся
+		// страница у каждой своя — она записывается в копию результата
+		for i := range page.Assets {
+			page.Assets[i].FoundOn = urlStr
+		}
+		page.SEO.MixedContentCount = countMixedContent(pageURL, links, page.Assets)
 
-		// Добавляем внутренние ссылки в очередь только если не достигли maxDepth
-		if depth+1 < c.maxDepth && page.Status == "ok" {
-			c.enqueueInternalLinks(links, depth+1)
+		if c.opts.FollowCanonical && c.handleCanonical(&page, htmlContent, pageURL, depth) {
+			if c.opts.SkipNonCanonical {
+				return
+			}
+		} else if depth+1 <= c.maxDepth && page.Status == "ok" {
+			// Ссылки страницы имеют глубину depth+1 и обходятся, пока она не больше maxDepth
+			c.enqueueInternalLinks(links, pageURL, depth+1)
 		}
 	} else {
 		page.DiscoveredAt = time.Now().UTC().Format(time.RFC3339)
@@ -152,25 +609,474 @@ func (c *Crawler) processSingleURL(ctx context.Context, urlStr string, depth int
 		page.Assets = []checker.Asset{}
 	}
 
+	c.rememberPage(key, page, links)
+	c.addPage(page)
+}
+
+// storedValidators возвращает валидаторы страницы из ConditionalStore.
+// Условный запрос отправляется, только если данные и ссылки страницы
+// сохранены прошлым запуском этого Crawler: иначе ответ 304 нечем
+// заполнить, и обход остановился бы на этой странице.
+func (c *Crawler) storedValidators(key, urlStr string) httputil.Validators {
+	if c.opts.ConditionalStore == nil {
+		return httputil.Validators{}
+	}
+	c.knownMu.Lock()
+	_, known := c.previousPages[key]
+	c.knownMu.Unlock()
+	if !known {
+		return httputil.Validators{}
+	}
+	etag, lastModified := c.opts.ConditionalStore.Get(urlStr)
+	return httputil.Validators{ETag: etag, LastModified: lastModified}
+}
+
+// knownPage — страница прошлого запуска вместе с её ссылками
+type knownPage struct {
+	page  report.Page
+	links []string
+}
+
+// rememberPage сохраняет успешно загруженную страницу для следующего Run:
+// если сервер ответит на неё 304, данные и ссылки возьмутся отсюда
+func (c *Crawler) rememberPage(key string, page report.Page, links []string) {
+	if c.opts.ConditionalStore == nil || page.Status != "ok" {
+		return
+	}
+	c.knownMu.Lock()
+	defer c.knownMu.Unlock()
+	c.currentPages[key] = knownPage{page: page, links: links}
+}
+
+// reuseUnchangedPage заполняет страницу с ответом 304 данными прошлого
+// запуска и ставит в очередь её ссылки. Если данных нет (сервер ответил 304
+// без условного запроса), в отчёт попадает только статус и валидаторы.
+func (c *Crawler) reuseUnchangedPage(page *report.Page, key string, validators httputil.Validators) {
+	// Сервер не обязан повторять валидаторы в ответе 304
+	if page.ETag == "" && page.LastModified == "" {
+		page.ETag = validators.ETag
+		page.LastModified = validators.LastModified
+	}
+	page.DiscoveredAt = time.Now().UTC().Format(time.RFC3339)
+
+	c.knownMu.Lock()
+	known, ok := c.previousPages[key]
+	if ok {
+		c.currentPages[key] = known
+	}
+	c.knownMu.Unlock()
+
+	if !ok {
+		page.SEO = &seo.SEO{}
+		page.BrokenLinks = []checker.BrokenLink{}
+		page.Assets = []checker.Asset{}
+		c.addPage(*page)
+		return
+	}
+
+	reused := known.page
+	reused.URL = page.URL
+	reused.Depth = page.Depth
+	reused.HTTPStatus = page.HTTPStatus
+	reused.Status = page.Status
+	reused.ETag = page.ETag
+	reused.LastModified = page.LastModified
+	reused.DiscoveredAt = page.DiscoveredAt
+	reused.RedirectChain = page.RedirectChain
+	*page = reused
+
+	if page.Depth+1 <= c.maxDepth {
+		pageURL, _ := url.Parse(page.URL)
+		c.enqueueInternalLinks(known.links, pageURL, page.Depth+1)
+	}
+	c.addPage(*page)
+}
+
+// fetchPage загружает страницу. С HeadFirst сначала отправляется HEAD, и если
+// по его заголовкам тело не нужно (не HTML или больше MaxBodyBytes),
+// возвращается результат HEAD с headOnly. Ошибка или не-2xx ответ на HEAD
+// (сервер не поддерживает HEAD, редирект) — обычная загрузка через GET.
+func (c *Crawler) fetchPage(ctx context.Context, urlStr string) (result httputil.FetchResult, headOnly bool) {
+	if !c.opts.HeadFirst || c.opts.DryRun {
+		return c.fetcher.Fetch(ctx, urlStr), false
+	}
+
+	head := c.fetcher.Head(ctx, urlStr)
+	if head.Error == nil && head.StatusCode >= 200 && head.StatusCode < 300 {
+		tooLarge := head.ContentLength > c.fetcher.MaxBodyBytes()
+		notHTML := head.ContentType != "" && !httputil.IsHTMLContent(head.ContentType)
+		if tooLarge || notHTML {
+			return head, true
+		}
+	}
+
+	return c.fetcher.Fetch(ctx, urlStr), false
+}
+
+// countMixedContent считает http-ссылки и http-ассеты страницы, открытой по https
+func countMixedContent(pageURL *url.URL, links []string, assets []checker.Asset) int {
+	count := 0
+	for _, link := range links {
+		if urlutil.IsMixedContent(pageURL, link) {
+			count++
+		}
+	}
+	for _, asset := range assets {
+		if asset.MixedContent {
+			count++
+		}
+	}
+	return count
+}
+
+// addPage добавляет страницу в отчёт и сообщает о прогрессе.
+// Событие не отправляется, если получатель не успевает его принять.
+func (c *Crawler) addPage(page report.Page) {
 	c.reportBuilder.AddPage(page)
+	if c.reportBuilder.SinkErr() != nil && c.stopped.CompareAndSwap(false, true) {
+		// Страницы больше некуда сохранять: run вернёт ошибку приёмника
+		c.cancel()
+	}
+	processed := c.processed.Add(1)
+
+	if c.opts.Progress == nil {
+		return
+	}
+
+	select {
+	case c.opts.Progress <- ProgressEvent{
+		URL:       page.URL,
+		Depth:     page.Depth,
+		Processed: int(processed),
+		Queued:    c.state.Queue.Len(),
+	}:
+	default:
+	}
+}
+
+// markSessionIDPages помечает страницы, на которые вели ссылки с
+// идентификатором сессии. Выполняется после обхода: такая ссылка может
+// найтись уже после того, как страница обработана по чистому URL.
+func (c *Crawler) markSessionIDPages() {
+	if !c.opts.DetectSessionIDs {
+		return
+	}
+	c.reportBuilder.UpdatePages(func(page *report.Page) {
+		if c.sessionIDPages.Contains(c.urlKeyString(page.URL)) {
+			page.SessionIDInURL = true
+		}
+	})
+}
+
+// isInternal сообщает, относится ли URL к обходимому сайту
+// (с IncludeSubdomains — включая поддомены корневого домена, а также AllowedHosts)
+func (c *Crawler) isInternal(u *url.URL) bool {
+	return c.opts.isSiteURL(u, c.state.BaseURL)
+}
+
+// externalDomains возвращает отсортированный список уникальных хостов
+// ссылок, ведущих за пределы обходимого домена
+func (c *Crawler) externalDomains(links []string) []string {
+	seen := make(map[string]bool)
+	domains := []string{}
+	for _, link := range links {
+		linkURL, err := url.Parse(link)
+		if err != nil || linkURL.Host == "" || c.isInternal(linkURL) {
+			continue
+		}
+		domain := strings.ToLower(linkURL.Hostname())
+		if !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// validateExternalLinks проверяет внешние http(s)-ссылки страницы, ещё
+// не проверенные в этом обходе, и записывает результаты в отчёт.
+// Ссылки сравниваются без fragment.
+func (c *Crawler) validateExternalLinks(ctx context.Context, links []string, foundOn string) {
+	var fresh []string
+	for _, link := range links {
+		linkURL, err := url.Parse(link)
+		if err != nil || (linkURL.Scheme != "http" && linkURL.Scheme != "https") || c.isInternal(linkURL) {
+			continue
+		}
+		linkURL.Fragment = ""
+		linkURL.RawFragment = ""
+		if target := linkURL.String(); c.externalLinks.AddNew(target) {
+			fresh = append(fresh, target)
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+
+	checked := c.linkChecker.CheckAll(ctx, fresh)
+	for i := range checked {
+		checked[i].FoundOn = foundOn
+	}
+	c.reportBuilder.AddExternalLinks(checked)
 }
 
-func (c *Crawler) enqueueInternalLinks(links []string, depth int) {
+// recordCertificate записывает в отчёт сертификат хоста страницы
+// при первом HTTPS-ответе с этого хоста
+func (c *Crawler) recordCertificate(result httputil.FetchResult) {
+	if result.TLS == nil {
+		return
+	}
+	pageURL, err := url.Parse(result.FinalURL)
+	if err != nil {
+		return
+	}
+	host := strings.ToLower(pageURL.Host)
+	if !c.certHosts.AddNew(host) {
+		return
+	}
+
+	if info, ok := httputil.PeerCertInfo(result.TLS, pageURL.Hostname()); ok {
+		c.reportBuilder.AddHost(host, report.HostInfo{
+			CertNotAfter: info.NotAfter.UTC().Format(time.RFC3339),
+			CertIssuer:   info.Issuer,
+			CertValid:    info.Valid,
+		})
+	}
+}
+
+// recordLinks записывает в страницу её ссылки без повторов и считает
+// внутренние и внешние
+func (c *Crawler) recordLinks(page *report.Page, links []string) {
+	seen := make(map[string]bool, len(links))
+	for _, link := range links {
+		if seen[link] {
+			continue
+		}
+		seen[link] = true
+		page.Links = append(page.Links, link)
+
+		if linkURL, err := url.Parse(link); err == nil && c.isInternal(linkURL) {
+			page.InternalLinkCount++
+		} else {
+			page.ExternalLinkCount++
+		}
+	}
+}
+
+// checkRootContentType сверяет Content-Type корневой страницы с ExpectContentType.
+// При несовпадении запоминает ошибку, и обход дальше не идёт.
+func (c *Crawler) checkRootContentType(result httputil.FetchResult) bool {
+	expected := c.opts.ExpectContentType
+	if expected == "" || result.StatusCode < 200 || result.StatusCode >= 300 {
+		return true
+	}
+
+	if strings.HasPrefix(strings.ToLower(result.ContentType), strings.ToLower(expected)) {
+		return true
+	}
+
+	c.rootErrMu.Lock()
+	defer c.rootErrMu.Unlock()
+	if c.rootErr == nil {
+		c.rootErr = fmt.Errorf("unexpected root content type %q, expected %q", result.ContentType, expected)
+	}
+	return false
+}
+
+func (c *Crawler) rootError() error {
+	c.rootErrMu.Lock()
+	defer c.rootErrMu.Unlock()
+	return c.rootErr
+}
+
+// isUnstable повторно загружает страницу и сравнивает хэши тел ответов.
+// Повторный запрос проходит через тот же Fetcher и rate limiter, но мимо
+// кэша ответов.
+func (c *Crawler) isUnstable(ctx context.Context, first httputil.FetchResult) bool {
+	second := c.fetcher.Fetch(httputil.WithoutCache(ctx), first.FinalURL)
+	if second.Error != nil || second.StatusCode != first.StatusCode {
+		return second.Error == nil
+	}
+	return sha256.Sum256([]byte(first.HTMLContent)) != sha256.Sum256([]byte(second.HTMLContent))
+}
+
+// contentHash — sha256 HTML без пробельных символов, чтобы страницы,
+// отличающиеся только форматированием, считались одинаковыми
+func contentHash(htmlContent string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(htmlContent), "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// handleCanonical помечает страницу как non_canonical и ставит canonical URL
+// в очередь на той же глубине. Возвращает true, если страница не каноническая
+// и её canonical обходится вместо неё. Если canonical не принят (внешний,
+// отфильтрован, ловушка), возвращает false: ссылки страницы обходятся как обычно.
+func (c *Crawler) handleCanonical(page *report.Page, htmlContent string, pageURL *url.URL, depth int) bool {
+	canonical := c.parser.ExtractCanonical(htmlContent, pageURL)
+	if canonical == "" || c.urlKeyString(canonical) == c.urlKey(pageURL) {
+		return false
+	}
+
+	page.Canonical = canonical
+	page.NonCanonical = true
+	return c.enqueueInternalLinks([]string{canonical}, pageURL, depth) > 0
+}
+
+// normalizeURL приводит найденный URL к виду, в котором он ставится в очередь
+func (c *Crawler) normalizeURL(u *url.URL) string {
+	stripParams := c.opts.StripParams
+	if c.opts.DetectSessionIDs {
+		stripParams = append(append([]string{}, stripParams...), c.opts.SessionIDParams...)
+	}
+	return urlutil.NormalizeURLWithOpts(u, urlutil.NormalizeOptions{
+		StripParams:          stripParams,
+		CaseInsensitivePaths: c.opts.CaseInsensitivePaths,
+	})
+}
+
+// urlKey вычисляет ключ URL в множестве посещённых: Options.CanonicalizeURL,
+// если задан, иначе normalizeURL
+func (c *Crawler) urlKey(u *url.URL) string {
+	if c.opts.CanonicalizeURL != nil {
+		return c.opts.CanonicalizeURL(u)
+	}
+	return c.normalizeURL(u)
+}
+
+func (c *Crawler) urlKeyString(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return urlStr
+	}
+	return c.urlKey(u)
+}
+
+// enqueueInternalLinks ставит в очередь внутренние ссылки, прошедшие фильтры.
+// Возвращает, сколько ссылок обходится: поставлено в очередь или уже посещено.
+func (c *Crawler) enqueueInternalLinks(links []string, pageURL *url.URL, depth int) (accepted int) {
+	if c.opts.SinglePage {
+		return 0
+	}
+
 	toAdd := []state.URLWithDepth{}
 
 	for _, link := range links {
 		linkURL, err := url.Parse(link)
-		if err != nil || !urlutil.IsSameDomain(linkURL, c.state.BaseURL) {
+		if err != nil {
+			c.logSkippedLink(link, "invalid url")
+			continue
+		}
+		if !c.isInternal(linkURL) {
+			c.logSkippedLink(link, "external")
 			continue
 		}
 
-		normalized := urlutil.NormalizeURL(linkURL)
-		if !c.state.Visited.Contains(normalized) {
-			toAdd = append(toAdd, state.URLWithDepth{URL: normalized, Depth: depth})
+		// Ссылки на саму страницу (#top, ?ref=nav) не создают новых целей обхода
+		if pageURL != nil && urlutil.IsSelfLink(linkURL, pageURL, c.opts.IgnoreSelfLinkParams) {
+			c.logSkippedLink(link, "self link")
+			continue
 		}
+
+		normalized := c.normalizeURL(linkURL)
+		if reason := c.trapReason(normalized); reason != "" {
+			c.logSkippedLink(link, reason)
+			if c.opts.RecordSkippedURLs {
+				c.reportBuilder.AddSkippedURL(normalized, reason)
+			}
+			continue
+		}
+		if !c.opts.matchesPathPrefix(linkURL) {
+			c.logSkippedLink(link, "outside path prefix")
+			continue
+		}
+		if !c.opts.matchesFilters(normalized) {
+			c.logSkippedLink(link, "filtered")
+			continue
+		}
+
+		// Ссылка с идентификатором сессии ведёт на ту же страницу, что и без него
+		if c.opts.DetectSessionIDs && urlutil.HasParam(linkURL, c.opts.SessionIDParams) {
+			c.sessionIDPages.Add(c.urlKey(linkURL))
+		}
+
+		if c.state.Visited.Contains(c.urlKey(linkURL)) {
+			c.logSkippedLink(link, "visited")
+			accepted++
+			continue
+		}
+
+		if c.opts.ShouldCrawl != nil && !c.opts.ShouldCrawl(linkURL, depth) {
+			c.logSkippedLink(link, "rejected by ShouldCrawl")
+			continue
+		}
+
+		if logger := c.opts.Logger; logger != nil {
+			logger.Debug("link enqueued", "url", normalized, "depth", depth)
+		}
+		toAdd = append(toAdd, state.URLWithDepth{URL: normalized, Depth: depth})
 	}
 
 	if len(toAdd) > 0 {
-		c.state.Queue.Enqueue(toAdd)
+		c.enqueue(toAdd, pageURL == nil)
+	}
+	return accepted + len(toAdd)
+}
+
+// enqueue ставит URL в очередь и записывает отброшенные из-за её размера
+// (QueueFullDrop). Воркер при QueueFullBlock ждёт места в очереди и на время
+// ожидания отдаёт свой слот, чтобы crawl мог запускать URL из очереди.
+// Стартовые URL (start) ставятся без ожидания: очередь ещё никто не разбирает.
+func (c *Crawler) enqueue(urls []state.URLWithDepth, start bool) {
+	var dropped []state.URLWithDepth
+	if start {
+		dropped = c.state.Queue.Enqueue(urls)
+	} else {
+		yielded := false
+		dropped, _ = c.state.Queue.EnqueueWait(c.crawlCtx, urls, func() {
+			<-c.state.Semaphore
+			yielded = true
+		})
+		if yielded {
+			c.state.Semaphore <- struct{}{}
+		}
+	}
+
+	for _, item := range dropped {
+		c.logSkippedLink(item.URL, report.SkipReasonQueueFull)
+		if c.opts.RecordSkippedURLs {
+			c.reportBuilder.AddSkippedURL(item.URL, report.SkipReasonQueueFull)
+		}
+	}
+}
+
+// trapReason проверяет ссылку на признаки ловушки обхода (MaxURLLength,
+// MaxQueryParams) и возвращает причину пропуска или пустую строку.
+// Проверяется нормализованный URL: отброшенные параметры не считаются.
+func (c *Crawler) trapReason(normalized string) string {
+	if c.opts.MaxURLLength > 0 && len(normalized) > c.opts.MaxURLLength {
+		return report.SkipReasonURLTooLong
+	}
+	if c.opts.MaxQueryParams <= 0 {
+		return ""
+	}
+	if u, err := url.Parse(normalized); err == nil && u.RawQuery != "" {
+		params := 0
+		for _, values := range u.Query() {
+			params += len(values)
+		}
+		if params > c.opts.MaxQueryParams {
+			return report.SkipReasonTooManyQueryParams
+		}
+	}
+	return ""
+}
+
+// logSkippedLink сообщает, почему ссылка не поставлена в очередь.
+// Аргументы — обычные строки, поэтому без логгера вызов не аллоцирует.
+func (c *Crawler) logSkippedLink(link, reason string) {
+	if c.opts.Logger == nil {
+		return
 	}
+	c.opts.Logger.Debug("link skipped", "url", link, "reason", reason)
 }
@@ -1,14 +1,31 @@
 package crawler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
+
+	"code/crawler/crawlertest"
+	"code/internal/httputil"
+	"code/internal/report"
+	"code/internal/state"
 )
 
 // MockHTTPClient для подмены реальных HTTP запросов
@@ -304,6 +321,9 @@ func TestAnalyzeRetries(t *testing.T) {
 	if attemptCount != 3 {
 		t.Errorf("Expected 3 attempts, got %d", attemptCount)
 	}
+	if page.Attempts != 3 {
+		t.Errorf("Expected page.Attempts to be 3, got %d", page.Attempts)
+	}
 }
 
 // TestBrokenLinks проверяет обнаружение битых ссылок
@@ -624,3 +644,3991 @@ func TestSEOHTMLEntities(t *testing.T) {
 		t.Errorf("Expected description with decoded quotes, got '%s'", page.SEO.Description)
 	}
 }
+
+// TestCustomHeadersAndCookies проверяет что заголовки и cookies уходят во всех запросах
+func TestCustomHeadersAndCookies(t *testing.T) {
+	htmlContent := `<html><body>
+		<a href="/page">Link</a>
+		<img src="/logo.png">
+	</body></html>`
+
+	var mu sync.Mutex
+	seen := map[string]*http.Request{}
+
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			seen[req.Method+" "+req.URL.Path] = req
+			mu.Unlock()
+
+			header := http.Header{}
+			body := ""
+			if req.URL.Path == "" {
+				header.Set("Content-Type", "text/html")
+				body = htmlContent
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:         "https://example.com",
+		Depth:       0,
+		Concurrency: 1,
+		UserAgent:   "Crawler/1.0",
+		HTTPClient:  mockClient,
+		Headers:     map[string]string{"X-Auth": "secret"},
+		Cookies:     []*http.Cookie{{Name: "session", Value: "abc"}},
+	}
+
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	for _, key := range []string{"GET ", "HEAD /page", "GET /logo.png"} {
+		req, ok := seen[key]
+		if !ok {
+			t.Fatalf("Expected request %q to be made", key)
+		}
+		if got := req.Header.Get("X-Auth"); got != "secret" {
+			t.Errorf("%s: expected X-Auth header 'secret', got %q", key, got)
+		}
+		if got := req.Header.Get("User-Agent"); got != "Crawler/1.0" {
+			t.Errorf("%s: expected User-Agent 'Crawler/1.0', got %q", key, got)
+		}
+		if cookie, err := req.Cookie("session"); err != nil || cookie.Value != "abc" {
+			t.Errorf("%s: expected session cookie, got %v", key, err)
+		}
+	}
+}
+
+// TestFollowCanonical проверяет что canonical URL ставится в очередь и обходится
+func TestFollowCanonical(t *testing.T) {
+	pages := map[string]string{
+		"":      `<html><head><link rel="canonical" href="/main"></head><body></body></html>`,
+		"/main": `<html><head><link rel="canonical" href="https://example.com/main"></head><body></body></html>`,
+	}
+
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			body, ok := pages[req.URL.Path]
+			if !ok {
+				return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	for _, skip := range []bool{false, true} {
+		opts := Options{
+			URL:              "https://example.com",
+			Depth:            0,
+			Concurrency:      1,
+			HTTPClient:       mockClient,
+			FollowCanonical:  true,
+			SkipNonCanonical: skip,
+		}
+
+		result, err := Analyze(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+
+		var report Report
+		if err := json.Unmarshal(result, &report); err != nil {
+			t.Fatalf("Failed to unmarshal report: %v", err)
+		}
+
+		byURL := map[string]Page{}
+		for _, page := range report.Pages {
+			byURL[page.URL] = page
+		}
+
+		if _, ok := byURL["https://example.com/main"]; !ok {
+			t.Fatalf("skip=%v: expected canonical URL to be crawled, got %v", skip, byURL)
+		}
+
+		root, ok := byURL["https://example.com"]
+		if skip {
+			if ok {
+				t.Errorf("Expected non-canonical root to be suppressed")
+			}
+			continue
+		}
+		if !ok || !root.NonCanonical || root.Canonical != "https://example.com/main" {
+			t.Errorf("Expected root to be marked non-canonical, got %+v", root)
+		}
+	}
+}
+
+// TestFollowCanonicalCrossDomain проверяет, что при внешнем canonical
+// страница остаётся в отчёте, а её ссылки обходятся
+func TestFollowCanonicalCrossDomain(t *testing.T) {
+	canonical := `<link rel="canonical" href="https://mirror.example.net/">`
+	mockClient, fetched := newSiteMock(map[string]string{
+		"/":      `<html><head>` + canonical + `</head><body><a href="/about">About</a></body></html>`,
+		"/about": `<html><head>` + canonical + `</head><body>About</body></html>`,
+	})
+
+	result, err := Analyze(context.Background(), Options{
+		URL:              "https://example.com/",
+		Depth:            1,
+		Concurrency:      1,
+		HTTPClient:       mockClient,
+		FollowCanonical:  true,
+		SkipNonCanonical: true,
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if got := fetched(); !slices.Equal(got, []string{"/", "/about"}) {
+		t.Errorf("expected the page links to be followed, fetched %v", got)
+	}
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	if len(report.Pages) != 2 {
+		t.Fatalf("expected both pages in the report, got %d", len(report.Pages))
+	}
+	for _, page := range report.Pages {
+		if !page.NonCanonical || page.Canonical != "https://mirror.example.net" {
+			t.Errorf("expected %s to keep its external canonical, got %q", page.URL, page.Canonical)
+		}
+	}
+}
+
+// TestAuthOptions проверяет Basic и Bearer авторизацию
+func TestAuthOptions(t *testing.T) {
+	var gotAuth string
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			gotAuth = req.Header.Get("Authorization")
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{"basic", Options{BasicAuthUser: "user", BasicAuthPass: "pass"}, "Basic dXNlcjpwYXNz"},
+		{"bearer", Options{BearerToken: "token"}, "Bearer token"},
+	}
+
+	for _, tt := range tests {
+		opts := tt.opts
+		opts.URL = "https://example.com"
+		opts.Concurrency = 1
+		opts.HTTPClient = mockClient
+
+		if _, err := Analyze(context.Background(), opts); err != nil {
+			t.Fatalf("%s: Analyze failed: %v", tt.name, err)
+		}
+		if gotAuth != tt.expected {
+			t.Errorf("%s: expected Authorization %q, got %q", tt.name, tt.expected, gotAuth)
+		}
+	}
+
+	opts := Options{
+		URL:           "https://example.com",
+		HTTPClient:    mockClient,
+		BasicAuthUser: "user",
+		BearerToken:   "token",
+	}
+	if _, err := Analyze(context.Background(), opts); err == nil {
+		t.Error("Expected error when both basic auth and bearer token are set")
+	}
+}
+
+// TestEffectiveRPS проверяет расчёт фактической скорости запросов
+func TestEffectiveRPS(t *testing.T) {
+	htmlContent := `<html><body><a href="/a">A</a><a href="/b">B</a></body></html>`
+
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			time.Sleep(10 * time.Millisecond)
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(htmlContent)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:         "https://example.com",
+		Depth:       0,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	summary := report.Summary
+	// GET страницы + HEAD для двух ссылок
+	if summary.TotalRequests != 3 {
+		t.Errorf("Expected 3 requests, got %d", summary.TotalRequests)
+	}
+	if report.DurationMs < 30 {
+		t.Errorf("Expected duration of at least 30ms, got %d", report.DurationMs)
+	}
+	if summary.EffectiveRPS <= 0 {
+		t.Fatalf("Expected positive effective_rps, got %f", summary.EffectiveRPS)
+	}
+
+	expected := float64(summary.TotalRequests) / (float64(report.DurationMs) / 1000)
+	if math.Abs(summary.EffectiveRPS-expected) > 1e-9 {
+		t.Errorf("Expected effective_rps %f, got %f", expected, summary.EffectiveRPS)
+	}
+}
+
+// newSiteMock возвращает клиент, отдающий HTML-страницы по пути (404 для остальных),
+// и функцию, возвращающую пути страниц, запрошенных через GET
+func newSiteMock(pages map[string]string) (*MockHTTPClient, func() []string) {
+	var mu sync.Mutex
+	fetched := []string{}
+
+	client := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet {
+				mu.Lock()
+				fetched = append(fetched, req.URL.Path)
+				mu.Unlock()
+			}
+
+			body, ok := pages[req.URL.Path]
+			if !ok {
+				return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		result := append([]string{}, fetched...)
+		sort.Strings(result)
+		return result
+	}
+}
+
+// TestIncludeExcludeFilters проверяет фильтрацию ссылок регулярными выражениями
+func TestIncludeExcludeFilters(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"": `<html><body>
+			<a href="/blog/a">A</a>
+			<a href="/blog/b">B</a>
+			<a href="/admin/x">Admin</a>
+			<a href="/about">About</a>
+		</body></html>`,
+		"/blog/a":  `<html></html>`,
+		"/blog/b":  `<html></html>`,
+		"/admin/x": `<html></html>`,
+		"/about":   `<html></html>`,
+	})
+
+	opts := Options{
+		URL:         "https://example.com",
+		Depth:       2,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+		Include:     []string{`^https://example\.com/blog/`},
+		Exclude:     []string{`/b$`},
+	}
+
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	got := strings.Join(fetched(), ",")
+	if got != ",/blog/a" {
+		t.Errorf("Expected only root and /blog/a to be fetched, got %q", got)
+	}
+
+	opts.Include = []string{"(unclosed"}
+	if _, err := Analyze(context.Background(), opts); err == nil {
+		t.Error("Expected error for invalid include pattern")
+	}
+}
+
+// TestExpectContentType проверяет ошибку, если корень отдаёт не HTML
+func TestExpectContentType(t *testing.T) {
+	contentType := "application/json"
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{contentType}},
+				Body:       io.NopCloser(strings.NewReader(`{"status":"ok"}`)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:               "https://api.example.com",
+		Depth:             1,
+		Concurrency:       1,
+		HTTPClient:        mockClient,
+		ExpectContentType: "text/html",
+	}
+
+	_, err := Analyze(context.Background(), opts)
+	if err == nil || !strings.Contains(err.Error(), "application/json") {
+		t.Fatalf("Expected content type error, got %v", err)
+	}
+
+	contentType = "text/html; charset=utf-8"
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Errorf("Expected no error for matching content type, got %v", err)
+	}
+}
+
+// TestExpectContentTypeIgnoresSeeds проверяет, что не-HTML seed не срывает обход
+func TestExpectContentTypeIgnoresSeeds(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			contentType := "text/html"
+			if req.URL.Path == "/manual.pdf" {
+				contentType = "application/pdf"
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{contentType}},
+				Body:       io.NopCloser(strings.NewReader("<html></html>")),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	result, err := Analyze(context.Background(), Options{
+		URL:               "https://example.com/",
+		Depth:             1,
+		Concurrency:       1,
+		HTTPClient:        mockClient,
+		ExpectContentType: "text/html",
+		SeedURLs:          []string{"https://example.com/manual.pdf"},
+	})
+	if err != nil {
+		t.Fatalf("Expected seed content type to be ignored, got %v", err)
+	}
+
+	var rep Report
+	if err := json.Unmarshal(result, &rep); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	if len(rep.Pages) != 2 {
+		t.Errorf("expected root and seed in the report, got %d pages", len(rep.Pages))
+	}
+}
+
+// TestRedirectLoopStatus проверяет статус redirect_loop для цикла A -> B -> A
+func TestRedirectLoopStatus(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			location := "/b"
+			if req.URL.Path == "/b" {
+				location = "/a"
+			}
+			return &http.Response{
+				StatusCode: 301,
+				Header:     http.Header{"Location": []string{location}},
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:          "https://example.com/a",
+		Depth:        0,
+		Concurrency:  1,
+		MaxRedirects: 10,
+		HTTPClient:   mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	page := report.Pages[0]
+	if page.Status != "redirect_loop" {
+		t.Errorf("Expected status 'redirect_loop', got %s", page.Status)
+	}
+	if !strings.Contains(page.Error, "https://example.com/a -> https://example.com/b -> https://example.com/a") {
+		t.Errorf("Expected error to describe the loop, got %q", page.Error)
+	}
+}
+
+// TestRedirectLoopStatusNormalizedHost: цикл через другой регистр хоста
+// тоже помечается redirect_loop
+func TestRedirectLoopStatusNormalizedHost(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			location := "/b"
+			if req.URL.Path == "/b" {
+				location = "https://EXAMPLE.com/a"
+			}
+			return &http.Response{
+				StatusCode: 301,
+				Header:     http.Header{"Location": []string{location}},
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:          "https://example.com/a",
+		Depth:        0,
+		Concurrency:  1,
+		MaxRedirects: 10,
+		HTTPClient:   mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if page := report.Pages[0]; page.Status != "redirect_loop" {
+		t.Errorf("Expected status 'redirect_loop', got %s (error %q)", page.Status, page.Error)
+	}
+}
+
+// TestDryRun проверяет что в dry-run страницы запрашиваются через HEAD без тела
+func TestDryRun(t *testing.T) {
+	var mu sync.Mutex
+	methods := []string{}
+
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			methods = append(methods, req.Method)
+			mu.Unlock()
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(`<html><body><a href="/a">A</a><img src="/i.png"></body></html>`)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:         "https://example.com",
+		Depth:       3,
+		Concurrency: 1,
+		DryRun:      true,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(result, &raw); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if strings.Join(methods, ",") != "HEAD" {
+		t.Errorf("Expected a single HEAD request, got %v", methods)
+	}
+
+	pages := raw["pages"].([]interface{})
+	if len(pages) != 1 {
+		t.Fatalf("Expected 1 page, got %d", len(pages))
+	}
+
+	page := pages[0].(map[string]interface{})
+	if page["http_status"].(float64) != 200 {
+		t.Errorf("Expected http_status 200, got %v", page["http_status"])
+	}
+	if links, ok := page["broken_links"].([]interface{}); !ok || len(links) != 0 {
+		t.Errorf("Expected empty broken_links array, got %v", page["broken_links"])
+	}
+	if assets, ok := page["assets"].([]interface{}); !ok || len(assets) != 0 {
+		t.Errorf("Expected empty assets array, got %v", page["assets"])
+	}
+}
+
+// TestRecordTechStack проверяет подсчёт значений Server и X-Powered-By
+func TestRecordTechStack(t *testing.T) {
+	servers := map[string]string{"": "nginx", "/a": "nginx", "/b": "Apache"}
+
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			header := http.Header{
+				"Content-Type": []string{"text/html"},
+				"Server":       []string{servers[req.URL.Path]},
+			}
+			if req.URL.Path == "/b" {
+				header.Set("X-Powered-By", "PHP/8.2")
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(`<html><body><a href="/a">A</a><a href="/b">B</a></body></html>`)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:             "https://example.com",
+		Depth:           2,
+		Concurrency:     2,
+		HTTPClient:      mockClient,
+		RecordTechStack: true,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	expected := map[string]int{"nginx": 2, "Apache": 1, "PHP/8.2": 1}
+	if len(report.Summary.TechStack) != len(expected) {
+		t.Fatalf("Expected tech stack %v, got %v", expected, report.Summary.TechStack)
+	}
+	for value, count := range expected {
+		if report.Summary.TechStack[value] != count {
+			t.Errorf("Expected %s count %d, got %d", value, count, report.Summary.TechStack[value])
+		}
+	}
+}
+
+// TestSkipSelfLinks проверяет что ссылки на саму страницу не ставятся в очередь
+func TestSkipSelfLinks(t *testing.T) {
+	var mu sync.Mutex
+	fetched := []string{}
+
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet {
+				mu.Lock()
+				fetched = append(fetched, req.URL.RequestURI())
+				mu.Unlock()
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body: io.NopCloser(strings.NewReader(`<html><body>
+					<a href="/list#top">Top</a>
+					<a href="/list?ref=nav">Nav</a>
+					<a href="/list?page=2">Next</a>
+				</body></html>`)),
+				Request: req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:                  "https://example.com/list",
+		Depth:                1,
+		Concurrency:          1,
+		HTTPClient:           mockClient,
+		IgnoreSelfLinkParams: []string{"ref"},
+	}
+
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	sort.Strings(fetched)
+	if got := strings.Join(fetched, ","); got != "/list,/list?page=2" {
+		t.Errorf("Expected only /list and /list?page=2 to be fetched, got %q", got)
+	}
+}
+
+// TestSitemapSeeding проверяет что URL из sitemap обходятся как стартовые
+func TestSitemapSeeding(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"":   `<html></html>`,
+		"/a": `<html></html>`,
+		"/b": `<html></html>`,
+		"/sitemap.xml": `<urlset>
+			<url><loc>https://example.com/a</loc></url>
+			<url><loc>https://example.com/b</loc></url>
+			<url><loc>https://other.com/c</loc></url>
+		</urlset>`,
+	})
+
+	opts := Options{
+		URL:         "https://example.com",
+		Depth:       0,
+		Concurrency: 2,
+		HTTPClient:  mockClient,
+		SitemapURL:  "https://example.com/sitemap.xml",
+	}
+
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if got := strings.Join(fetched(), ","); got != ",/a,/b,/sitemap.xml" {
+		t.Errorf("Expected root and same-domain sitemap URLs to be fetched, got %q", got)
+	}
+}
+
+// TestSitemapSeedingDryRun проверяет, что в DryRun sitemap загружается через GET,
+// а его страницы — через HEAD
+func TestSitemapSeedingDryRun(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"":             `<html></html>`,
+		"/a":           `<html></html>`,
+		"/sitemap.xml": `<urlset><url><loc>https://example.com/a</loc></url></urlset>`,
+	})
+
+	result, err := Analyze(context.Background(), Options{
+		URL:         "https://example.com",
+		Depth:       0,
+		Concurrency: 2,
+		HTTPClient:  mockClient,
+		SitemapURL:  "https://example.com/sitemap.xml",
+		DryRun:      true,
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if got := strings.Join(fetched(), ","); got != "/sitemap.xml" {
+		t.Errorf("Expected only the sitemap to be fetched with GET, got %q", got)
+	}
+	var rep Report
+	if err := json.Unmarshal(result, &rep); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	if len(rep.Pages) != 2 {
+		t.Errorf("Expected root and sitemap page in the report, got %d pages", len(rep.Pages))
+	}
+}
+
+// TestVerifyStability проверяет что страница с меняющимся телом помечается unstable
+func TestVerifyStability(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			calls[req.URL.Path]++
+			n := calls[req.URL.Path]
+			mu.Unlock()
+
+			body := `<html><body><a href="/static">Static</a></body></html>`
+			if req.URL.Path == "/static" {
+				body = `<html><body>same</body></html>`
+			} else if n > 1 {
+				body = fmt.Sprintf(`<html><body><a href="/static">Static</a><p>%d</p></body></html>`, n)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:             "https://example.com",
+		Depth:           2,
+		Concurrency:     1,
+		HTTPClient:      mockClient,
+		VerifyStability: true,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	unstable := map[string]bool{}
+	for _, page := range report.Pages {
+		unstable[page.URL] = page.Unstable
+	}
+	if !unstable["https://example.com"] {
+		t.Errorf("Expected root page to be unstable, got %v", unstable)
+	}
+	if unstable["https://example.com/static"] {
+		t.Errorf("Expected /static to be stable")
+	}
+}
+
+// TestRecordExternalDomains проверяет список уникальных внешних доменов страницы
+func TestRecordExternalDomains(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/": `<html><body>
+			<a href="https://b.example.org/one">B1</a>
+			<a href="https://a.example.net/">A</a>
+			<a href="https://b.example.org/two">B2</a>
+			<a href="/internal">Internal</a>
+		</body></html>`,
+	})
+
+	opts := Options{
+		URL:                   "https://example.com/",
+		Depth:                 0,
+		Concurrency:           1,
+		HTTPClient:            mockClient,
+		RecordExternalDomains: true,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if len(report.Pages) != 1 {
+		t.Fatalf("Expected 1 page, got %d", len(report.Pages))
+	}
+
+	expected := []string{"a.example.net", "b.example.org"}
+	got := report.Pages[0].ExternalDomains
+	if len(got) != len(expected) {
+		t.Fatalf("Expected external domains %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected external domains %v, got %v", expected, got)
+		}
+	}
+}
+
+// TestDepthLevels проверяет что Depth N обходит ровно N уровней ссылок от корня
+func TestDepthLevels(t *testing.T) {
+	site := map[string]string{
+		"/":  `<html><body><a href="/a">A</a></body></html>`,
+		"/a": `<html><body><a href="/b">B</a></body></html>`,
+		"/b": `<html><body><a href="/c">C</a></body></html>`,
+		"/c": `<html><body>end</body></html>`,
+	}
+
+	tests := []struct {
+		depth    int
+		expected []string
+	}{
+		{depth: 0, expected: []string{"/"}},
+		{depth: 1, expected: []string{"/", "/a"}},
+		{depth: 2, expected: []string{"/", "/a", "/b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("depth %d", tt.depth), func(t *testing.T) {
+			mockClient, fetched := newSiteMock(site)
+			opts := Options{
+				URL:         "https://example.com/",
+				Depth:       tt.depth,
+				Concurrency: 1,
+				HTTPClient:  mockClient,
+			}
+
+			result, err := Analyze(context.Background(), opts)
+			if err != nil {
+				t.Fatalf("Analyze failed: %v", err)
+			}
+
+			var report Report
+			if err := json.Unmarshal(result, &report); err != nil {
+				t.Fatalf("Failed to unmarshal report: %v", err)
+			}
+
+			if got := fetched(); strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected fetched pages %v, got %v", tt.expected, got)
+			}
+			if len(report.Pages) != len(tt.expected) {
+				t.Errorf("Expected %d pages in report, got %d", len(tt.expected), len(report.Pages))
+			}
+			for _, page := range report.Pages {
+				if page.Depth > tt.depth {
+					t.Errorf("Page %s has depth %d beyond limit %d", page.URL, page.Depth, tt.depth)
+				}
+			}
+		})
+	}
+}
+
+// TestMaxConcurrentHosts проверяет что запросы одновременно идут не более чем к N хостам
+func TestMaxConcurrentHosts(t *testing.T) {
+	var mu sync.Mutex
+	active := map[string]int{}
+	maxActive := 0
+
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			active[req.URL.Host]++
+			maxActive = max(maxActive, len(active))
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			active[req.URL.Host]--
+			if active[req.URL.Host] == 0 {
+				delete(active, req.URL.Host)
+			}
+			mu.Unlock()
+
+			body := `<html><body>
+				<a href="https://a.example.org/">A</a>
+				<a href="https://b.example.org/">B</a>
+				<a href="https://c.example.org/">C</a>
+				<a href="https://d.example.org/">D</a>
+				<a href="https://e.example.org/">E</a>
+			</body></html>`
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:                "https://example.com",
+		Depth:              0,
+		Concurrency:        8,
+		HTTPClient:         mockClient,
+		MaxConcurrentHosts: 2,
+	}
+
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if maxActive > 2 {
+		t.Errorf("Expected at most 2 hosts active at once, got %d", maxActive)
+	}
+	if maxActive < 2 {
+		t.Errorf("Expected link checks to use both host slots, got %d", maxActive)
+	}
+}
+
+// TestProgressEvents проверяет события прогресса и что медленный получатель не блокирует обход
+func TestProgressEvents(t *testing.T) {
+	site := map[string]string{
+		"/":  `<html><body><a href="/a">A</a><a href="/b">B</a></body></html>`,
+		"/a": `<html><body>A</body></html>`,
+		"/b": `<html><body>B</body></html>`,
+	}
+
+	mockClient, _ := newSiteMock(site)
+	progress := make(chan ProgressEvent, 10)
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 2,
+		HTTPClient:  mockClient,
+		Progress:    progress,
+	}
+
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if len(progress) != 3 {
+		t.Fatalf("Expected 3 progress events, got %d", len(progress))
+	}
+	seen := map[int]bool{}
+	for i := 0; i < 3; i++ {
+		event := <-progress
+		if event.URL == "" {
+			t.Errorf("Expected event URL to be set: %+v", event)
+		}
+		seen[event.Processed] = true
+	}
+	for processed := 1; processed <= 3; processed++ {
+		if !seen[processed] {
+			t.Errorf("Expected an event with Processed=%d, got %v", processed, seen)
+		}
+	}
+
+	// Никто не читает небуферизованный канал — обход всё равно завершается
+	mockClient, _ = newSiteMock(site)
+	opts.HTTPClient = mockClient
+	opts.Progress = make(chan ProgressEvent)
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze with blocked progress channel failed: %v", err)
+	}
+}
+
+// TestShouldCrawlAndShouldCheckAsset проверяет пользовательские фильтры ссылок и ассетов
+func TestShouldCrawlAndShouldCheckAsset(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"/": `<html><body>
+			<a href="/docs">Docs</a>
+			<a href="/blog?utm_source=nav">Blog</a>
+			<img src="/logo.png">
+			<script src="/app.js"></script>
+		</body></html>`,
+		"/docs": `<html><body>Docs</body></html>`,
+		"/blog": `<html><body>Blog</body></html>`,
+	})
+
+	var depths []int
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+		ShouldCrawl: func(u *url.URL, depth int) bool {
+			depths = append(depths, depth)
+			return !strings.Contains(u.RawQuery, "utm_")
+		},
+		ShouldCheckAsset: func(u *url.URL, assetType string) bool {
+			return assetType != "script"
+		},
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	paths := fetched()
+	if !strings.Contains(strings.Join(paths, ","), "/docs") {
+		t.Errorf("Expected /docs to be crawled, got %v", paths)
+	}
+	for _, path := range paths {
+		if path == "/blog" {
+			t.Errorf("Expected /blog?utm_source=nav to be skipped by ShouldCrawl")
+		}
+		if path == "/app.js" {
+			t.Errorf("Expected /app.js to be skipped by ShouldCheckAsset")
+		}
+	}
+	for _, depth := range depths {
+		if depth != 1 {
+			t.Errorf("Expected ShouldCrawl to receive depth 1, got %d", depth)
+		}
+	}
+
+	for _, page := range report.Pages {
+		if page.URL != "https://example.com/" {
+			continue
+		}
+		if len(page.Assets) != 1 || page.Assets[0].Type != "image" {
+			t.Errorf("Expected only the image asset, got %+v", page.Assets)
+		}
+	}
+}
+
+// TestRedirectTarget проверяет что Location неследуемого редиректа записывается абсолютным URL
+func TestRedirectTarget(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 301,
+				Header:     http.Header{"Location": []string{"../new/page"}},
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:          "https://example.com/old/page",
+		Depth:        0,
+		Concurrency:  1,
+		MaxRedirects: 0,
+		HTTPClient:   mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if len(report.Pages) != 1 {
+		t.Fatalf("Expected 1 page, got %d", len(report.Pages))
+	}
+	page := report.Pages[0]
+	if page.Status != "redirect" || page.HTTPStatus != 301 {
+		t.Errorf("Expected redirect status 301, got %s/%d", page.Status, page.HTTPStatus)
+	}
+	if page.RedirectTarget != "https://example.com/new/page" {
+		t.Errorf("Expected redirect_target https://example.com/new/page, got %q", page.RedirectTarget)
+	}
+}
+
+// TestRelativeRedirects проверяет что относительный Location разрешается
+// относительно URL запроса, а цель редиректа классифицируется как
+// внутренняя или внешняя по разрешённому адресу
+func TestRelativeRedirects(t *testing.T) {
+	redirects := map[string]string{
+		"/docs/old":  "start",
+		"/root-rel":  "/docs/next",
+		"/elsewhere": "https://other.com/landing",
+	}
+	pages := map[string]string{
+		"/": `<html><body><a href="/docs/old">Old</a><a href="/root-rel">Root</a>` +
+			`<a href="/elsewhere">Elsewhere</a></body></html>`,
+		"/docs/start": `<html><body><a href="page">Relative to the target</a></body></html>`,
+		"/docs/next":  `<html><body>Next</body></html>`,
+		"/docs/page":  `<html><body>Page</body></html>`,
+		"/landing":    `<html><body><a href="/external-only">External</a></body></html>`,
+	}
+
+	var mu sync.Mutex
+	fetched := []string{}
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet {
+				mu.Lock()
+				fetched = append(fetched, req.URL.String())
+				mu.Unlock()
+			}
+			if location, ok := redirects[req.URL.Path]; ok && req.URL.Host == "example.com" {
+				return &http.Response{
+					StatusCode: http.StatusFound,
+					Header:     http.Header{"Location": []string{location}},
+					Body:       io.NopCloser(strings.NewReader("")),
+					Request:    req,
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(pages[req.URL.Path])),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:          "https://example.com/",
+		Depth:        2,
+		Concurrency:  1,
+		MaxRedirects: 5,
+		HTTPClient:   mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	chains := map[string]string{}
+	for _, page := range report.Pages {
+		chains[page.URL] = strings.Join(page.RedirectChain, " ")
+	}
+	expectedChains := map[string]string{
+		"https://example.com/docs/old":  "https://example.com/docs/old https://example.com/docs/start",
+		"https://example.com/root-rel":  "https://example.com/root-rel https://example.com/docs/next",
+		"https://example.com/elsewhere": "https://example.com/elsewhere https://other.com/landing",
+	}
+	for pageURL, expected := range expectedChains {
+		if chains[pageURL] != expected {
+			t.Errorf("Expected redirect chain %q for %s, got %q", expected, pageURL, chains[pageURL])
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Ссылка "page" с цели /docs/start разрешается в /docs/page и обходится,
+	// а ссылки страницы другого домена внешние и в очередь не попадают
+	if !slices.Contains(fetched, "https://example.com/docs/page") {
+		t.Errorf("Expected the link on the redirect target to resolve to /docs/page, fetched %v", fetched)
+	}
+	for _, fetchedURL := range fetched {
+		if strings.HasSuffix(fetchedURL, "/external-only") {
+			t.Errorf("Expected links of the external redirect target not to be crawled, fetched %v", fetched)
+		}
+	}
+}
+
+// TestStripParams проверяет что URL, отличающиеся только параметрами отслеживания, обходятся один раз
+func TestStripParams(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"/": `<html><body>
+			<a href="/p?utm_source=x">X</a>
+			<a href="/p?utm_source=y&fbclid=1">Y</a>
+		</body></html>`,
+		"/p": `<html><body>P</body></html>`,
+	})
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+		StripParams: DefaultStripParams,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if got := strings.Join(fetched(), ","); got != "/,/p" {
+		t.Errorf("Expected /p to be fetched once, got %s", got)
+	}
+	if len(report.Pages) != 2 || report.Pages[1].URL != "https://example.com/p" {
+		t.Errorf("Expected pages for / and /p, got %+v", report.Pages)
+	}
+}
+
+// TestCanonicalizeURL проверяет что URL с одинаковым ключом обходятся и проверяются один раз
+func TestCanonicalizeURL(t *testing.T) {
+	var mu sync.Mutex
+	requests := []string{}
+
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requests = append(requests, req.Method+" "+req.URL.RequestURI())
+			mu.Unlock()
+
+			body := `<html><body>
+				<a href="/p?page=1">1</a>
+				<a href="/p?page=2">2</a>
+				<img src="/logo.png?v=1"><img src="/logo.png?v=2">
+			</body></html>`
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:         "https://example.com",
+		Depth:       1,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+		CanonicalizeURL: func(u *url.URL) string {
+			return u.Scheme + "://" + u.Host + u.Path
+		},
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if len(report.Pages) != 2 {
+		t.Errorf("Expected root and a single /p page, got %d pages", len(report.Pages))
+	}
+
+	pageFetches, assetFetches := 0, 0
+	for _, request := range requests {
+		switch {
+		case strings.HasPrefix(request, "GET /p?"):
+			pageFetches++
+		case strings.HasPrefix(request, "GET /logo.png"):
+			assetFetches++
+		}
+	}
+	if pageFetches != 1 {
+		t.Errorf("Expected query-only page variants to be fetched once, got %d: %v", pageFetches, requests)
+	}
+	if assetFetches != 1 {
+		t.Errorf("Expected query-only asset variants to be checked once, got %d: %v", assetFetches, requests)
+	}
+}
+
+// TestVisitedDedupCaseAndQueryOrder проверяет что эквивалентные URL занимают одну запись в Visited
+func TestVisitedDedupCaseAndQueryOrder(t *testing.T) {
+	links := []string{
+		"https://EXAMPLE.com/about",
+		"https://example.com/About",
+		"https://example.com/about",
+		"https://example.com/p?a=1&b=2",
+		"https://example.com/p?b=2&a=1",
+	}
+
+	tests := []struct {
+		name                 string
+		caseInsensitivePaths bool
+		expected             int
+	}{
+		{name: "case-sensitive paths", caseInsensitivePaths: false, expected: 3},
+		{name: "case-insensitive paths", caseInsensitivePaths: true, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, _ := url.Parse("https://example.com")
+			c := &Crawler{
+				state: state.NewCrawlState(root, 1, nil),
+				opts:  Options{CaseInsensitivePaths: tt.caseInsensitivePaths},
+			}
+			c.state.Queue.Dequeue() // корень не нужен
+
+			c.enqueueInternalLinks(links, nil, 1)
+			for item := c.state.Queue.Dequeue(); item != nil; item = c.state.Queue.Dequeue() {
+				c.state.Visited.Add(c.urlKeyString(item.URL))
+			}
+
+			if got := c.state.Visited.Len(); got != tt.expected {
+				t.Errorf("Expected %d visited URLs, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestParseTimeRecorded проверяет что время разбора HTML записывается для страницы и в сводке
+func TestParseTimeRecorded(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/": `<html><head><title>T</title></head><body><a href="/a">A</a></body></html>`,
+	})
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       0,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var raw struct {
+		Summary map[string]json.RawMessage   `json:"summary"`
+		Pages   []map[string]json.RawMessage `json:"pages"`
+	}
+	if err := json.Unmarshal(result, &raw); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if len(raw.Pages) != 1 {
+		t.Fatalf("Expected 1 page, got %d", len(raw.Pages))
+	}
+
+	var pageTime, totalTime int64
+	if err := json.Unmarshal(raw.Pages[0]["parse_time_ms"], &pageTime); err != nil {
+		t.Fatalf("Expected parse_time_ms on page: %v", err)
+	}
+	if err := json.Unmarshal(raw.Summary["parse_time_ms"], &totalTime); err != nil {
+		t.Fatalf("Expected parse_time_ms in summary: %v", err)
+	}
+	if pageTime < 0 || totalTime != pageTime {
+		t.Errorf("Expected non-negative parse time matching the summary, got page %d, summary %d", pageTime, totalTime)
+	}
+}
+
+// TestDetectSessionIDs проверяет что URL с jsessionid совпадает с чистым URL и помечается
+func TestDetectSessionIDs(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"/": `<html><body>
+			<a href="/p">P</a>
+			<a href="/p?JSESSIONID=XYZ">P with session</a>
+			<a href="/q">Q</a>
+		</body></html>`,
+		"/p": `<html><body>P</body></html>`,
+		"/q": `<html><body>Q</body></html>`,
+	})
+
+	opts := Options{
+		URL:              "https://example.com/",
+		Depth:            1,
+		Concurrency:      1,
+		HTTPClient:       mockClient,
+		DetectSessionIDs: true,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if got := strings.Join(fetched(), ","); got != "/,/p,/q" {
+		t.Errorf("Expected /p to be fetched once without the session id, got %s", got)
+	}
+
+	flagged := map[string]bool{}
+	for _, page := range report.Pages {
+		flagged[page.URL] = page.SessionIDInURL
+	}
+	if len(flagged) != 3 {
+		t.Fatalf("Expected 3 pages, got %v", flagged)
+	}
+	if !flagged["https://example.com/p"] {
+		t.Errorf("Expected /p to be flagged with session_id_in_url")
+	}
+	if flagged["https://example.com/q"] || flagged["https://example.com"] {
+		t.Errorf("Expected only /p to be flagged, got %v", flagged)
+	}
+}
+
+func TestMaxRequests(t *testing.T) {
+	site := map[string]string{}
+	var links strings.Builder
+	for i := 0; i < 10; i++ {
+		path := fmt.Sprintf("/p%d", i)
+		links.WriteString(fmt.Sprintf(`<a href="%s">%s</a>`, path, path))
+		site[path] = `<html><body>page</body></html>`
+	}
+	site["/"] = "<html><body>" + links.String() + "</body></html>"
+	mockClient, fetched := newSiteMock(site)
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+		MaxRequests: 3,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if report.StopReason != StopReasonMaxRequests {
+		t.Errorf("Expected stop_reason %q, got %q", StopReasonMaxRequests, report.StopReason)
+	}
+	if report.Summary.TotalRequests > 3 {
+		t.Errorf("Expected at most 3 requests, got %d", report.Summary.TotalRequests)
+	}
+	if n := len(fetched()); n > 3 {
+		t.Errorf("Expected at most 3 fetched URLs, got %d", n)
+	}
+	for _, page := range report.Pages {
+		if page.Error != "" {
+			t.Errorf("Expected pages cut off by the limit to be omitted, got %s: %s", page.URL, page.Error)
+		}
+		// Ссылки, которые не успели проверить до лимита, не битые
+		if len(page.BrokenLinks) != 0 {
+			t.Errorf("Expected no broken links on %s, got %+v", page.URL, page.BrokenLinks)
+		}
+	}
+}
+
+func TestPathPrefix(t *testing.T) {
+	site := map[string]string{
+		"/docs/": `<html><body>
+			<a href="/docs/intro">Intro</a>
+			<a href="/docs">Docs without slash</a>
+			<a href="/blog/post">Blog</a>
+			<a href="/">Home</a>
+		</body></html>`,
+		"/docs/intro": `<html><body><a href="/about">About</a></body></html>`,
+		"/docs":       `<html><body>Docs</body></html>`,
+		"/blog/post":  `<html><body>Post</body></html>`,
+		"/about":      `<html><body>About</body></html>`,
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "explicit prefix", opts: Options{URL: "https://example.com/docs/", PathPrefix: "/docs/"}},
+		{name: "seed path", opts: Options{URL: "https://example.com/docs/", ConfineToSeedPath: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient, fetched := newSiteMock(site)
+			opts := tt.opts
+			opts.Depth = 2
+			opts.Concurrency = 1
+			opts.HTTPClient = mockClient
+
+			result, err := Analyze(context.Background(), opts)
+			if err != nil {
+				t.Fatalf("Analyze failed: %v", err)
+			}
+
+			var report Report
+			if err := json.Unmarshal(result, &report); err != nil {
+				t.Fatalf("Failed to unmarshal report: %v", err)
+			}
+
+			if got := strings.Join(fetched(), ","); got != "/docs/,/docs/intro" {
+				t.Errorf("Expected only pages under /docs/ to be fetched, got %s", got)
+			}
+			for _, page := range report.Pages {
+				if !strings.HasPrefix(page.URL, "https://example.com/docs/") {
+					t.Errorf("Expected sibling path %s to be excluded from the report", page.URL)
+				}
+			}
+		})
+	}
+}
+
+func TestSeedPathPrefix(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com":            "/",
+		"https://example.com/":           "/",
+		"https://example.com/docs/":      "/docs/",
+		"https://example.com/docs/intro": "/docs/",
+		"https://example.com/docs":       "/",
+	}
+
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		if got := seedPathPrefix(u); got != want {
+			t.Errorf("seedPathPrefix(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestTotalRedirects(t *testing.T) {
+	redirects := map[string]string{
+		"/old":     "/new",
+		"/moved":   "/new",
+		"/old.png": "/new.png",
+	}
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if location, ok := redirects[req.URL.Path]; ok {
+				return &http.Response{
+					StatusCode: http.StatusMovedPermanently,
+					Header:     http.Header{"Location": []string{location}},
+					Body:       io.NopCloser(strings.NewReader("")),
+					Request:    req,
+				}, nil
+			}
+			body := `<html><body><a href="/old">Old</a><a href="/moved">Moved</a><img src="/old.png"></body></html>`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       0,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	// Проверка ссылок /old и /moved и ассета /old.png — по одному 3xx
+	if report.Summary.TotalRedirects != 3 {
+		t.Errorf("Expected total_redirects 3, got %d", report.Summary.TotalRedirects)
+	}
+}
+
+func TestContentTypeAndCharset(t *testing.T) {
+	// "Привет" в windows-1251
+	title := string([]byte{0xcf, 0xf0, 0xe8, 0xe2, 0xe5, 0xf2})
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html; charset=windows-1251"}},
+				Body:       io.NopCloser(strings.NewReader("<html><head><title>" + title + "</title></head><body></body></html>")),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:         "https://example.com",
+		Depth:       0,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	page := report.Pages[0]
+	if page.ContentType != "text/html; charset=windows-1251" {
+		t.Errorf("Expected content_type from the response header, got %q", page.ContentType)
+	}
+	if page.Charset != "windows-1251" {
+		t.Errorf("Expected charset windows-1251, got %q", page.Charset)
+	}
+	if page.SEO.Title != "Привет" {
+		t.Errorf("Expected title transcoded to UTF-8, got %q", page.SEO.Title)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/":       `<html><head><title>Big</title></head><body>` + strings.Repeat("<p>filler</p>", 1000) + `<a href="/hidden">Hidden</a></body></html>`,
+		"/hidden": `<html><body>Hidden</body></html>`,
+	})
+
+	opts := Options{
+		URL:          "https://example.com/",
+		Depth:        1,
+		Concurrency:  1,
+		HTTPClient:   mockClient,
+		MaxBodyBytes: 1024,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if len(report.Pages) != 1 {
+		t.Fatalf("Expected the link past the cap to be ignored, got %d pages", len(report.Pages))
+	}
+	page := report.Pages[0]
+	if !page.Truncated {
+		t.Errorf("Expected page to be flagged as truncated")
+	}
+	if page.SEO.Title != "Big" {
+		t.Errorf("Expected title from the capped prefix, got %q", page.SEO.Title)
+	}
+}
+
+func TestArchiveDir(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/":  `<html><body><a href="/a">A</a></body></html>`,
+		"/a": `<html><body>A</body></html>`,
+	})
+
+	dir := t.TempDir()
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 2,
+		HTTPClient:  mockClient,
+		ArchiveDir:  dir,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if len(report.Pages) != 2 {
+		t.Fatalf("Expected 2 pages, got %d", len(report.Pages))
+	}
+	for _, page := range report.Pages {
+		if page.ArchivePath == "" {
+			t.Fatalf("Expected archive_path for %s", page.URL)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, page.ArchivePath))
+		if err != nil {
+			t.Fatalf("Failed to read archived page %s: %v", page.URL, err)
+		}
+		if !strings.Contains(string(content), "<body>") {
+			t.Errorf("Expected archived HTML for %s, got %q", page.URL, content)
+		}
+	}
+}
+
+func TestArchiveDirKeepsRawBody(t *testing.T) {
+	// "Привет" в windows-1251, дальше тело обрезается MaxBodyBytes
+	body := "<html><head><title>" + string([]byte{0xcf, 0xf0, 0xe8, 0xe2, 0xe5, 0xf2}) + "</title></head><body>" +
+		strings.Repeat("<p>filler</p>", 100) + "</body></html>"
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html; charset=windows-1251"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	dir := t.TempDir()
+	opts := Options{
+		URL:          "https://example.com/",
+		Depth:        0,
+		Concurrency:  1,
+		HTTPClient:   mockClient,
+		MaxBodyBytes: 256,
+		ArchiveDir:   dir,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	page := report.Pages[0]
+	if !page.Truncated || page.ArchivePath == "" {
+		t.Fatalf("Expected a truncated archived page, got truncated=%v archive_path=%q", page.Truncated, page.ArchivePath)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, page.ArchivePath))
+	if err != nil {
+		t.Fatalf("Failed to read archived page: %v", err)
+	}
+	if string(content) != body[:256] {
+		t.Errorf("Expected the first 256 raw bytes in the archive, got %q", content)
+	}
+}
+
+func TestArchiveDirWriteError(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/": `<html><body>Root</body></html>`,
+	})
+
+	// На месте каталога архива — файл, поэтому запись не удаётся
+	file := filepath.Join(t.TempDir(), "archive")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       0,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+		ArchiveDir:  file,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Expected archive errors not to abort the crawl, got %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	page := report.Pages[0]
+	if page.Status != "ok" || page.Error != "" {
+		t.Errorf("Expected page to stay ok, got status %s error %q", page.Status, page.Error)
+	}
+	if page.ArchivePath != "" || len(page.Warnings) != 1 {
+		t.Errorf("Expected one archive warning and no path, got %q %v", page.ArchivePath, page.Warnings)
+	}
+}
+
+func TestReportStats(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"/":  `<html><body><a href="/a">A</a><a href="/missing">Missing</a><img src="/logo.png"></body></html>`,
+		"/a": `<html><body>A</body></html>`,
+	})
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	stats := report.Stats
+	if stats == nil {
+		t.Fatal("Expected stats in report")
+	}
+	requests := report.Summary.TotalRequests
+	// GET: /, /a, /missing (страница 404), /logo.png (ассет); плюс проверки ссылок
+	if n := int64(len(fetched())); requests < n {
+		t.Errorf("Expected at least %d requests, got %d", n, requests)
+	}
+
+	var byClass int64
+	for _, n := range stats.StatusClasses {
+		byClass += n
+	}
+	if byClass != requests {
+		t.Errorf("Expected every request to be counted by status class, got %v for %d requests", stats.StatusClasses, requests)
+	}
+	if stats.StatusClasses["4xx"] == 0 || stats.StatusClasses["2xx"] == 0 {
+		t.Errorf("Expected 2xx and 4xx responses, got %v", stats.StatusClasses)
+	}
+	if stats.BytesRead == 0 {
+		t.Errorf("Expected bytes_read to be counted")
+	}
+}
+
+func TestDedupeByContent(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"/": `<html><body>
+			<a href="/x/">X</a>
+			<a href="/y/">Y</a>
+		</body></html>`,
+		// Одинаковое содержимое, отличается только форматирование;
+		// относительная ссылка разрешается по-разному
+		"/x/":      "<html><body><a href=\"child\">Child</a></body></html>",
+		"/y/":      "<html>\n  <body>\n    <a href=\"child\">Child</a>\n  </body>\n</html>",
+		"/x/child": `<html><body>Child</body></html>`,
+		"/y/child": `<html><body>Child</body></html>`,
+	})
+
+	opts := Options{
+		URL:             "https://example.com/",
+		Depth:           2,
+		Concurrency:     1,
+		HTTPClient:      mockClient,
+		DedupeByContent: true,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	pages := map[string]Page{}
+	for _, page := range report.Pages {
+		pages[page.URL] = page
+	}
+
+	dup := pages["https://example.com/y/"]
+	if dup.Status != "duplicate" || dup.CanonicalOf != "https://example.com/x/" {
+		t.Errorf("Expected /y/ to be a duplicate of /x/, got status %q canonical_of %q", dup.Status, dup.CanonicalOf)
+	}
+	if first := pages["https://example.com/x/"]; first.Status != "ok" || first.CanonicalOf != "" {
+		t.Errorf("Expected /x/ to stay ok, got status %q canonical_of %q", first.Status, first.CanonicalOf)
+	}
+
+	for _, path := range fetched() {
+		if path == "/y/child" {
+			t.Errorf("Expected links of the duplicate page not to be crawled")
+		}
+	}
+	if pages["https://example.com/x/child"].Status != "ok" {
+		t.Errorf("Expected links of the first page to be crawled")
+	}
+	if report.Summary.ErrorPages != 0 {
+		t.Errorf("Expected duplicates not to count as error pages, got %d", report.Summary.ErrorPages)
+	}
+}
+
+func TestIncludeSubdomains(t *testing.T) {
+	var mu sync.Mutex
+	fetchedHosts := map[string]bool{}
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet {
+				mu.Lock()
+				fetchedHosts[req.URL.Host] = true
+				mu.Unlock()
+			}
+			body := `<html><body>
+				<a href="https://blog.example.com/">Blog</a>
+				<a href="https://shop.example.com/">Shop</a>
+				<a href="https://example.org/">Other site</a>
+			</body></html>`
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	for _, include := range []bool{false, true} {
+		fetchedHosts = map[string]bool{}
+		opts := Options{
+			URL:                   "https://www.example.com/",
+			Depth:                 1,
+			Concurrency:           1,
+			HTTPClient:            mockClient,
+			IncludeSubdomains:     include,
+			RecordExternalDomains: true,
+		}
+
+		result, err := Analyze(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+
+		var report Report
+		if err := json.Unmarshal(result, &report); err != nil {
+			t.Fatalf("Failed to unmarshal report: %v", err)
+		}
+
+		if fetchedHosts["blog.example.com"] != include || fetchedHosts["shop.example.com"] != include {
+			t.Errorf("IncludeSubdomains=%v: unexpected crawled hosts %v", include, fetchedHosts)
+		}
+		if fetchedHosts["example.org"] {
+			t.Errorf("IncludeSubdomains=%v: expected other sites not to be crawled", include)
+		}
+
+		var root Page
+		for _, page := range report.Pages {
+			if page.Depth == 0 {
+				root = page
+			}
+		}
+		expected := "blog.example.com,example.org,shop.example.com"
+		if include {
+			expected = "example.org"
+		}
+		if got := strings.Join(root.ExternalDomains, ","); got != expected {
+			t.Errorf("IncludeSubdomains=%v: expected external domains %s, got %s", include, expected, got)
+		}
+	}
+}
+
+func TestAnalyzeTo(t *testing.T) {
+	for _, indent := range []bool{false, true} {
+		mockClient, _ := newSiteMock(map[string]string{
+			"/":  `<html><head><title>Root</title></head><body><a href="/a">A</a></body></html>`,
+			"/a": `<html><body>A</body></html>`,
+		})
+		opts := Options{
+			URL:         "https://example.com/",
+			Depth:       1,
+			Concurrency: 1,
+			HTTPClient:  mockClient,
+			IndentJSON:  indent,
+		}
+
+		var buf strings.Builder
+		if err := AnalyzeTo(context.Background(), opts, &buf); err != nil {
+			t.Fatalf("AnalyzeTo failed: %v", err)
+		}
+
+		var report Report
+		if err := json.Unmarshal([]byte(buf.String()), &report); err != nil {
+			t.Fatalf("Failed to unmarshal streamed report: %v", err)
+		}
+		if len(report.Pages) != 2 || report.Summary.TotalPages != 2 {
+			t.Errorf("Expected 2 pages with summary, got %d pages, total_pages %d", len(report.Pages), report.Summary.TotalPages)
+		}
+		if got := strings.Contains(buf.String(), "\n  \"root_url\""); got != indent {
+			t.Errorf("IndentJSON=%v: unexpected indentation in %q", indent, buf.String())
+		}
+	}
+}
+
+func TestAnalyzeToInvalidURL(t *testing.T) {
+	var buf strings.Builder
+	if err := AnalyzeTo(context.Background(), Options{URL: "://bad"}, &buf); err == nil {
+		t.Fatal("Expected error for invalid URL")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing written on error, got %q", buf.String())
+	}
+}
+
+func TestSeedURLs(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"/":       `<html><body><a href="/a">A</a></body></html>`,
+		"/a":      `<html><body>A</body></html>`,
+		"/orphan": `<html><body><a href="/orphan/child">Child</a></body></html>`,
+		"/island": `<html><body>Island</body></html>`,
+	})
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       0,
+		Concurrency: 2,
+		HTTPClient:  mockClient,
+		// /a и корень повторяются — обходятся один раз
+		SeedURLs: []string{"https://example.com/orphan", "https://EXAMPLE.com/island", "https://example.com/a", "https://example.com/a#top", "https://example.com"},
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if got := strings.Join(fetched(), ","); got != "/,/a,/island,/orphan" {
+		t.Errorf("Expected every seed fetched once at depth 0, got %s", got)
+	}
+	for _, page := range report.Pages {
+		if page.Depth != 0 {
+			t.Errorf("Expected seed %s at depth 0, got %d", page.URL, page.Depth)
+		}
+	}
+}
+
+func TestSeedURLsOtherHost(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{"/": `<html></html>`})
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+		SeedURLs:    []string{"https://example.com/a", "https://other.com/"},
+	}
+
+	_, err := Analyze(context.Background(), opts)
+	if err == nil || !strings.Contains(err.Error(), "other.com") {
+		t.Fatalf("Expected error naming the foreign seed, got %v", err)
+	}
+	if len(fetched()) != 0 {
+		t.Errorf("Expected nothing fetched, got %v", fetched())
+	}
+}
+
+// TestCancelFlushesInFlightPages: отмена контекста посреди обхода не теряет
+// страницу, которая уже загружается, и не запускает новые
+func TestCancelFlushesInFlightPages(t *testing.T) {
+	siteClient, fetched := newSiteMock(map[string]string{
+		"/":      `<html><body><a href="/a">A</a><a href="/slow">Slow</a></body></html>`,
+		"/a":     `<html><body>A</body></html>`,
+		"/slow":  `<html><head><title>Slow</title></head><body><a href="/never">Never</a></body></html>`,
+		"/never": `<html><body>Never</body></html>`,
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet && req.URL.Path == "/slow" {
+				close(started)
+				<-release
+			}
+			return siteClient.Do(req)
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// Отменяем, пока /slow в работе, и только потом отдаём ответ
+		<-started
+		cancel()
+		close(release)
+	}()
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       2,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(ctx, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if report.StopReason != StopReasonCanceled {
+		t.Errorf("Expected stop_reason %q, got %q", StopReasonCanceled, report.StopReason)
+	}
+
+	pages := map[string]Page{}
+	for _, page := range report.Pages {
+		pages[page.URL] = page
+	}
+	for _, path := range []string{"/", "/a", "/slow"} {
+		page, ok := pages["https://example.com"+path]
+		if !ok {
+			t.Errorf("Expected page %s completed before cancellation in the report", path)
+			continue
+		}
+		if page.Status != "ok" || page.Error != "" {
+			t.Errorf("Expected %s to be ok, got status %q error %q", path, page.Status, page.Error)
+		}
+	}
+	if page := pages["https://example.com/slow"]; page.SEO == nil || page.SEO.Title != "Slow" {
+		t.Errorf("Expected in-flight page to be fully processed, got %+v", page.SEO)
+	}
+
+	if _, ok := pages["https://example.com/never"]; ok {
+		t.Error("Expected no new pages to be started after cancellation")
+	}
+	for _, path := range fetched() {
+		if path == "/never" {
+			t.Error("Expected /never not to be fetched after cancellation")
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/":      `<html><body><a href="/a">A</a><a href="/admin">Admin</a><a href="https://other.com/">Other</a></body></html>`,
+		"/a":     `<html><body>A</body></html>`,
+		"/admin": `<html><body>Admin</body></html>`,
+	})
+
+	var logs bytes.Buffer
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 4,
+		HTTPClient:  mockClient,
+		Exclude:     []string{"/admin"},
+		Logger:      slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	type event struct {
+		Msg    string `json:"msg"`
+		URL    string `json:"url"`
+		Reason string `json:"reason"`
+		Status int    `json:"status"`
+	}
+	seen := map[event]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var e event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		seen[e] = true
+	}
+
+	for _, want := range []event{
+		{Msg: "url dequeued", URL: "https://example.com/a"},
+		{Msg: "page fetched", URL: "https://example.com/a", Status: 200},
+		{Msg: "link enqueued", URL: "https://example.com/a"},
+		{Msg: "link skipped", URL: "https://example.com/admin", Reason: "filtered"},
+		{Msg: "link skipped", URL: "https://other.com", Reason: "external"},
+		{Msg: "link checked", URL: "https://example.com/admin", Status: 200},
+	} {
+		if !seen[want] {
+			t.Errorf("expected log event %+v, got:\n%s", want, logs.String())
+		}
+	}
+}
+
+func TestLogSkippedLinkWithoutLoggerDoesNotAllocate(t *testing.T) {
+	c := &Crawler{}
+	allocs := testing.AllocsPerRun(100, func() {
+		c.logSkippedLink("https://example.com/a", "filtered")
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations without a logger, got %v", allocs)
+	}
+}
+
+func TestMixedContent(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/": `<html><body>
+			<img src="http://example.com/logo.png">
+			<img src="/secure.png">
+			<a href="http://partner.com/">Partner</a>
+			<a href="/about">About</a>
+		</body></html>`,
+	})
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       0,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	page := report.Pages[0]
+	for _, asset := range page.Assets {
+		want := asset.URL == "http://example.com/logo.png"
+		if asset.MixedContent != want {
+			t.Errorf("Expected mixed_content=%v for %s", want, asset.URL)
+		}
+	}
+	if page.SEO.MixedContentCount != 2 {
+		t.Errorf("Expected 2 mixed content resources (image and link), got %d", page.SEO.MixedContentCount)
+	}
+}
+
+func TestHeadFirst(t *testing.T) {
+	var mu sync.Mutex
+	requests := []string{}
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requests = append(requests, req.Method+" "+req.URL.Path)
+			mu.Unlock()
+
+			header := http.Header{"Content-Type": []string{"text/html"}}
+			body := `<html><body>page</body></html>`
+			contentLength := int64(len(body))
+			switch req.URL.Path {
+			case "/":
+				body = `<html><body><a href="/doc.pdf">PDF</a><a href="/feed.xml">Feed</a><a href="/huge">Huge</a><a href="/nohead">No HEAD</a></body></html>`
+				contentLength = int64(len(body))
+			case "/feed.xml":
+				header.Set("Content-Type", "application/rss+xml; charset=utf-8")
+			case "/doc.pdf":
+				header.Set("Content-Type", "application/pdf")
+				contentLength = 5 << 20
+			case "/huge":
+				contentLength = 1 << 20
+			case "/nohead":
+				if req.Method == http.MethodHead {
+					return &http.Response{StatusCode: http.StatusMethodNotAllowed, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+				}
+			}
+			if req.Method == http.MethodHead {
+				body = ""
+			}
+			return &http.Response{
+				StatusCode:    200,
+				Header:        header,
+				ContentLength: contentLength,
+				Body:          io.NopCloser(strings.NewReader(body)),
+				Request:       req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:          "https://example.com/",
+		Depth:        1,
+		Concurrency:  1,
+		HTTPClient:   mockClient,
+		HeadFirst:    true,
+		MaxBodyBytes: 64 << 10,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	pages := map[string]Page{}
+	for _, page := range report.Pages {
+		pages[page.URL] = page
+	}
+	for _, tt := range []struct {
+		url      string
+		headOnly bool
+	}{
+		{url: "https://example.com/", headOnly: false},
+		{url: "https://example.com/doc.pdf", headOnly: true},
+		{url: "https://example.com/feed.xml", headOnly: true},
+		{url: "https://example.com/huge", headOnly: true},
+		{url: "https://example.com/nohead", headOnly: false},
+	} {
+		page, ok := pages[tt.url]
+		if !ok {
+			t.Errorf("Expected page %s in report", tt.url)
+			continue
+		}
+		if page.HeadOnly != tt.headOnly || page.Status != "ok" || page.HTTPStatus != 200 {
+			t.Errorf("%s: expected head_only=%v with status ok 200, got head_only=%v %s %d",
+				tt.url, tt.headOnly, page.HeadOnly, page.Status, page.HTTPStatus)
+		}
+	}
+
+	gets := map[string]bool{}
+	for _, r := range requests {
+		if path, ok := strings.CutPrefix(r, "GET "); ok {
+			gets[path] = true
+		}
+	}
+	if gets["/doc.pdf"] || gets["/feed.xml"] || gets["/huge"] {
+		t.Errorf("Expected no GET for non-HTML or oversized pages, got %v", requests)
+	}
+	if !gets["/"] || !gets["/nohead"] {
+		t.Errorf("Expected GET for HTML pages and fallback when HEAD is unsupported, got %v", requests)
+	}
+}
+
+func TestCrawlerRunTwice(t *testing.T) {
+	var mu sync.Mutex
+	root := `<html><body><a href="/a">A</a></body></html>`
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			body := root
+			mu.Unlock()
+			if req.URL.Path != "/" {
+				body = `<html><body><img src="/logo.png"></body></html>`
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	c, err := NewCrawler(Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 2,
+		HTTPClient:  mockClient,
+	})
+	if err != nil {
+		t.Fatalf("NewCrawler failed: %v", err)
+	}
+
+	first, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("first Run failed: %v", err)
+	}
+
+	// Между запусками сайт изменился: /a удалена, появилась /b
+	mu.Lock()
+	root = `<html><body><a href="/b">B</a></body></html>`
+	mu.Unlock()
+
+	second, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+
+	urls := func(r *Report) string {
+		var list []string
+		for _, page := range r.Pages {
+			list = append(list, page.URL)
+		}
+		return strings.Join(list, ",")
+	}
+	if got := urls(first); got != "https://example.com/,https://example.com/a" {
+		t.Errorf("unexpected first run pages: %s", got)
+	}
+	if got := urls(second); got != "https://example.com/,https://example.com/b" {
+		t.Errorf("expected second run to contain only its own pages, got %s", got)
+	}
+	if first.Summary.TotalPages != 2 || second.Summary.TotalPages != 2 {
+		t.Errorf("expected 2 pages per run, got %d and %d", first.Summary.TotalPages, second.Summary.TotalPages)
+	}
+
+	// Счётчики и кэш ассетов не переносятся: ассет /logo.png проверяется заново
+	if first.Summary.TotalRequests != second.Summary.TotalRequests {
+		t.Errorf("expected equal request counts per run, got %d and %d",
+			first.Summary.TotalRequests, second.Summary.TotalRequests)
+	}
+}
+
+func TestNewCrawlerInvalidOptions(t *testing.T) {
+	if _, err := NewCrawler(Options{URL: "://bad"}); err == nil {
+		t.Error("expected error for invalid URL")
+	}
+	if _, err := NewCrawler(Options{URL: "https://example.com", Include: []string{"("}}); err == nil {
+		t.Error("expected error for invalid include pattern")
+	}
+	if _, err := NewCrawler(Options{URL: "https://example.com", SortAssets: "size"}); err == nil {
+		t.Error("expected error for unknown asset order")
+	}
+	if _, err := NewCrawler(Options{URL: "https://example.com", AllowedHosts: []string{"[a-"}}); err == nil {
+		t.Error("expected error for invalid allowed host pattern")
+	}
+	if _, err := NewCrawler(Options{URL: "https://example.com", Proxy: "ftp://proxy.local"}); err == nil {
+		t.Error("expected error for unsupported proxy scheme")
+	}
+}
+
+// memoryConditionalStore — ConditionalStore в памяти для тестов
+type memoryConditionalStore struct {
+	mu         sync.Mutex
+	validators map[string][2]string
+}
+
+func (s *memoryConditionalStore) Get(url string) (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := s.validators[url]
+	return v[0], v[1]
+}
+
+func (s *memoryConditionalStore) Set(url, etag, lastModified string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validators[url] = [2]string{etag, lastModified}
+}
+
+// conditionalSiteMock отвечает 304, если If-None-Match совпадает с ETag страницы
+func conditionalSiteMock(pages map[string]string) *MockHTTPClient {
+	return &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			body, ok := pages[req.URL.Path]
+			if !ok {
+				return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			}
+			etag := fmt.Sprintf(`"%x"`, len(body))
+			if req.Header.Get("If-None-Match") == etag {
+				return &http.Response{StatusCode: http.StatusNotModified, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header: http.Header{
+					"Content-Type":  []string{"text/html"},
+					"Etag":          []string{etag},
+					"Last-Modified": []string{"Mon, 01 Jan 2024 00:00:00 GMT"},
+				},
+				Body:    io.NopCloser(strings.NewReader(body)),
+				Request: req,
+			}, nil
+		},
+	}
+}
+
+func TestConditionalRecrawl(t *testing.T) {
+	store := &memoryConditionalStore{validators: map[string][2]string{}}
+	c, err := NewCrawler(Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 2,
+		HTTPClient: conditionalSiteMock(map[string]string{
+			"/":  `<html><head><title>Home</title></head><body><a href="/a">A</a></body></html>`,
+			"/a": `<html><head><title>A</title></head><body>A</body></html>`,
+		}),
+		ConditionalStore: store,
+	})
+	if err != nil {
+		t.Fatalf("NewCrawler failed: %v", err)
+	}
+
+	first, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("first Run failed: %v", err)
+	}
+	for _, page := range first.Pages {
+		if page.Status != "ok" || page.ETag == "" || page.LastModified == "" {
+			t.Errorf("expected ok page with validators, got %s %s etag=%q", page.URL, page.Status, page.ETag)
+		}
+		if etag, _ := store.Get(page.URL); etag != page.ETag {
+			t.Errorf("expected ETag of %s to be stored, got %q", page.URL, etag)
+		}
+	}
+
+	second, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+	if len(second.Pages) != 2 {
+		t.Fatalf("expected links of unchanged root to be followed, got %d pages", len(second.Pages))
+	}
+	for i, page := range second.Pages {
+		if page.Status != report.StatusNotModified || page.HTTPStatus != http.StatusNotModified {
+			t.Errorf("expected %s to be not_modified, got %s %d", page.URL, page.Status, page.HTTPStatus)
+		}
+		if page.SEO == nil || page.SEO.Title != first.Pages[i].SEO.Title || page.ETag != first.Pages[i].ETag {
+			t.Errorf("expected %s to reuse data of the previous run, got %+v", page.URL, page.SEO)
+		}
+	}
+	if second.Summary.ErrorPages != 0 {
+		t.Errorf("expected not_modified pages not to count as errors, got %d", second.Summary.ErrorPages)
+	}
+}
+
+// TestConditionalAcrossAnalyzeCalls проверяет, что новый Analyze с тем же
+// хранилищем не останавливается на корне: данных прошлого обхода у него нет
+func TestConditionalAcrossAnalyzeCalls(t *testing.T) {
+	store := &memoryConditionalStore{validators: map[string][2]string{}}
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 1,
+		HTTPClient: conditionalSiteMock(map[string]string{
+			"/":  `<html><body><a href="/a">A</a></body></html>`,
+			"/a": `<html><body>A</body></html>`,
+		}),
+		ConditionalStore: store,
+	}
+
+	for run := 1; run <= 2; run++ {
+		result, err := Analyze(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("run %d: Analyze failed: %v", run, err)
+		}
+
+		var rep Report
+		if err := json.Unmarshal(result, &rep); err != nil {
+			t.Fatalf("run %d: Failed to unmarshal report: %v", run, err)
+		}
+		if len(rep.Pages) != 2 {
+			t.Fatalf("run %d: expected root and /a, got %d pages", run, len(rep.Pages))
+		}
+		for _, page := range rep.Pages {
+			if page.Status != "ok" || page.ETag == "" {
+				t.Errorf("run %d: expected fully fetched %s, got %s etag=%q", run, page.URL, page.Status, page.ETag)
+			}
+		}
+	}
+}
+
+func TestPaginationLinksFollowed(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"/":       `<html><head><link rel="stylesheet" href="/style.css"><link rel="next" href="/page/2"></head><body>1</body></html>`,
+		"/page/2": `<html><head><link rel="prev" href="/"><link rel="next" href="/page/3"></head><body>2</body></html>`,
+		"/page/3": `<html><head><link rel="prev" href="/page/2"></head><body>3</body></html>`,
+	})
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       5,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	got := strings.Join(fetched(), ",")
+	if !strings.Contains(got, "/page/2") || !strings.Contains(got, "/page/3") {
+		t.Errorf("expected all paginated pages to be crawled, got %s", got)
+	}
+}
+
+func TestFoundOn(t *testing.T) {
+	shared := `<a href="/missing">Missing</a><img src="/missing.png">`
+	mockClient, _ := newSiteMock(map[string]string{
+		"/":  `<html><body><a href="/a">A</a>` + shared + `</body></html>`,
+		"/a": `<html><body>` + shared + `</body></html>`,
+	})
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	// Ассет с обеих страниц проверяется один раз, но ссылается каждая на себя
+	checked := 0
+	for _, page := range report.Pages {
+		if page.Status != "ok" {
+			continue
+		}
+		checked++
+		if len(page.BrokenLinks) != 1 || page.BrokenLinks[0].FoundOn != page.URL {
+			t.Errorf("expected broken link found on %s, got %+v", page.URL, page.BrokenLinks)
+		}
+		if len(page.Assets) != 1 || page.Assets[0].FoundOn != page.URL {
+			t.Errorf("expected asset found on %s, got %+v", page.URL, page.Assets)
+		}
+	}
+	if checked != 2 {
+		t.Errorf("expected 2 ok pages, got %d", checked)
+	}
+}
+
+func TestIsBrokenStatus(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/":
+				return &http.Response{
+					StatusCode: 200,
+					Header:     http.Header{"Content-Type": []string{"text/html"}},
+					Body:       io.NopCloser(strings.NewReader(`<html><body><a href="https://other.com/admin">Admin</a><a href="https://other.com/gone">Gone</a></body></html>`)),
+					Request:    req,
+				}, nil
+			case "/admin":
+				return &http.Response{StatusCode: 403, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			default:
+				return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			}
+		},
+	}
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       0,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+		IsBrokenStatus: func(statusCode int) bool {
+			return statusCode >= 400 && statusCode != http.StatusForbidden
+		},
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	broken := report.Pages[0].BrokenLinks
+	if len(broken) != 1 || broken[0].URL != "https://other.com/gone" {
+		t.Errorf("expected only the 404 link to be broken with 403 excluded, got %+v", broken)
+	}
+}
+
+func TestCrawlTimeout(t *testing.T) {
+	siteClient, _ := newSiteMock(map[string]string{
+		"/":     `<html><body><a href="/slow">Slow</a></body></html>`,
+		"/slow": `<html><body>Slow</body></html>`,
+	})
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			// Страница загружается дольше, чем разрешено на весь обход
+			if req.Method == http.MethodGet && req.URL.Path == "/slow" {
+				select {
+				case <-time.After(time.Second):
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+			}
+			return siteClient.Do(req)
+		},
+	}
+
+	opts := Options{
+		URL:          "https://example.com/",
+		Depth:        1,
+		Concurrency:  1,
+		Timeout:      10 * time.Second,
+		HTTPClient:   mockClient,
+		CrawlTimeout: 200 * time.Millisecond,
+	}
+
+	startedAt := time.Now()
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if elapsed := time.Since(startedAt); elapsed >= time.Second {
+		t.Errorf("expected the crawl to stop at CrawlTimeout, took %v", elapsed)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	if !report.TimedOut || report.StopReason != StopReasonTimeout {
+		t.Errorf("expected timed_out with stop_reason %q, got %v and %q", StopReasonTimeout, report.TimedOut, report.StopReason)
+	}
+	if len(report.Pages) != 1 || report.Pages[0].URL != "https://example.com/" {
+		t.Errorf("expected only the root page in the partial report, got %+v", report.Pages)
+	}
+
+	// Отмена вызывающим кодом раньше таймаута обхода — это не таймаут
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	opts.CrawlTimeout = time.Minute
+
+	result, err = Analyze(ctx, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	report = Report{}
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	if report.TimedOut || report.StopReason != StopReasonCanceled {
+		t.Errorf("expected caller cancellation with stop_reason %q, got timed_out %v and %q", StopReasonCanceled, report.TimedOut, report.StopReason)
+	}
+}
+
+func TestURLTrapGuards(t *testing.T) {
+	long := "/" + strings.Repeat("a", 100)
+	mockClient, fetched := newSiteMock(map[string]string{
+		"/": `<html><body><a href="` + long + `">Long</a><a href="` + long + `">Again</a>` +
+			`<a href="/search?a=1&b=2&c=3">Facets</a><a href="/search?a=1&b=2">Search</a>` +
+			`<a href="/short">Short</a></body></html>`,
+		long:      `<html><body>Long</body></html>`,
+		"/search": `<html><body>Search</body></html>`,
+		"/short":  `<html><body>Short</body></html>`,
+	})
+
+	opts := Options{
+		URL:               "https://example.com/",
+		Depth:             1,
+		Concurrency:       1,
+		HTTPClient:        mockClient,
+		MaxURLLength:      60,
+		MaxQueryParams:    2,
+		RecordSkippedURLs: true,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if got := strings.Join(fetched(), ","); got != "/,/search,/short" {
+		t.Errorf("Expected guarded links not to be crawled, fetched %s", got)
+	}
+
+	expected := []SkippedURL{
+		{URL: "https://example.com" + long, Reason: SkipReasonURLTooLong},
+		{URL: "https://example.com/search?a=1&b=2&c=3", Reason: SkipReasonTooManyQueryParams},
+	}
+	if fmt.Sprint(report.SkippedURLs) != fmt.Sprint(expected) {
+		t.Errorf("Expected skipped_urls %v, got %v", expected, report.SkippedURLs)
+	}
+
+	// Без RecordSkippedURLs ссылки пропускаются, но в отчёт не попадают
+	opts.RecordSkippedURLs = false
+	result, err = Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if strings.Contains(string(result), "skipped_urls") {
+		t.Errorf("Expected no skipped_urls without RecordSkippedURLs, got %s", result)
+	}
+}
+
+func TestAllowedHosts(t *testing.T) {
+	pages := map[string]string{
+		"example.com/": `<html><body><a href="https://blog.example.com/post">Blog</a>` +
+			`<a href="https://cdn.example.org/docs">Docs</a><a href="https://other.org/page">Other</a></body></html>`,
+		"blog.example.com/post": `<html><body><a href="/next">Next</a></body></html>`,
+		"blog.example.com/next": `<html><body>Next</body></html>`,
+		"cdn.example.org/docs":  `<html><body>Docs</body></html>`,
+		"other.org/page":        `<html><body>Other</body></html>`,
+	}
+
+	var mu sync.Mutex
+	fetched := []string{}
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet {
+				mu.Lock()
+				fetched = append(fetched, req.URL.Host+req.URL.Path)
+				mu.Unlock()
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(pages[req.URL.Host+req.URL.Path])),
+				Request:    req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:          "https://example.com/",
+		Depth:        2,
+		Concurrency:  1,
+		HTTPClient:   mockClient,
+		AllowedHosts: []string{"*.example.com", "cdn.example.org"},
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	urls := []string{}
+	for _, page := range report.Pages {
+		urls = append(urls, page.URL)
+	}
+	expected := "https://blog.example.com/next https://blog.example.com/post https://cdn.example.org/docs https://example.com/"
+	if got := strings.Join(urls, " "); got != expected {
+		t.Errorf("Expected pages %q, got %q", expected, got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if slices.Contains(fetched, "other.org/page") {
+		t.Errorf("Expected a host outside AllowedHosts not to be crawled, fetched %v", fetched)
+	}
+}
+
+func TestCaptureHeaders(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header: http.Header{
+					"Content-Type":              []string{"text/html"},
+					"Content-Security-Policy":   []string{"default-src 'self'"},
+					"Strict-Transport-Security": []string{"max-age=31536000"},
+					"X-Other":                   []string{"ignored"},
+				},
+				Body:    io.NopCloser(strings.NewReader(`<html><body>Page</body></html>`)),
+				Request: req,
+			}, nil
+		},
+	}
+
+	opts := Options{
+		URL:            "https://example.com/",
+		Depth:          0,
+		Concurrency:    1,
+		HTTPClient:     mockClient,
+		CaptureHeaders: []string{"Content-Security-Policy", "Strict-Transport-Security", "X-Frame-Options"},
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	headers := report.Pages[0].Headers
+	if headers["Content-Security-Policy"] != "default-src 'self'" {
+		t.Errorf("Expected the CSP header in the report, got %v", headers)
+	}
+	if len(headers) != 2 || headers["Strict-Transport-Security"] != "max-age=31536000" {
+		t.Errorf("Expected only the requested headers present in the response, got %v", headers)
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	siteClient, _ := newSiteMock(map[string]string{
+		"/": `<html><body><a href="/refused">Refused</a></body></html>`,
+	})
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/refused" {
+				return nil, &url.Error{Op: "Get", URL: req.URL.String(), Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+			}
+			return siteClient.Do(req)
+		},
+	}
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	for _, page := range report.Pages {
+		switch page.URL {
+		case "https://example.com/refused":
+			if page.Status != "error" || page.ErrorKind != httputil.ErrorKindConnection {
+				t.Errorf("Expected a connection error, got status %q and error_kind %q", page.Status, page.ErrorKind)
+			}
+		default:
+			if page.ErrorKind != "" {
+				t.Errorf("Expected no error_kind for %s, got %q", page.URL, page.ErrorKind)
+			}
+		}
+	}
+}
+
+func TestHostOverrides(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			_, _ = io.WriteString(w, `<html><body><a href="/about">About</a></body></html>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<html><body>About</body></html>`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	opts := Options{
+		URL:           "http://www.example.test:" + serverURL.Port() + "/",
+		Depth:         1,
+		Concurrency:   1,
+		HostOverrides: map[string]string{"www.example.test": "127.0.0.1"},
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	if len(report.Pages) != 2 {
+		t.Fatalf("Expected 2 pages crawled via the override, got %+v", report.Pages)
+	}
+	for _, page := range report.Pages {
+		if page.Status != "ok" || !strings.HasPrefix(page.URL, "http://www.example.test:") {
+			t.Errorf("Expected %s to be fetched from the local server, got status %q (%s)", page.URL, page.Status, page.Error)
+		}
+	}
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = io.WriteString(w, `<html><head><title>Internal</title></head><body></body></html>`)
+	}))
+	defer server.Close()
+
+	opts := Options{
+		URL:                server.URL,
+		Depth:              0,
+		Concurrency:        1,
+		Retries:            0,
+		InsecureSkipVerify: true,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	if len(report.Pages) != 1 || report.Pages[0].Status != "ok" {
+		t.Fatalf("Expected the self-signed page to be fetched, got %+v", report.Pages)
+	}
+
+	// Без опции сертификат отклоняется
+	opts.InsecureSkipVerify = false
+	result, err = Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	report = Report{}
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	if page := report.Pages[0]; page.Status != "error" || page.ErrorKind != httputil.ErrorKindTLS {
+		t.Errorf("Expected a TLS error without InsecureSkipVerify, got %q (%s)", page.Status, page.ErrorKind)
+	}
+}
+
+func TestClientCertOptions(t *testing.T) {
+	if _, err := NewCrawler(Options{URL: "https://example.com", ClientCert: "cert.pem"}); err == nil {
+		t.Error("expected error for a client certificate without a key")
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	if _, err := NewCrawler(Options{URL: "https://example.com", ClientCert: missing, ClientKey: missing}); err == nil {
+		t.Error("expected error for unreadable client certificate files")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var mu sync.Mutex
+	proxied := []string{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.Method+" "+r.URL.String())
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			_, _ = io.WriteString(w, `<html><body><a href="/about">About</a><img src="/logo.png"></body></html>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<html><body>About</body></html>`)
+	}))
+	defer proxy.Close()
+
+	opts := Options{
+		URL:         "http://site.invalid/",
+		Depth:       1,
+		Concurrency: 1,
+		Proxy:       proxy.URL,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	if len(report.Pages) != 2 || report.Pages[0].Status != "ok" || report.Pages[1].Status != "ok" {
+		t.Fatalf("Expected both pages fetched through the proxy, got %+v", report.Pages)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Через прокси идут и страницы, и проверки ссылок, и ассеты
+	for _, request := range []string{"GET http://site.invalid/", "HEAD http://site.invalid/about", "GET http://site.invalid/logo.png"} {
+		if !slices.Contains(proxied, request) {
+			t.Errorf("Expected %q to go through the proxy, got %v", request, proxied)
+		}
+	}
+}
+
+func TestPagesByDepth(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/":   `<html><body><a href="/a">A</a><a href="/b">B</a></body></html>`,
+		"/a":  `<html><body><a href="/a1">A1</a></body></html>`,
+		"/b":  `<html><body><a href="/a">A</a></body></html>`,
+		"/a1": `<html><body>A1</body></html>`,
+	})
+
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       2,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	expected := map[int]int{0: 1, 1: 2, 2: 1}
+	if fmt.Sprint(report.Summary.PagesByDepth) != fmt.Sprint(expected) {
+		t.Errorf("Expected pages_by_depth %v, got %v", expected, report.Summary.PagesByDepth)
+	}
+}
+
+func TestIncludeAllLinks(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/": `<html><body><a href="/a">A</a><a href="/a">A again</a><a href="#top">Top</a>` +
+			`<a href="mailto:info@example.com">Mail</a><a href="tel:+100">Call</a><a href="javascript:void(0)">JS</a>` +
+			`<a href="https://other.com/x">Other</a><a href="https://example.com/b">B</a></body></html>`,
+		"/a": `<html><body>A</body></html>`,
+		"/b": `<html><body>B</body></html>`,
+	})
+
+	opts := Options{
+		URL:             "https://example.com/",
+		Depth:           0,
+		Concurrency:     1,
+		HTTPClient:      mockClient,
+		IncludeAllLinks: true,
+	}
+
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	page := report.Pages[0]
+	expected := "https://example.com/a https://other.com/x https://example.com/b"
+	if got := strings.Join(page.Links, " "); got != expected {
+		t.Errorf("Expected links %q without anchors, mailto, tel and javascript, got %q", expected, got)
+	}
+	if page.InternalLinkCount != 2 || page.ExternalLinkCount != 1 {
+		t.Errorf("Expected 2 internal and 1 external links, got %d and %d", page.InternalLinkCount, page.ExternalLinkCount)
+	}
+
+	// Без IncludeAllLinks ссылки в отчёт не попадают
+	opts.IncludeAllLinks = false
+	result, err = Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if strings.Contains(string(result), `"links"`) || strings.Contains(string(result), "internal_link_count") {
+		t.Errorf("Expected no link inventory without IncludeAllLinks, got %s", result)
+	}
+}
+
+func TestUseCookieJar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Корень выдаёт cookie и перенаправляет; остальные страницы без неё недоступны
+		if r.URL.Path == "/" {
+			http.SetCookie(w, &http.Cookie{Name: "__cf", Value: "token", Path: "/"})
+			http.Redirect(w, r, "/home", http.StatusFound)
+			return
+		}
+		if cookie, err := r.Cookie("__cf"); err != nil || cookie.Value != "token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/home" {
+			_, _ = io.WriteString(w, `<html><body><a href="/page">Page</a></body></html>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<html><body>Page</body></html>`)
+	}))
+	defer server.Close()
+
+	crawl := func(useJar bool) map[string]string {
+		opts := Options{
+			URL:          server.URL + "/",
+			Depth:        1,
+			Concurrency:  1,
+			MaxRedirects: 5,
+			UseCookieJar: useJar,
+		}
+		result, err := Analyze(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+		var report Report
+		if err := json.Unmarshal(result, &report); err != nil {
+			t.Fatalf("Failed to unmarshal report: %v", err)
+		}
+		statuses := map[string]string{}
+		for _, page := range report.Pages {
+			statuses[strings.TrimPrefix(page.URL, server.URL)] = page.Status
+		}
+		return statuses
+	}
+
+	statuses := crawl(true)
+	if statuses["/"] != "ok" || statuses["/page"] != "ok" {
+		t.Errorf("Expected the session cookie to be carried to later requests, got %v", statuses)
+	}
+
+	if statuses := crawl(false); statuses["/"] != "client_error" {
+		t.Errorf("Expected the redirect target to be forbidden without a cookie jar, got %v", statuses)
+	}
+}
+
+// countingSink считает страницы, переданные в Options.PageSink
+type countingSink struct {
+	mu    sync.Mutex
+	urls  []string
+	limit int
+}
+
+func (s *countingSink) AddPage(page Page) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit > 0 && len(s.urls) >= s.limit {
+		return fmt.Errorf("sink is full")
+	}
+	s.urls = append(s.urls, page.URL)
+	return nil
+}
+
+func TestPageSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = io.WriteString(w, `<html><body><a href="/a">A</a><a href="/b">B</a><a href="/c">C</a></body></html>`)
+	}))
+	defer server.Close()
+
+	sink := &countingSink{}
+	opts := Options{
+		URL:         server.URL,
+		Depth:       1,
+		Concurrency: 2,
+		PageSink:    sink,
+	}
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	if len(sink.urls) != 4 {
+		t.Errorf("Expected 4 pages in the sink, got %v", sink.urls)
+	}
+	if len(report.Pages) != 0 {
+		t.Errorf("Expected no pages in the report with a sink, got %d", len(report.Pages))
+	}
+	if report.Summary.TotalPages != 4 || report.Summary.OKPages != 4 {
+		t.Errorf("Expected the summary to cover streamed pages, got %+v", report.Summary)
+	}
+
+	opts.Concurrency = 1
+	opts.PageSink = &countingSink{limit: 1}
+	if _, err := Analyze(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "sink is full") {
+		t.Errorf("Expected the sink error to fail the crawl, got %v", err)
+	}
+}
+
+func TestSoft404Patterns(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			_, _ = io.WriteString(w, `<html><body><a href="/missing">Missing</a></body></html>`)
+		case "/missing":
+			// Сайт отвечает 200 на несуществующую страницу
+			_, _ = io.WriteString(w, `<html><head><title>404 Not Found</title></head>`+
+				`<body><a href="/hidden">Hidden</a></body></html>`)
+		default:
+			_, _ = io.WriteString(w, `<html><body>Hidden</body></html>`)
+		}
+	}))
+	defer server.Close()
+
+	opts := Options{
+		URL:             server.URL,
+		Depth:           2,
+		Concurrency:     1,
+		Soft404Patterns: []string{"404 Not Found", `(?i)page not found`},
+	}
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	statuses := map[string]string{}
+	for _, page := range report.Pages {
+		statuses[strings.TrimPrefix(page.URL, server.URL)] = page.Status
+	}
+	if statuses["/missing"] != "soft_404" || statuses[""] != "ok" {
+		t.Errorf("Expected /missing to be reclassified as soft_404, got %v", statuses)
+	}
+	if _, ok := statuses["/hidden"]; ok {
+		t.Errorf("Expected links of a soft 404 page not to be followed, got %v", statuses)
+	}
+	if report.Summary.OKPages != 1 || report.Summary.ErrorPages != 1 {
+		t.Errorf("Expected soft 404 to count as an error page, got %+v", report.Summary)
+	}
+
+	opts.Soft404Patterns = []string{"("}
+	if _, err := Analyze(context.Background(), opts); err == nil {
+		t.Error("Expected error for an invalid soft 404 pattern")
+	}
+}
+
+// BenchmarkAnalyzeFanOut обходит сайт в памяти из корня и 100 страниц
+func BenchmarkAnalyzeFanOut(b *testing.B) {
+	for b.Loop() {
+		site := crawlertest.NewInMemorySite(crawlertest.FanOut(100))
+		site.Latency = time.Millisecond
+		opts := Options{
+			URL:         "https://example.com",
+			Depth:       1,
+			Concurrency: 10,
+			HTTPClient:  site,
+		}
+		if _, err := Analyze(context.Background(), opts); err != nil {
+			b.Fatalf("Analyze failed: %v", err)
+		}
+	}
+}
+
+func TestStartJitter(t *testing.T) {
+	var mu sync.Mutex
+	var started []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			_, _ = io.WriteString(w, `<html><body>`+
+				`<a href="/1">1</a><a href="/2">2</a><a href="/3">3</a>`+
+				`<a href="/4">4</a><a href="/5">5</a><a href="/6">6</a></body></html>`)
+			return
+		}
+		// Учитываются только загрузки страниц первого уровня, без HEAD проверки ссылок
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			started = append(started, time.Now())
+			mu.Unlock()
+		}
+		_, _ = io.WriteString(w, `<html><body>Page</body></html>`)
+	}))
+	defer server.Close()
+
+	opts := Options{
+		URL:         server.URL,
+		Depth:       1,
+		Concurrency: 8,
+		StartJitter: 300 * time.Millisecond,
+	}
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if len(started) != 6 {
+		t.Fatalf("Expected 6 page requests, got %d", len(started))
+	}
+	slices.SortFunc(started, func(a, b time.Time) int { return a.Compare(b) })
+	// Без паузы все шесть запросов уходят одновременно
+	if spread := started[len(started)-1].Sub(started[0]); spread < 30*time.Millisecond {
+		t.Errorf("Expected first requests to be spread over time, got %v", spread)
+	}
+}
+
+func TestSinglePage(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			fetched[r.URL.Path]++
+			mu.Unlock()
+		}
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = io.WriteString(w, `<html><head><link rel="canonical" href="/canonical">`+
+				`<link rel="stylesheet" href="/style.css"></head>`+
+				`<body><a href="/ok">OK</a><a href="/missing">Missing</a></body></html>`)
+		case "/ok", "/canonical", "/seed":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = io.WriteString(w, `<html><body>Page</body></html>`)
+		case "/style.css":
+			w.Header().Set("Content-Type", "text/css")
+			_, _ = io.WriteString(w, `body {}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	opts := Options{
+		URL:             server.URL,
+		Depth:           3,
+		Concurrency:     2,
+		SinglePage:      true,
+		FollowCanonical: true,
+		SeedURLs:        []string{server.URL + "/seed"},
+	}
+	result, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+	if len(report.Pages) != 1 {
+		t.Fatalf("Expected exactly one page, got %d", len(report.Pages))
+	}
+	if report.Depth != 0 {
+		t.Errorf("Expected report depth 0, got %d", report.Depth)
+	}
+
+	page := report.Pages[0]
+	if len(page.BrokenLinks) != 1 || page.BrokenLinks[0].URL != server.URL+"/missing" {
+		t.Errorf("Expected /missing to be reported as broken, got %+v", page.BrokenLinks)
+	}
+	if len(page.Assets) != 1 || page.Assets[0].StatusCode != http.StatusOK {
+		t.Errorf("Expected the stylesheet to be checked, got %+v", page.Assets)
+	}
+	for _, path := range []string{"/ok", "/canonical", "/seed"} {
+		if fetched[path] != 0 {
+			t.Errorf("Expected %s not to be crawled, got %d GET requests", path, fetched[path])
+		}
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			_, _ = io.WriteString(w, `<html><body><a href="/a">A</a></body></html>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<html><body><a href="/">Home</a></body></html>`)
+	}))
+	defer server.Close()
+
+	crawler, err := NewCrawler(Options{
+		URL:         server.URL,
+		Depth:       1,
+		Concurrency: 1,
+		CacheTTL:    time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewCrawler failed: %v", err)
+	}
+
+	first, err := crawler.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	afterFirst := requests
+
+	second, err := crawler.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if requests != afterFirst {
+		t.Errorf("Expected the second run within TTL to be served from cache, got %d extra requests", requests-afterFirst)
+	}
+	if len(second.Pages) != len(first.Pages) || second.Pages[1].SEO.WordCount != first.Pages[1].SEO.WordCount {
+		t.Errorf("Expected cached run to produce the same pages, got %+v", second.Pages)
+	}
+	if second.Summary.TotalRequests != 0 {
+		t.Errorf("Expected cache hits not to count as requests, got %d", second.Summary.TotalRequests)
+	}
+}
+
+func TestIncludeNoscript(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			_, _ = io.WriteString(w, `<html><body><!-- <a href="/commented">Old</a> -->`+
+				`<noscript><a href="/noscript">No JS</a></noscript></body></html>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<html><body>Page</body></html>`)
+	}))
+	defer server.Close()
+
+	crawl := func(includeNoscript bool) []string {
+		opts := Options{
+			URL:             server.URL,
+			Depth:           1,
+			Concurrency:     1,
+			IncludeNoscript: includeNoscript,
+		}
+		result, err := Analyze(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+		var report Report
+		if err := json.Unmarshal(result, &report); err != nil {
+			t.Fatalf("Failed to unmarshal report: %v", err)
+		}
+		var paths []string
+		for _, page := range report.Pages {
+			paths = append(paths, strings.TrimPrefix(page.URL, server.URL))
+		}
+		return paths
+	}
+
+	if paths := crawl(false); len(paths) != 1 {
+		t.Errorf("Expected noscript and commented links to be skipped, got %v", paths)
+	}
+	if paths := crawl(true); !slices.Equal(paths, []string{"", "/noscript"}) {
+		t.Errorf("Expected the noscript link to be crawled, got %v", paths)
+	}
+}
+
+func TestMaxQueueSize(t *testing.T) {
+	t.Run("drop", func(t *testing.T) {
+		opts := Options{
+			URL:               "https://example.com",
+			Depth:             1,
+			Concurrency:       2,
+			HTTPClient:        crawlertest.NewInMemorySite(crawlertest.FanOut(10)),
+			MaxQueueSize:      3,
+			RecordSkippedURLs: true,
+		}
+		result, err := Analyze(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+
+		var report Report
+		if err := json.Unmarshal(result, &report); err != nil {
+			t.Fatalf("failed to parse report: %v", err)
+		}
+		if len(report.Pages) != 4 {
+			t.Errorf("expected root and 3 queued pages, got %d", len(report.Pages))
+		}
+		if len(report.SkippedURLs) != 7 {
+			t.Fatalf("expected 7 dropped URLs, got %v", report.SkippedURLs)
+		}
+		for _, skipped := range report.SkippedURLs {
+			if skipped.Reason != SkipReasonQueueFull {
+				t.Errorf("expected reason %q, got %+v", SkipReasonQueueFull, skipped)
+			}
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		for _, concurrency := range []int{1, 3} {
+			site := crawlertest.NewInMemorySite(crawlertest.FanOut(10))
+			site.Latency = time.Millisecond
+			opts := Options{
+				URL:             "https://example.com",
+				Depth:           1,
+				Concurrency:     concurrency,
+				HTTPClient:      site,
+				MaxQueueSize:    2,
+				QueueFullPolicy: QueueFullBlock,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			result, err := Analyze(ctx, opts)
+			cancel()
+			if err != nil {
+				t.Fatalf("Analyze failed: %v", err)
+			}
+
+			var report Report
+			if err := json.Unmarshal(result, &report); err != nil {
+				t.Fatalf("failed to parse report: %v", err)
+			}
+			if report.StopReason != "" {
+				t.Fatalf("concurrency %d: expected crawl to finish without blocking forever, stopped: %s", concurrency, report.StopReason)
+			}
+			if len(report.Pages) != 11 {
+				t.Errorf("concurrency %d: expected all 11 pages to be crawled, got %d", concurrency, len(report.Pages))
+			}
+		}
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		_, err := Analyze(context.Background(), Options{URL: "https://example.com", QueueFullPolicy: "wait"})
+		if err == nil || !strings.Contains(err.Error(), "queue full policy") {
+			t.Errorf("expected invalid policy error, got %v", err)
+		}
+	})
+}
+
+// TestBreadthFirstOrder проверяет, что страницы обходятся по возрастанию
+// глубины, даже если URL меньшей глубины попал в очередь позже более глубоких
+func TestBreadthFirstOrder(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/":   `<html><body><a href="/a">A</a><a href="/b">B</a></body></html>`,
+		"/a":  `<html><body><a href="/a1">A1</a></body></html>`,
+		"/b":  `<html><head><link rel="canonical" href="/c"></head><body>B</body></html>`,
+		"/c":  `<html><body>C</body></html>`,
+		"/a1": `<html><body>A1</body></html>`,
+	})
+	progress := make(chan ProgressEvent, 10)
+	opts := Options{
+		URL:             "https://example.com/",
+		Depth:           2,
+		Concurrency:     1,
+		HTTPClient:      mockClient,
+		FollowCanonical: true,
+		Progress:        progress,
+	}
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	close(progress)
+
+	// Canonical /c ставится в очередь на глубине /b (1) после /a1 (2),
+	// но обходится раньше него
+	var order []string
+	lastDepth := 0
+	for event := range progress {
+		order = append(order, strings.TrimPrefix(event.URL, "https://example.com"))
+		if event.Depth < lastDepth {
+			t.Errorf("page %s at depth %d processed after depth %d", event.URL, event.Depth, lastDepth)
+		}
+		lastDepth = event.Depth
+	}
+	if len(order) != 5 || order[3] != "/c" || order[4] != "/a1" {
+		t.Errorf("expected /c to be processed before /a1, got %v", order)
+	}
+}
+
+// TestValidateExternalLinks проверяет, что внешняя ссылка с нескольких
+// страниц проверяется один раз за обход и попадает в external_links
+func TestValidateExternalLinks(t *testing.T) {
+	pages := map[string]string{
+		"/":  `<html><body><a href="/a">A</a><a href="/b">B</a></body></html>`,
+		"/a": `<html><body><a href="https://external.example/ok">Ext</a></body></html>`,
+		"/b": `<html><body><a href="https://external.example/ok#top">Ext</a>` +
+			`<a href="https://external.example/missing">Gone</a><a href="mailto:me@example.com">Mail</a></body></html>`,
+	}
+
+	run := func(validate bool) (Report, int) {
+		var mu sync.Mutex
+		externalHeads := 0
+		client := &MockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Host == "external.example" {
+					mu.Lock()
+					if req.URL.Path == "/ok" && req.Method == http.MethodHead {
+						externalHeads++
+					}
+					mu.Unlock()
+					status := http.StatusOK
+					if req.URL.Path == "/missing" {
+						status = http.StatusNotFound
+					}
+					return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+				}
+				body, ok := pages[req.URL.Path]
+				if !ok {
+					return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+				}
+				return &http.Response{
+					StatusCode: 200,
+					Header:     http.Header{"Content-Type": []string{"text/html"}},
+					Body:       io.NopCloser(strings.NewReader(body)),
+					Request:    req,
+				}, nil
+			},
+		}
+
+		result, err := Analyze(context.Background(), Options{
+			URL:                   "https://example.com/",
+			Depth:                 1,
+			Concurrency:           2,
+			HTTPClient:            client,
+			ValidateExternalLinks: validate,
+		})
+		if err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+		var report Report
+		if err := json.Unmarshal(result, &report); err != nil {
+			t.Fatalf("failed to parse report: %v", err)
+		}
+		return report, externalHeads
+	}
+
+	withoutReport, withoutHeads := run(false)
+	if withoutReport.ExternalLinks != nil {
+		t.Errorf("expected no external_links without ValidateExternalLinks, got %v", withoutReport.ExternalLinks)
+	}
+
+	report, heads := run(true)
+	if heads != withoutHeads+1 {
+		t.Errorf("expected one extra HEAD for the shared external link, got %d (without validation %d)", heads, withoutHeads)
+	}
+	if len(report.ExternalLinks) != 2 {
+		t.Fatalf("expected 2 external links deduplicated across pages, got %+v", report.ExternalLinks)
+	}
+	missing, ok := report.ExternalLinks[0], report.ExternalLinks[1]
+	if missing.URL != "https://external.example/missing" || !missing.Broken || missing.StatusCode != 404 || missing.FoundOn != "https://example.com/b" {
+		t.Errorf("unexpected broken external link %+v", missing)
+	}
+	if ok.URL != "https://external.example/ok" || ok.Broken || ok.StatusCode != 200 || ok.FoundOn == "" {
+		t.Errorf("unexpected working external link %+v", ok)
+	}
+}
+
+// TestResponseHeaderTimeout проверяет, что долгое ожидание заголовков
+// прерывается ResponseHeaderTimeout, а медленное тело — нет
+func TestResponseHeaderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			_, _ = io.WriteString(w, `<html><body><a href="/slow-headers">H</a><a href="/slow-body">B</a></body></html>`)
+		case "/slow-headers":
+			time.Sleep(300 * time.Millisecond)
+			_, _ = io.WriteString(w, `<html><body>Late</body></html>`)
+		case "/slow-body":
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			time.Sleep(300 * time.Millisecond)
+			_, _ = io.WriteString(w, `<html><head><title>Slow</title></head><body>Body</body></html>`)
+		}
+	}))
+	defer server.Close()
+
+	result, err := Analyze(context.Background(), Options{
+		URL:                   server.URL,
+		Depth:                 1,
+		Concurrency:           2,
+		Timeout:               5 * time.Second,
+		ResponseHeaderTimeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("failed to parse report: %v", err)
+	}
+	pages := map[string]Page{}
+	for _, page := range report.Pages {
+		pages[strings.TrimPrefix(page.URL, server.URL)] = page
+	}
+	if page := pages["/slow-headers"]; page.Error == "" {
+		t.Errorf("expected header timeout error for /slow-headers, got %+v", page)
+	}
+	if page := pages["/slow-body"]; page.Status != "ok" || page.SEO == nil || page.SEO.Title != "Slow" {
+		t.Errorf("expected /slow-body to load completely, got %+v", page)
+	}
+}
+
+// TestPreprocessHTML проверяет, что ссылки ищутся в HTML после PreprocessHTML
+func TestPreprocessHTML(t *testing.T) {
+	mockClient, fetched := newSiteMock(map[string]string{
+		"/":       `<html><head><title>Shell</title></head><body>{{LINK}}</body></html>`,
+		"/hidden": `<html><body>Hidden</body></html>`,
+	})
+
+	var mu sync.Mutex
+	var seen []string
+	opts := Options{
+		URL:         "https://example.com/",
+		Depth:       1,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+		PreprocessHTML: func(pageURL, html string) string {
+			mu.Lock()
+			seen = append(seen, pageURL)
+			mu.Unlock()
+			return strings.ReplaceAll(html, "{{LINK}}", `<a href="/hidden">Hidden</a>`)
+		},
+	}
+	if _, err := Analyze(context.Background(), opts); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if got := fetched(); !slices.Contains(got, "/hidden") {
+		t.Errorf("expected the injected link to be crawled, fetched %v", got)
+	}
+	if len(seen) != 2 || seen[0] != "https://example.com/" {
+		t.Errorf("expected the hook to receive final page URLs, got %v", seen)
+	}
+}
+
+// TestHostCertificates проверяет, что сертификат HTTPS-хоста попадает в отчёт один раз
+func TestHostCertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = io.WriteString(w, `<html><body><a href="/a">A</a></body></html>`)
+	}))
+	defer server.Close()
+
+	result, err := Analyze(context.Background(), Options{
+		URL:         server.URL,
+		Depth:       1,
+		Concurrency: 2,
+		HTTPClient:  server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("failed to parse report: %v", err)
+	}
+	if len(report.Hosts) != 1 {
+		t.Fatalf("expected one host, got %v", report.Hosts)
+	}
+	host := strings.TrimPrefix(server.URL, "https://")
+	info, ok := report.Hosts[host]
+	if !ok {
+		t.Fatalf("expected host %s, got %v", host, report.Hosts)
+	}
+	cert := server.Certificate()
+	if info.CertNotAfter != cert.NotAfter.UTC().Format(time.RFC3339) {
+		t.Errorf("expected expiry %s, got %s", cert.NotAfter.UTC().Format(time.RFC3339), info.CertNotAfter)
+	}
+	if info.CertIssuer != cert.Issuer.String() || !info.CertValid {
+		t.Errorf("unexpected host info %+v", info)
+	}
+}
+
+// TestFollowMetaRefresh проверяет, что цель мета-обновления обходится как ссылка
+func TestFollowMetaRefresh(t *testing.T) {
+	pages := map[string]string{
+		"/":     `<html><head><meta http-equiv="refresh" content="0; url=/next"></head><body>Moved</body></html>`,
+		"/next": `<html><body>Next</body></html>`,
+	}
+
+	for _, follow := range []bool{false, true} {
+		mockClient, fetched := newSiteMock(pages)
+		result, err := Analyze(context.Background(), Options{
+			URL:               "https://example.com/",
+			Depth:             1,
+			Concurrency:       1,
+			HTTPClient:        mockClient,
+			FollowMetaRefresh: follow,
+		})
+		if err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+
+		if got := slices.Contains(fetched(), "/next"); got != follow {
+			t.Errorf("FollowMetaRefresh=%v: expected /next crawled = %v, fetched %v", follow, follow, fetched())
+		}
+		var report Report
+		if err := json.Unmarshal(result, &report); err != nil {
+			t.Fatalf("failed to parse report: %v", err)
+		}
+		for _, page := range report.Pages {
+			if page.URL == "https://example.com/" && follow && page.MetaRefresh != "https://example.com/next" {
+				t.Errorf("expected meta_refresh on the root page, got %q", page.MetaRefresh)
+			}
+		}
+	}
+}
+
+// TestParseCSS проверяет, что битый шрифт из таблицы стилей попадает в ассеты страницы
+func TestParseCSS(t *testing.T) {
+	mockClient, _ := newSiteMock(map[string]string{
+		"/":             `<html><head><link rel="stylesheet" href="/css/site.css"></head><body>Home</body></html>`,
+		"/css/site.css": `@font-face { src: url("fonts/main.woff2"); } .bg { background: url(data:image/gif;base64,R0lGOD==); }`,
+	})
+
+	result, err := Analyze(context.Background(), Options{
+		URL:         "https://example.com/",
+		Depth:       0,
+		Concurrency: 1,
+		HTTPClient:  mockClient,
+		ParseCSS:    true,
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(result, &report); err != nil {
+		t.Fatalf("failed to parse report: %v", err)
+	}
+	assets := report.Pages[0].Assets
+	if len(assets) != 2 {
+		t.Fatalf("expected the stylesheet and its font, got %+v", assets)
+	}
+	font := assets[1]
+	if font.URL != "https://example.com/css/fonts/main.woff2" || font.Type != "font" || font.Origin != AssetOriginCSS {
+		t.Errorf("unexpected CSS-referenced asset %+v", font)
+	}
+	if font.StatusCode != http.StatusNotFound || report.Summary.BrokenAssets != 1 {
+		t.Errorf("expected the missing font to be reported as broken, got status %d and %d broken assets", font.StatusCode, report.Summary.BrokenAssets)
+	}
+}
+
+// countingTransport записывает метод и путь каждого запроса
+type countingTransport struct {
+	mu       sync.Mutex
+	requests []string
+	next     http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.requests = append(t.requests, req.Method+" "+req.URL.Path)
+	t.mu.Unlock()
+	return t.next.RoundTrip(req)
+}
+
+// TestTransport проверяет, что Options.Transport видит запросы страниц,
+// ассетов и проверок ссылок
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = io.WriteString(w, `<html><body><a href="/about">About</a><img src="/logo.png"></body></html>`)
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = io.WriteString(w, "png")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{next: http.DefaultTransport}
+	if _, err := Analyze(context.Background(), Options{
+		URL:         server.URL + "/",
+		Concurrency: 1,
+		Transport:   transport,
+	}); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	seen := strings.Join(transport.requests, "\n")
+	for _, expected := range []string{"GET /", "HEAD /about"} {
+		if !slices.Contains(transport.requests, expected) {
+			t.Errorf("expected transport to see %q, got:\n%s", expected, seen)
+		}
+	}
+	if !strings.Contains(seen, " /logo.png") {
+		t.Errorf("expected transport to see the asset request, got:\n%s", seen)
+	}
+
+	_, err := Analyze(context.Background(), Options{URL: server.URL, Transport: transport, HTTPClient: http.DefaultClient})
+	if err == nil || !strings.Contains(err.Error(), "HTTPClient and Transport") {
+		t.Errorf("expected error for HTTPClient with Transport, got %v", err)
+	}
+}
+
+// TestClientOptionsWithCustomClient: настройки клиента по умолчанию нельзя
+// задавать вместе с HTTPClient или Transport — они бы молча не действовали
+func TestClientOptionsWithCustomClient(t *testing.T) {
+	options := map[string]func(*Options){
+		"Proxy":                 func(o *Options) { o.Proxy = "http://proxy.example.com:8080" },
+		"InsecureSkipVerify":    func(o *Options) { o.InsecureSkipVerify = true },
+		"ClientCert":            func(o *Options) { o.ClientCert, o.ClientKey = "cert.pem", "key.pem" },
+		"HostOverrides":         func(o *Options) { o.HostOverrides = map[string]string{"example.com": "127.0.0.1"} },
+		"MaxIdleConnsPerHost":   func(o *Options) { o.MaxIdleConnsPerHost = 8 },
+		"ConnectTimeout":        func(o *Options) { o.ConnectTimeout = time.Second },
+		"ResponseHeaderTimeout": func(o *Options) { o.ResponseHeaderTimeout = time.Second },
+	}
+
+	for name, set := range options {
+		for custom, opts := range map[string]Options{
+			"HTTPClient": {URL: "https://example.com", HTTPClient: &MockHTTPClient{}},
+			"Transport":  {URL: "https://example.com", Transport: http.DefaultTransport},
+		} {
+			set(&opts)
+			_, err := NewCrawler(opts)
+			if expected := name + " cannot be used with " + custom; err == nil || err.Error() != expected {
+				t.Errorf("expected %q, got %v", expected, err)
+			}
+		}
+	}
+
+	// Хранилище cookie подключает клиент краулера, и с Transport оно работает
+	if _, err := NewCrawler(Options{URL: "https://example.com", HTTPClient: &MockHTTPClient{}, UseCookieJar: true}); err == nil {
+		t.Error("expected error for UseCookieJar with HTTPClient")
+	}
+	if _, err := NewCrawler(Options{URL: "https://example.com", Transport: http.DefaultTransport, UseCookieJar: true}); err != nil {
+		t.Errorf("expected UseCookieJar to work with Transport, got %v", err)
+	}
+}
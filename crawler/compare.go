@@ -0,0 +1,66 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"code/internal/httputil"
+	"code/internal/report"
+)
+
+type ReportDiff = report.ReportDiff
+
+// LoadReport читает ранее сохранённый JSON-отчёт
+func LoadReport(r io.Reader) (*Report, error) {
+	return report.Load(r)
+}
+
+// FetchReport загружает ранее сохранённый JSON-отчёт по http(s) URL тем же
+// клиентом, что и обход с опциями opts: прокси, сертификаты, заголовки,
+// cookies, авторизация и User-Agent. Загрузка ограничена opts.Timeout.
+func FetchReport(ctx context.Context, opts Options, reportURL string) (*Report, error) {
+	if err := normalizeOptions(&opts); err != nil {
+		return nil, err
+	}
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{
+		Client:        opts.HTTPClient,
+		UserAgent:     opts.UserAgent,
+		Timeout:       opts.Timeout,
+		Headers:       opts.Headers,
+		Cookies:       opts.Cookies,
+		BasicAuthUser: opts.BasicAuthUser,
+		BasicAuthPass: opts.BasicAuthPass,
+		BearerToken:   opts.BearerToken,
+	}, nil)
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	req, err := fetcher.NewRequest(ctx, http.MethodGet, reportURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fetcher.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	body, err := httputil.Decompress(resp.Header.Get("Content-Encoding"), resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return LoadReport(body)
+}
+
+// DiffReports возвращает только изменения между предыдущим и текущим отчётом
+func DiffReports(prev, curr *Report) ReportDiff {
+	return report.Diff(prev, curr)
+}
@@ -0,0 +1,107 @@
+package crawlertest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"code/internal/httputil"
+)
+
+var _ httputil.HTTPClient = (*Site)(nil)
+
+// Site — сайт в памяти для тестов и бенчмарков краулера: отвечает на запросы
+// заранее заданными HTML-страницами без сети и httptest-сервера.
+// Подставляется в Options.HTTPClient. Страницы ищутся по пути URL, хост
+// не учитывается. Latency и Statuses задаются до начала обхода;
+// Do безопасен для одновременного использования из нескольких воркеров.
+type Site struct {
+	pages map[string]string
+	// Latency — задержка перед каждым ответом (0 — без задержки)
+	Latency time.Duration
+	// Statuses — код ответа для пути вместо 200. Пути без страницы
+	// отвечают 404, если код для них не задан.
+	Statuses map[string]int
+	requests atomic.Int64
+}
+
+// NewInMemorySite создаёт сайт из страниц: путь ("/", "/about") → HTML
+func NewInMemorySite(pages map[string]string) *Site {
+	return &Site{pages: pages}
+}
+
+// Do отвечает страницей по пути запроса. На HEAD отвечает тем же статусом
+// без тела. Отмена контекста запроса прерывает задержку Latency.
+func (s *Site) Do(req *http.Request) (*http.Response, error) {
+	s.requests.Add(1)
+
+	if s.Latency > 0 {
+		timer := time.NewTimer(s.Latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	body, ok := s.pages[path]
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusNotFound
+		body = "not found"
+	}
+	if code, ok := s.Statuses[path]; ok {
+		status = code
+	}
+	if req.Method == http.MethodHead {
+		body = ""
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return &http.Response{
+		StatusCode:    status,
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Requests возвращает число запросов, полученных сайтом
+func (s *Site) Requests() int64 {
+	return s.requests.Load()
+}
+
+// FanOut возвращает страницы сайта-"веера": корень "/" ссылается на n
+// страниц "/page/1" ... "/page/n", а каждая из них — обратно на корень
+func FanOut(n int) map[string]string {
+	pages := make(map[string]string, n+1)
+
+	var root strings.Builder
+	root.WriteString("<html><head><title>Home</title></head><body>")
+	for i := 1; i <= n; i++ {
+		path := fmt.Sprintf("/page/%d", i)
+		fmt.Fprintf(&root, `<a href="%s">Page %d</a>`, path, i)
+		pages[path] = fmt.Sprintf(`<html><head><title>Page %d</title></head><body><a href="/">Home</a></body></html>`, i)
+	}
+	root.WriteString("</body></html>")
+	pages["/"] = root.String()
+
+	return pages
+}
@@ -0,0 +1,79 @@
+package crawlertest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, site *Site, method, rawURL string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := site.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestSite(t *testing.T) {
+	site := NewInMemorySite(map[string]string{
+		"/":      "<html>home</html>",
+		"/gone":  "<html>gone</html>",
+		"/about": "<html>about</html>",
+	})
+	site.Statuses = map[string]int{"/gone": http.StatusGone, "/flaky": http.StatusServiceUnavailable}
+
+	if resp, body := get(t, site, http.MethodGet, "https://example.com"); resp.StatusCode != http.StatusOK || body != "<html>home</html>" {
+		t.Errorf("expected root page, got %d %q", resp.StatusCode, body)
+	}
+	if resp, body := get(t, site, http.MethodHead, "https://other.example/about"); resp.StatusCode != http.StatusOK || body != "" {
+		t.Errorf("expected HEAD without body, got %d %q", resp.StatusCode, body)
+	}
+	if resp, _ := get(t, site, http.MethodGet, "https://example.com/gone"); resp.StatusCode != http.StatusGone {
+		t.Errorf("expected configured status 410, got %d", resp.StatusCode)
+	}
+	if resp, _ := get(t, site, http.MethodGet, "https://example.com/flaky"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected configured status for a path without page, got %d", resp.StatusCode)
+	}
+	if resp, _ := get(t, site, http.MethodGet, "https://example.com/missing"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing page, got %d", resp.StatusCode)
+	}
+	if site.Requests() != 5 {
+		t.Errorf("expected 5 requests, got %d", site.Requests())
+	}
+}
+
+func TestSiteLatencyRespectsContext(t *testing.T) {
+	site := NewInMemorySite(FanOut(1))
+	site.Latency = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com/", nil)
+
+	started := time.Now()
+	if _, err := site.Do(req); err == nil {
+		t.Fatal("expected context error during latency")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("expected canceled request to return promptly, took %v", elapsed)
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	pages := FanOut(3)
+	if len(pages) != 4 {
+		t.Fatalf("expected root and 3 pages, got %d", len(pages))
+	}
+	if _, ok := pages["/page/3"]; !ok {
+		t.Errorf("expected /page/3, got %v", pages)
+	}
+}
@@ -0,0 +1,28 @@
+package crawler
+
+import (
+	"encoding/json"
+
+	"code/internal/report"
+)
+
+// RenderHTML отображает JSON-отчёт Analyze в виде самодостаточной
+// HTML-страницы (встроенный CSS, без внешних ресурсов)
+func RenderHTML(reportJSON []byte) ([]byte, error) {
+	var r Report
+	if err := json.Unmarshal(reportJSON, &r); err != nil {
+		return nil, err
+	}
+	return report.RenderHTML(&r)
+}
+
+// RenderDOT отображает JSON-отчёт Analyze в виде графа Graphviz (DOT):
+// страницы — узлы, ссылки между ними — рёбра. Рёбра строятся по Page.Links,
+// поэтому обход должен быть запущен с IncludeAllLinks.
+func RenderDOT(reportJSON []byte) ([]byte, error) {
+	var r Report
+	if err := json.Unmarshal(reportJSON, &r); err != nil {
+		return nil, err
+	}
+	return report.RenderDOT(&r), nil
+}
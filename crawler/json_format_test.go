@@ -280,6 +280,19 @@ func TestJSONFormat_ISO8601Timestamps(t *testing.T) {
 		t.Errorf("generated_at is not valid ISO8601: %v", err)
 	}
 
+	// Время обхода: завершение не раньше начала
+	startedAt, err := time.Parse(time.RFC3339, report.StartedAt)
+	if err != nil {
+		t.Errorf("started_at is not valid ISO8601: %v", err)
+	}
+	completedAt, err := time.Parse(time.RFC3339, report.CompletedAt)
+	if err != nil {
+		t.Errorf("completed_at is not valid ISO8601: %v", err)
+	}
+	if completedAt.Before(startedAt) || report.DurationMs < 0 {
+		t.Errorf("expected completed_at >= started_at, got %s, %s (%d ms)", report.StartedAt, report.CompletedAt, report.DurationMs)
+	}
+
 	// Проверяем формат discovered_at
 	if len(report.Pages) > 0 && report.Pages[0].DiscoveredAt != "" {
 		_, err = time.Parse(time.RFC3339, report.Pages[0].DiscoveredAt)
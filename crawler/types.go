@@ -1,19 +1,36 @@
 package crawler
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
 	"time"
 
+	"golang.org/x/net/publicsuffix"
+
 	"code/internal/checker"
 	"code/internal/httputil"
 	"code/internal/report"
 	"code/internal/seo"
+	"code/internal/state"
+	"code/internal/urlutil"
 )
 
 type HTTPClient = httputil.HTTPClient
 
 type Options struct {
-	URL         string
+	URL string
+	// Depth — сколько уровней ссылок обходить от корня. Корень имеет
+	// глубину 0, ссылки с него — 1 и т.д.; обходятся страницы с глубиной
+	// не больше Depth. Depth 0 — только корень (и seed-URL), Depth 1 —
+	// корень и страницы, на которые он ссылается.
 	Depth       int
 	Retries     int
 	Delay       time.Duration
@@ -21,20 +38,465 @@ type Options struct {
 	UserAgent   string
 	Concurrency int
 	IndentJSON  bool
-	HTTPClient  HTTPClient
+	// HTTPClient выполняет все запросы вместо клиента по умолчанию.
+	// Собственный *http.Client сам следует редиректам, и краулер видит
+	// только итоговый ответ: MaxRedirects, статусы "redirect"
+	// и "redirect_loop", RedirectChain и RedirectTarget не действуют.
+	HTTPClient HTTPClient
+	// Transport — транспорт клиента по умолчанию вместо встроенного, например
+	// обёртка с метриками или логированием всех запросов (страницы, ассеты,
+	// проверки ссылок, sitemap). Редиректы и UseCookieJar по-прежнему
+	// обрабатывает клиент краулера, а настройки соединений (Proxy,
+	// InsecureSkipVerify, ClientCert, HostOverrides, MaxIdleConnsPerHost,
+	// ConnectTimeout, ResponseHeaderTimeout) вместе с Transport задавать
+	// нельзя. Нельзя задавать вместе с HTTPClient.
+	Transport http.RoundTripper
+	// SinglePage — аудит одной страницы: загружается только Options.URL,
+	// его ссылки и ассеты проверяются, но в очередь ничего не ставится
+	// (ни ссылки, ни canonical, ни SeedURLs, ни URL из SitemapURL).
+	// Depth при этом считается равным 0.
+	SinglePage bool
+	// IncludeNoscript извлекает ссылки и ассеты из содержимого <noscript>.
+	// По умолчанию оно пропускается, как и разметка в HTML-комментариях.
+	IncludeNoscript bool
+	// Logger получает отладочные события обхода: выдача URL из очереди,
+	// результаты загрузки, повторы запросов, решения о постановке ссылок
+	// в очередь. nil — без логирования.
+	Logger *slog.Logger
+	// Headers добавляются к каждому запросу (страницы, ассеты, проверка ссылок)
+	Headers map[string]string
+	// Cookies отправляются с каждым запросом
+	Cookies []*http.Cookie
+	// FollowCanonical: если страница объявляет другой canonical URL,
+	// она помечается как non_canonical, а в очередь ставится canonical
+	FollowCanonical bool
+	// FollowMetaRefresh считает URL из <meta http-equiv="refresh"> ссылкой
+	// страницы: он проверяется, ставится в очередь, как обычная ссылка,
+	// и записывается в Page.MetaRefresh
+	FollowMetaRefresh bool
+	// SkipNonCanonical убирает non_canonical страницы из отчёта (вместе с FollowCanonical)
+	SkipNonCanonical bool
+	// BasicAuthUser/BasicAuthPass включают Basic-авторизацию для всех запросов
+	BasicAuthUser string
+	BasicAuthPass string
+	// BearerToken включает авторизацию "Authorization: Bearer <token>".
+	// Нельзя использовать одновременно с Basic-авторизацией.
+	BearerToken string
+	// Include — регулярные выражения: если заданы, в очередь попадают только
+	// URL, совпадающие хотя бы с одним из них. Корневой URL обходится всегда.
+	Include []string
+	// Exclude — регулярные выражения URL, которые не ставятся в очередь
+	Exclude []string
+	// ExpectContentType — ожидаемый префикс Content-Type корневой страницы
+	// (например, "text/html"). При несовпадении Analyze возвращает ошибку.
+	// Пустое значение отключает проверку.
+	ExpectContentType string
+	// Soft404Patterns — регулярные выражения (подойдёт и простая подстрока,
+	// например "Page not found"): ответ 2xx, в теле которого нашлось
+	// совпадение, получает статус "soft_404" вместо "ok", и его ссылки
+	// не обходятся
+	Soft404Patterns []string
+	// MaxRedirects — сколько редиректов проходить при загрузке страницы.
+	// 0 — не следовать: страница попадает в отчёт со статусом "redirect".
+	// Цикл редиректов отмечается статусом "redirect_loop".
+	// Не действует, если задан HTTPClient, следующий редиректам сам.
+	MaxRedirects int
+	// DryRun — страницы запрашиваются через HEAD, тело не читается: ссылки и
+	// ассеты не извлекаются, в отчёте только статусы. Поскольку ссылки не
+	// обнаруживаются, обход фактически ограничен глубиной 0 (стартовыми URL).
+	DryRun bool
+	// RecordTechStack записывает заголовки Server и X-Powered-By страниц
+	// и сводку summary.tech_stack с числом страниц для каждого значения
+	RecordTechStack bool
+	// IgnoreSelfLinkParams — query-параметры, которыми ссылка на текущую
+	// страницу может отличаться, оставаясь ссылкой на себя (например, "ref").
+	// Такие ссылки, как и ссылки только с fragment, не ставятся в очередь.
+	IgnoreSelfLinkParams []string
+	// SitemapURL — sitemap.xml или sitemap index, URL из которого ставятся
+	// в очередь на глубине 0 вместе с корнем (только того же домена).
+	// Дочерние sitemap загружаются параллельно с ограничением Concurrency.
+	SitemapURL string
+	// VerifyStability загружает каждую страницу дважды и помечает её
+	// unstable, если тело ответа (или статус) при повторе отличается
+	VerifyStability bool
+	// RecordExternalDomains записывает для каждой страницы отсортированный
+	// список уникальных внешних доменов, на которые она ссылается
+	RecordExternalDomains bool
+	// ValidateExternalLinks проверяет (HEAD) каждую внешнюю ссылку обхода
+	// ровно один раз, даже если на неё ссылаются многие страницы, и
+	// записывает результат в Report.ExternalLinks — и рабочие, и битые
+	// ссылки. Внешние сайты при этом не обходятся.
+	ValidateExternalLinks bool
+	// AssetCacheTTL — через сколько повторно проверять ассет, уже проверенный
+	// в этом обходе. 0 — результат проверки кэшируется до конца обхода.
+	AssetCacheTTL time.Duration
+	// CacheTTL — сколько хранить ответы GET и HEAD (страницы, проверки ссылок,
+	// ассеты) в памяти: повторный запрос того же URL в пределах CacheTTL,
+	// в том же обходе или в следующем Crawler.Run, не уходит в сеть и не
+	// учитывается в total_requests. Ответы 5xx, 429 и 304 не кэшируются.
+	// 0 — без кэша.
+	CacheTTL time.Duration
+	// RetryBackoff — пауза перед первым повтором запроса; удваивается с каждой
+	// попыткой (не больше 30s), с разбросом ±20%. Retry-After в ответе 429/503
+	// (тоже не больше 30s) используется вместо неё. 0 — 100ms.
+	RetryBackoff time.Duration
+	// MaxConcurrentHosts — сколько разных хостов (поддомены, внешние ссылки)
+	// могут одновременно обрабатывать запросы, независимо от Concurrency.
+	// 0 — без ограничения.
+	MaxConcurrentHosts int
+	// Progress получает ProgressEvent после каждой обработанной страницы.
+	// Отправка не блокирует обход: если получатель не успевает, событие
+	// отбрасывается. Канал не закрывается пакетом. nil отключает прогресс.
+	Progress chan<- ProgressEvent
+	// ShouldCrawl вызывается для каждой внутренней ссылки после проверок
+	// домена, фильтров Include/Exclude и посещённых URL; false — ссылка
+	// не ставится в очередь. nil — обходятся все ссылки.
+	ShouldCrawl func(u *url.URL, depth int) bool
+	// ShouldCheckAsset решает, проверять ли ассет страницы; false — ассет
+	// не запрашивается и не попадает в отчёт. nil — проверяются все ассеты.
+	ShouldCheckAsset func(u *url.URL, assetType string) bool
+	// PreprocessHTML получает адрес страницы после редиректов и тело ответа
+	// и возвращает HTML, из которого извлекаются SEO, ссылки, ассеты и
+	// canonical (например, чтобы развернуть экранированный HTML из JSON
+	// SPA-оболочки или убрать известный шум). Архив, DedupeByContent и
+	// Soft404Patterns работают с исходным телом. Вызывается из нескольких
+	// воркеров одновременно. nil — HTML не меняется.
+	PreprocessHTML func(url string, html string) string
+	// AssetsSameDomainOnly проверяет только ассеты с домена страницы:
+	// сторонние (CDN, аналитика, реклама) не запрашиваются и не попадают
+	// в отчёт. Действует вместе с ShouldCheckAsset.
+	AssetsSameDomainOnly bool
+	// MaxAssetsPerPage — сколько ассетов страницы проверять: остаются первые
+	// в порядке документа (после ShouldCheckAsset и AssetsSameDomainOnly),
+	// а у страницы выставляется assets_truncated. 0 — без ограничения.
+	MaxAssetsPerPage int
+	// ParseCSS разбирает таблицы стилей страницы: адреса из url() и @import
+	// (шрифты, изображения, вложенные стили) проверяются как ассеты
+	// с origin = "css-referenced". data:-URI пропускаются.
+	ParseCSS bool
+	// IsBrokenStatus решает, считать ли ссылку с таким HTTP-статусом битой
+	// (например, не считать 401/403 у закрытых разделов). Ошибки сети битые
+	// всегда. nil — битые все статусы вне 200–399.
+	IsBrokenStatus func(statusCode int) bool
+	// SortAssets — порядок ассетов страницы: "document" (как в HTML,
+	// по умолчанию), "type" (по типу, внутри типа как в HTML) или "url"
+	SortAssets string
+	// DetectDuplicateIDs записывает в duplicate_ids значения атрибута id,
+	// повторяющиеся на странице (ломают якоря и доступность)
+	DetectDuplicateIDs bool
+	// StripParams — query-параметры, удаляемые из найденных URL перед
+	// постановкой в очередь, чтобы /p?utm_source=x и /p?utm_source=y
+	// считались одной страницей. Поддерживаются шаблоны ("utm_*");
+	// типичный набор — DefaultStripParams. nil — ничего не удалять.
+	StripParams []string
+	// CanonicalizeURL вычисляет ключ, по которому URL страниц считаются
+	// посещёнными, а ассеты — уже проверенными. URL с одинаковым ключом
+	// обходятся один раз. Сам запрашиваемый URL ключ не меняет.
+	// nil — ключом служит нормализованный URL (без fragment и "/" в конце).
+	CanonicalizeURL func(u *url.URL) string
+	// CaseInsensitivePaths считает пути, отличающиеся только регистром
+	// (/About и /about), одной страницей. Хост и порядок query-параметров
+	// нормализуются всегда; регистр пути — только с этой опцией, так как
+	// многие серверы различают его.
+	CaseInsensitivePaths bool
+	// DetectSessionIDs удаляет из найденных ссылок параметры с идентификатором
+	// сессии (чтобы /p?jsessionid=X и /p были одной страницей) и помечает такие
+	// страницы флагом session_id_in_url
+	DetectSessionIDs bool
+	// SessionIDParams — имена параметров сессии (без учёта регистра, можно
+	// шаблоны). nil — jsessionid, phpsessid, sid.
+	SessionIDParams []string
+	// MaxRequests — жёсткий предел общего числа HTTP-запросов (страницы,
+	// проверки ссылок, ассеты, sitemap). При попытке его превысить обход
+	// останавливается, а в отчёте stop_reason = "max_requests". 0 — без предела.
+	MaxRequests int
+	// CrawlTimeout — предел длительности всего обхода. По его истечении
+	// обход останавливается, в отчёте timed_out = true и
+	// stop_reason = "timeout". 0 — без предела.
+	CrawlTimeout time.Duration
+	// StartJitter — верхняя граница случайной паузы перед первой страницей
+	// каждого из Concurrency воркеров: сглаживает всплеск запросов в начале
+	// обхода независимо от Delay. 0 — без паузы.
+	StartJitter time.Duration
+	// MaxURLLength — ссылки длиннее (в байтах, после нормализации) не
+	// обходятся. Защищает от ловушек, порождающих бесконечные URL. 0 — без предела.
+	MaxURLLength int
+	// MaxQueryParams — ссылки с большим числом параметров query не обходятся
+	// (календари, фасетный поиск). 0 — без предела.
+	MaxQueryParams int
+	// RecordSkippedURLs записывает в отчёт (skipped_urls) ссылки, отброшенные
+	// по MaxURLLength и MaxQueryParams, с причиной
+	RecordSkippedURLs bool
+	// PathPrefix ограничивает обход страницами, путь которых начинается
+	// с этого префикса (например, "/docs/"). Пустая строка — весь домен.
+	PathPrefix string
+	// ConfineToSeedPath при пустом PathPrefix берёт префикс из корневого URL:
+	// каталог его пути (для https://site.com/docs/intro — "/docs/")
+	ConfineToSeedPath bool
+	// MaxIdleConnsPerHost — сколько простаивающих соединений с хостом держит
+	// клиент по умолчанию (0 — httputil.DefaultMaxIdleConnsPerHost).
+	// Нельзя задавать вместе с HTTPClient или Transport.
+	MaxIdleConnsPerHost int
+	// HostOverrides — IP-адреса для имён хостов (hostname → IP) вместо DNS,
+	// например для обхода сайта на staging-сервере до переключения DNS.
+	// Нельзя задавать вместе с HTTPClient или Transport.
+	HostOverrides map[string]string
+	// InsecureSkipVerify отключает проверку TLS-сертификатов (например, для
+	// внутренних стендов с самоподписанными сертификатами). Нельзя задавать
+	// вместе с HTTPClient или Transport.
+	InsecureSkipVerify bool
+	// ConnectTimeout ограничивает установку соединения (TCP и, отдельно,
+	// TLS-рукопожатие), ResponseHeaderTimeout — ожидание заголовков ответа
+	// после отправки запроса. Чтение тела ограничено только общим Timeout,
+	// поэтому сервер, долго не отвечающий, отсекается быстро, а большие
+	// страницы загружаются целиком. 0 — таймауты подключения
+	// http.DefaultTransport и без предела на заголовки.
+	// Нельзя задавать вместе с HTTPClient или Transport.
+	ConnectTimeout        time.Duration
+	ResponseHeaderTimeout time.Duration
+	// ClientCert и ClientKey — пути к PEM-файлам клиентского сертификата
+	// и его ключа для mTLS; задаются вместе. Нельзя задавать вместе
+	// с HTTPClient или Transport.
+	ClientCert string
+	ClientKey  string
+	// Proxy — URL прокси для всех запросов (страницы, ссылки, ассеты):
+	// http://, https:// или socks5://. Пусто — прокси из переменных
+	// окружения. Нельзя задавать вместе с HTTPClient или Transport.
+	Proxy string
+	// UseCookieJar сохраняет cookie, установленные сайтом во время обхода
+	// (например, сессионные), и отправляет их в следующих запросах.
+	// Хранилище общее для всех запусков Crawler. Нельзя задавать вместе
+	// с HTTPClient.
+	UseCookieJar bool
+	// MaxBodyBytes — сколько байт тела страницы или ассета без Content-Length
+	// читается не больше; длинные страницы помечаются truncated
+	// (0 — DefaultMaxBodyBytes, 10 МБ)
+	MaxBodyBytes int64
+	// ArchiveDir — каталог, куда сохраняется HTML успешно загруженных страниц
+	// (после распаковки, в исходной кодировке). Имя файла — sha256 от ключа
+	// страницы, путь записывается в archive_path. У страницы с truncated
+	// в файле только первые MaxBodyBytes байт. Пустая строка — не сохранять.
+	ArchiveDir string
+	// DedupeByContent помечает страницу статусом duplicate, если её HTML
+	// (без учёта пробелов) совпадает с уже обойдённой страницей: canonical_of
+	// указывает на первую такую страницу, ссылки дубликата не обходятся
+	DedupeByContent bool
+	// IncludeSubdomains обходит поддомены регистрируемого домена корня
+	// (www.example.com, blog.example.com, shop.example.com) как один сайт
+	IncludeSubdomains bool
+	// AllowedHosts — шаблоны path.Match дополнительных хостов, которые
+	// обходятся как часть сайта, например "*.example.com", "cdn.example.org".
+	// Некорректный шаблон — ошибка Analyze.
+	AllowedHosts []string
+	// IncludeAllLinks записывает для каждой страницы все её ссылки (Page.Links,
+	// без повторов, в порядке документа) и число внутренних и внешних из них
+	IncludeAllLinks bool
+	// CaptureHeaders — имена заголовков ответа (например,
+	// Content-Security-Policy, Strict-Transport-Security), которые
+	// записываются в Page.Headers. Берётся последний ответ после редиректов.
+	CaptureHeaders []string
+	// SeedURLs — дополнительные стартовые URL (глубина 0) вместе с URL.
+	// Все они должны относиться к сайту URL, иначе Analyze вернёт ошибку;
+	// совпадающие URL обходятся один раз.
+	SeedURLs []string
+	// HeadFirst перед загрузкой страницы отправляет HEAD: если ответ 2xx и
+	// Content-Type не text/html или Content-Length больше MaxBodyBytes, статус
+	// берётся из HEAD, а GET не выполняется (страница помечается head_only).
+	// Если сервер не поддерживает HEAD (ошибка или не-2xx), страница
+	// загружается обычным GET.
+	HeadFirst bool
+	// ConditionalStore хранит ETag / Last-Modified страниц между обходами.
+	// При повторном Crawler.Run страница с валидаторами запрашивается
+	// с If-None-Match / If-Modified-Since, ответ 304 даёт статус
+	// not_modified, а данные и ссылки берутся из прошлого запуска. Страницы,
+	// которых нет в памяти этого Crawler (первый Run, новый вызов Analyze),
+	// загружаются полностью. nil — условные запросы не отправляются.
+	ConditionalStore ConditionalStore
+	// PageSink получает каждую страницу вместо списка в памяти — для очень
+	// больших обходов. Отчёт тогда содержит сводку и служебные поля, а pages
+	// пуст; DetectSessionIDs не отмечает уже переданные страницы. Ошибка
+	// PageSink останавливает обход, и Analyze / Run возвращают её.
+	// nil — страницы хранятся в отчёте.
+	PageSink PageSink
+	// MaxQueueSize ограничивает число URL, ожидающих обхода, чтобы очередь
+	// огромного сайта не исчерпала память. Что делать при заполнении,
+	// задаёт QueueFullPolicy. Стартовые URL (корень, SeedURLs, sitemap)
+	// ставятся в очередь при QueueFullBlock сверх предела. 0 — без предела.
+	MaxQueueSize int
+	// QueueFullPolicy — политика заполненной очереди: QueueFullDrop (по
+	// умолчанию) отбрасывает самые глубокие URL (skipped_urls с причиной
+	// queue_full при RecordSkippedURLs), QueueFullBlock заставляет воркер
+	// ждать, пока очередь не разберут
+	QueueFullPolicy string
+
+	includeRe []*regexp.Regexp
+	excludeRe []*regexp.Regexp
+	soft404Re []*regexp.Regexp
 }
 
 type (
-	Report     = report.Report
-	Page       = report.Page
-	BrokenLink = checker.BrokenLink
-	SEO        = seo.SEO
-	Asset      = checker.Asset
+	Report      = report.Report
+	Page        = report.Page
+	BrokenLink  = checker.BrokenLink
+	CheckedLink = checker.CheckedLink
+	SEO         = seo.SEO
+	Asset       = checker.Asset
+	PageChunk   = report.PageChunk
+	SkippedURL  = report.SkippedURL
+	PageSink    = report.PageSink
+	HostInfo    = report.HostInfo
+)
+
+// ProgressEvent отправляется в Options.Progress после добавления каждой страницы в отчёт
+type ProgressEvent struct {
+	URL   string
+	Depth int
+	// Processed — сколько страниц уже добавлено в отчёт
+	Processed int
+	// Queued — сколько URL ожидают обхода в очереди
+	Queued int
+}
+
+// DefaultStripParams — типичные параметры отслеживания для Options.StripParams
+var DefaultStripParams = append([]string(nil), urlutil.DefaultStripParams...)
+
+// DefaultIsBrokenStatus — правило Options.IsBrokenStatus по умолчанию:
+// битые ссылки со статусом вне 200–399
+func DefaultIsBrokenStatus(statusCode int) bool {
+	return checker.DefaultIsBrokenStatus(statusCode)
+}
+
+// ConditionalStore — хранилище валидаторов страниц для условных запросов.
+// Методы вызываются из нескольких воркеров одновременно.
+type ConditionalStore interface {
+	// Get возвращает сохранённые ETag и Last-Modified (пустые, если их нет)
+	Get(url string) (etag, lastModified string)
+	// Set сохраняет валидаторы успешно загруженной страницы
+	Set(url, etag, lastModified string)
+}
+
+// StopReasonMaxRequests — обход остановлен по достижении Options.MaxRequests
+const StopReasonMaxRequests = "max_requests"
+
+// StopReasonTimeout — обход остановлен по истечении Options.CrawlTimeout
+const StopReasonTimeout = "timeout"
+
+// StopReasonCanceled — обход прерван отменой контекста (например, по SIGINT);
+// в отчёте есть страницы, обработка которых успела завершиться
+const StopReasonCanceled = "canceled"
+
+// Причины пропуска ссылок в Report.SkippedURLs
+const (
+	SkipReasonURLTooLong         = report.SkipReasonURLTooLong
+	SkipReasonTooManyQueryParams = report.SkipReasonTooManyQueryParams
+	SkipReasonQueueFull          = report.SkipReasonQueueFull
+)
+
+// Политики Options.QueueFullPolicy
+const (
+	QueueFullDrop  = state.QueueFullDrop
+	QueueFullBlock = state.QueueFullBlock
 )
 
-func normalizeOptions(opts *Options) {
+// AssetOriginCSS — значение Asset.Origin у ассетов из таблиц стилей (Options.ParseCSS)
+const AssetOriginCSS = checker.AssetOriginCSS
+
+// SchemaVersion — версия формата JSON-отчёта
+const SchemaVersion = report.SchemaVersion
+
+// DefaultMaxBodyBytes — значение Options.MaxBodyBytes по умолчанию
+const DefaultMaxBodyBytes = httputil.DefaultMaxBodyBytes
+
+var errMultipleAuth = errors.New("only one auth mode can be used: basic auth or bearer token")
+
+var errClientAndTransport = errors.New("only one of HTTPClient and Transport can be set")
+
+// checkClientOptions отклоняет настройки клиента по умолчанию, которые
+// не действуют при собственном HTTPClient или Transport
+func checkClientOptions(opts *Options) error {
+	custom := "HTTPClient"
+	if opts.HTTPClient == nil {
+		if opts.Transport == nil {
+			return nil
+		}
+		custom = "Transport"
+	}
+
+	conflicts := []struct {
+		name string
+		set  bool
+	}{
+		{"Proxy", opts.Proxy != ""},
+		{"InsecureSkipVerify", opts.InsecureSkipVerify},
+		{"ClientCert", opts.ClientCert != "" || opts.ClientKey != ""},
+		{"HostOverrides", len(opts.HostOverrides) > 0},
+		{"MaxIdleConnsPerHost", opts.MaxIdleConnsPerHost != 0},
+		{"ConnectTimeout", opts.ConnectTimeout != 0},
+		{"ResponseHeaderTimeout", opts.ResponseHeaderTimeout != 0},
+		// Хранилище cookie подключает клиент краулера, поэтому с Transport оно работает
+		{"UseCookieJar", opts.UseCookieJar && opts.HTTPClient != nil},
+	}
+	for _, conflict := range conflicts {
+		if conflict.set {
+			return fmt.Errorf("%s cannot be used with %s", conflict.name, custom)
+		}
+	}
+	return nil
+}
+
+func normalizeOptions(opts *Options) error {
+	if opts.BasicAuthUser != "" && opts.BearerToken != "" {
+		return errMultipleAuth
+	}
+	if opts.HTTPClient != nil && opts.Transport != nil {
+		return errClientAndTransport
+	}
+	if err := checkClientOptions(opts); err != nil {
+		return err
+	}
+	if opts.SinglePage {
+		opts.Depth = 0
+	}
+
+	var err error
+	if opts.includeRe, err = compilePatterns(opts.Include); err != nil {
+		return fmt.Errorf("invalid include pattern: %w", err)
+	}
+	if opts.excludeRe, err = compilePatterns(opts.Exclude); err != nil {
+		return fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+	if opts.soft404Re, err = compilePatterns(opts.Soft404Patterns); err != nil {
+		return fmt.Errorf("invalid soft 404 pattern: %w", err)
+	}
+
+	for _, pattern := range opts.AllowedHosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allowed host pattern %q: %w", pattern, err)
+		}
+	}
+
+	switch opts.SortAssets {
+	case "":
+		opts.SortAssets = checker.AssetOrderDocument
+	case checker.AssetOrderDocument, checker.AssetOrderType, checker.AssetOrderURL:
+	default:
+		return fmt.Errorf("invalid asset order %q: expected document, type or url", opts.SortAssets)
+	}
+
+	switch opts.QueueFullPolicy {
+	case "":
+		opts.QueueFullPolicy = QueueFullDrop
+	case QueueFullDrop, QueueFullBlock:
+	default:
+		return fmt.Errorf("invalid queue full policy %q: expected drop or block", opts.QueueFullPolicy)
+	}
+
 	if opts.HTTPClient == nil {
-		opts.HTTPClient = &http.Client{}
+		cfg, err := clientConfig(opts)
+		if err != nil {
+			return err
+		}
+		opts.HTTPClient = httputil.NewClientWithConfig(cfg)
 	}
 	if opts.Concurrency <= 0 {
 		opts.Concurrency = 4
@@ -42,4 +504,123 @@ func normalizeOptions(opts *Options) {
 	if opts.Timeout <= 0 {
 		opts.Timeout = 15 * time.Second
 	}
+	if opts.DetectSessionIDs && opts.SessionIDParams == nil {
+		opts.SessionIDParams = urlutil.DefaultSessionIDParams
+	}
+
+	return nil
+}
+
+// clientConfig собирает настройки HTTP-клиента по умолчанию из опций
+func clientConfig(opts *Options) (httputil.ClientConfig, error) {
+	cfg := httputil.ClientConfig{
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		HostOverrides:         opts.HostOverrides,
+		InsecureSkipVerify:    opts.InsecureSkipVerify,
+		ConnectTimeout:        opts.ConnectTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		Transport:             opts.Transport,
+	}
+
+	if opts.Proxy != "" {
+		proxy, err := httputil.ParseProxyURL(opts.Proxy)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Proxy = proxy
+	}
+
+	if opts.UseCookieJar {
+		jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		if err != nil {
+			return cfg, fmt.Errorf("failed to create cookie jar: %w", err)
+		}
+		cfg.Jar = jar
+	}
+
+	if (opts.ClientCert == "") != (opts.ClientKey == "") {
+		return cfg, errors.New("client certificate and key must be set together")
+	}
+	if opts.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// seedPathPrefix возвращает каталог пути корневого URL — часть до последнего "/"
+func seedPathPrefix(rootURL *url.URL) string {
+	p := rootURL.EscapedPath()
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i+1]
+	}
+	return "/"
+}
+
+// isSiteURL сообщает, что URL относится к обходимому сайту: к домену корня
+// (с IncludeSubdomains — и его поддоменам) или к одному из AllowedHosts
+func (opts *Options) isSiteURL(u, rootURL *url.URL) bool {
+	return urlutil.IsSameSite(u, rootURL, opts.IncludeSubdomains) || urlutil.MatchesHost(u, opts.AllowedHosts)
+}
+
+// matchesPathPrefix проверяет, что путь URL начинается с PathPrefix
+func (opts *Options) matchesPathPrefix(u *url.URL) bool {
+	if opts.PathPrefix == "" {
+		return true
+	}
+
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if opts.CaseInsensitivePaths {
+		return strings.HasPrefix(strings.ToLower(p), strings.ToLower(opts.PathPrefix))
+	}
+	return strings.HasPrefix(p, opts.PathPrefix)
+}
+
+// matchesFilters проверяет URL по спискам Include/Exclude
+func (opts *Options) matchesFilters(urlStr string) bool {
+	for _, re := range opts.excludeRe {
+		if re.MatchString(urlStr) {
+			return false
+		}
+	}
+
+	if len(opts.includeRe) == 0 {
+		return true
+	}
+
+	for _, re := range opts.includeRe {
+		if re.MatchString(urlStr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSoft404 проверяет тело страницы по Soft404Patterns
+func (opts *Options) isSoft404(body string) bool {
+	for _, re := range opts.soft404Re {
+		if re.MatchString(body) {
+			return true
+		}
+	}
+	return false
 }
@@ -2,4 +2,9 @@ module code
 
 go 1.24.3
 
-require golang.org/x/net v0.31.0
+require (
+	github.com/andybalholm/brotli v1.2.0
+	golang.org/x/net v0.31.0
+)
+
+require golang.org/x/text v0.21.0 // indirect
@@ -0,0 +1,60 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Archiver сохраняет HTML страниц в каталог. Имя файла — sha256 от ключа
+// страницы, поэтому один URL всегда попадает в один файл.
+// Безопасен для одновременного использования из нескольких воркеров.
+type Archiver struct {
+	dir string
+}
+
+func NewArchiver(dir string) *Archiver {
+	return &Archiver{dir: dir}
+}
+
+// FileName возвращает имя файла страницы с ключом key (относительно каталога)
+func FileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + ".html"
+}
+
+// Save записывает body в файл страницы и возвращает его путь относительно
+// каталога архива. Файл сначала пишется во временный и затем переименовывается,
+// чтобы параллельные записи не оставляли частично записанных файлов.
+func (a *Archiver) Save(key string, body []byte) (string, error) {
+	if err := os.MkdirAll(a.dir, 0o755); err != nil {
+		return "", fmt.Errorf("create archive dir: %w", err)
+	}
+
+	name := FileName(key)
+	tmp, err := os.CreateTemp(a.dir, name+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("archive page: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(body); err != nil {
+		_ = tmp.Close()
+		return "", fmt.Errorf("archive page: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("archive page: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", fmt.Errorf("archive page: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(a.dir, name)); err != nil {
+		return "", fmt.Errorf("archive page: %w", err)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,73 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSave(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "archive")
+	archiver := NewArchiver(dir)
+
+	name, err := archiver.Save("https://example.com/page", []byte("<html>page</html>"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != FileName("https://example.com/page") {
+		t.Fatalf("expected file name from key hash, got %s", name)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("read archived page: %v", err)
+	}
+	if string(content) != "<html>page</html>" {
+		t.Fatalf("unexpected archived content %q", content)
+	}
+
+	if FileName("https://example.com/other") == name {
+		t.Fatal("expected different URLs to get different files")
+	}
+}
+
+func TestSaveConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	archiver := NewArchiver(dir)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Половина воркеров пишет один и тот же ключ
+			key := fmt.Sprintf("https://example.com/%d", i%10)
+			if _, err := archiver.Save(key, []byte(key)); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 10 {
+		t.Fatalf("expected 10 archived files without temp leftovers, got %d", len(entries))
+	}
+}
+
+func TestSaveError(t *testing.T) {
+	// Каталог архива нельзя создать: на его месте файл
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewArchiver(file).Save("https://example.com", []byte("x")); err == nil {
+		t.Fatal("expected error when archive dir is a file")
+	}
+}
@@ -6,11 +6,16 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"path"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"code/internal/cssparse"
 	"code/internal/httputil"
 	"code/internal/parser"
+	"code/internal/urlutil"
 )
 
 // Asset содержит информацию об ассете (изображение, скрипт, стиль)
@@ -18,16 +23,42 @@ type Asset struct {
 	URL        string `json:"url"`
 	Type       string `json:"type"`
 	StatusCode int    `json:"status_code"`
-	SizeBytes  int64  `json:"size_bytes"`
-	Error      string `json:"error,omitempty"`
+	// StatusText — строка статуса ответа, например "404 Not Found"
+	StatusText string `json:"status_text,omitempty"`
+	// SizeBytes — размер содержимого; для сжатого ответа — после распаковки
+	SizeBytes int64 `json:"size_bytes"`
+	// CompressedSizeBytes — размер сжатого тела при Content-Encoding (иначе 0)
+	CompressedSizeBytes int64 `json:"compressed_size_bytes,omitempty"`
+	// Truncated — тело длиннее MaxBodyBytes и дочитано не до конца;
+	// SizeBytes равен лимиту
+	Truncated bool `json:"truncated,omitempty"`
+	// MixedContent — ассет загружается по http со страницы, открытой по https
+	MixedContent bool   `json:"mixed_content,omitempty"`
+	Error        string `json:"error,omitempty"`
+	// FoundOn — страница, на которой найден ассет. Заполняется для каждой
+	// страницы отдельно и не хранится в кэше проверок.
+	FoundOn string `json:"found_on,omitempty"`
+	// Origin — откуда взят ассет: пусто — из HTML страницы,
+	// AssetOriginCSS — из url() или @import таблицы стилей
+	Origin string `json:"origin,omitempty"`
 }
 
-type AssetResult struct {
-	URL        string
-	Type       string
+// AssetOriginCSS — ассет найден в таблице стилей страницы (SetParseCSS)
+const AssetOriginCSS = "css-referenced"
+
+// assetNetResult — результат сетевой проверки ассета. Только он хранится
+// в кэше: поля, зависящие от страницы (MixedContent, FoundOn), для каждой
+// страницы вычисляются заново.
+type assetNetResult struct {
 	StatusCode int
+	StatusText string
 	SizeBytes  int64
-	Error      error
+	// CompressedSizeBytes — размер тела до распаковки (0 — ответ не сжат)
+	CompressedSizeBytes int64
+	Truncated           bool
+	Error               error
+	// CSSRefs — адреса из url() и @import таблицы стилей (только с SetParseCSS)
+	CSSRefs []string
 }
 
 // AssetChecker проверяет ассеты и кэширует результаты
@@ -35,8 +66,38 @@ type AssetChecker struct {
 	fetcher    *httputil.Fetcher
 	parser     *parser.HTMLParser
 	workers    int
-	cache      map[string]Asset
+	cache      map[string]cachedAsset
 	cacheMutex sync.RWMutex
+	// cacheTTL — время жизни записи кэша; 0 — кэшировать до конца обхода
+	cacheTTL time.Duration
+	now      func() time.Time
+	// filter решает, проверять ли ассет (nil — проверять все)
+	filter func(u *url.URL, assetType string) bool
+	// sameDomainOnly — проверять только ассеты с домена страницы
+	sameDomainOnly bool
+	// cacheKey вычисляет ключ кэша по URL ассета (nil — сам URL)
+	cacheKey func(u *url.URL) string
+	// order — порядок ассетов в результате CheckAssets
+	order string
+	// maxAssets — сколько ассетов страницы проверять (0 — без ограничения)
+	maxAssets int
+	// parseCSS — проверять ассеты, на которые ссылаются таблицы стилей
+	parseCSS bool
+}
+
+// Порядок ассетов страницы в результате CheckAssets
+const (
+	// AssetOrderDocument — в порядке появления в HTML (по умолчанию)
+	AssetOrderDocument = "document"
+	// AssetOrderType — по типу, внутри типа в порядке документа
+	AssetOrderType = "type"
+	// AssetOrderURL — по URL
+	AssetOrderURL = "url"
+)
+
+type cachedAsset struct {
+	result    assetNetResult
+	checkedAt time.Time
 }
 
 func NewAssetChecker(fetcher *httputil.Fetcher, htmlParser *parser.HTMLParser, workers int) *AssetChecker {
@@ -44,23 +105,105 @@ func NewAssetChecker(fetcher *httputil.Fetcher, htmlParser *parser.HTMLParser, w
 		fetcher: fetcher,
 		parser:  htmlParser,
 		workers: workers,
-		cache:   make(map[string]Asset),
+		cache:   make(map[string]cachedAsset),
+		now:     time.Now,
 	}
 }
 
+// SetFilter задаёт функцию, отбирающую ассеты для проверки.
+// Ассеты, для которых она возвращает false, не проверяются и не попадают в отчёт.
+func (ac *AssetChecker) SetFilter(filter func(u *url.URL, assetType string) bool) {
+	ac.filter = filter
+}
+
+// SetSameDomainOnly включает проверку только ассетов с того же домена,
+// что и страница: сторонние (CDN, счётчики, реклама) не запрашиваются
+// и не попадают в отчёт
+func (ac *AssetChecker) SetSameDomainOnly(sameDomainOnly bool) {
+	ac.sameDomainOnly = sameDomainOnly
+}
+
+// SetCacheKey задаёт функцию, по которой вычисляется ключ кэша:
+// ассеты с одинаковым ключом проверяются один раз
+func (ac *AssetChecker) SetCacheKey(cacheKey func(u *url.URL) string) {
+	ac.cacheKey = cacheKey
+}
+
+// SetCacheTTL задаёт время, после которого закэшированный ассет проверяется
+// заново. 0 (по умолчанию) — результат кэшируется до конца обхода.
+func (ac *AssetChecker) SetCacheTTL(ttl time.Duration) {
+	ac.cacheTTL = ttl
+}
+
+// SetOrder задаёт порядок ассетов в результате CheckAssets
+// (AssetOrderDocument, AssetOrderType или AssetOrderURL). Пустая строка —
+// AssetOrderDocument.
+func (ac *AssetChecker) SetOrder(order string) {
+	ac.order = order
+}
+
+// SetMaxAssets ограничивает число проверяемых ассетов страницы: остаются
+// первые n в порядке документа (после фильтров), затем ассеты из таблиц
+// стилей. n <= 0 — без ограничения.
+func (ac *AssetChecker) SetMaxAssets(n int) {
+	ac.maxAssets = n
+}
+
+// SetParseCSS включает разбор таблиц стилей страницы: адреса из url()
+// и @import проверяются как ассеты с Origin = AssetOriginCSS
+func (ac *AssetChecker) SetParseCSS(parseCSS bool) {
+	ac.parseCSS = parseCSS
+}
+
 type assetWithIndex struct {
 	asset Asset
-	index int
+	refs  []string
+	// interrupted — проверка прервана остановкой обхода
+	interrupted bool
+	index       int
 }
 
-// CheckAssets извлекает и проверяет все ассеты на странице
-func (ac *AssetChecker) CheckAssets(ctx context.Context, htmlContent string, pageURL *url.URL) []Asset {
-	assetInfos := ac.parser.ExtractAssets(htmlContent, pageURL)
+// CheckAssets извлекает и проверяет все ассеты на странице. truncated —
+// ассетов больше лимита SetMaxAssets и лишние не проверялись.
+func (ac *AssetChecker) CheckAssets(ctx context.Context, htmlContent string, pageURL *url.URL) (assets []Asset, truncated bool) {
+	assetInfos := ac.filterAssets(ac.parser.ExtractAssets(htmlContent, pageURL), pageURL)
+
+	seen := make(map[string]bool, len(assetInfos))
+	for _, info := range assetInfos {
+		seen[info.URL] = true
+	}
+
+	assets = []Asset{}
+	origin := ""
+	for {
+		if ac.maxAssets > 0 && len(assets)+len(assetInfos) > ac.maxAssets {
+			assetInfos = assetInfos[:ac.maxAssets-len(assets)]
+			truncated = true
+		}
+		if len(assetInfos) == 0 {
+			break
+		}
+
+		checked, refs := ac.checkInfos(ctx, assetInfos, pageURL)
+		for i := range checked {
+			checked[i].Origin = origin
+		}
+		assets = append(assets, checked...)
 
-	if len(assetInfos) == 0 {
-		return []Asset{}
+		// Ассеты из таблиц стилей проверяются следующим кругом; таблицы
+		// из @import тоже разбираются, пока не кончатся новые адреса
+		assetInfos = ac.filterAssets(cssAssetInfos(refs, seen), pageURL)
+		origin = AssetOriginCSS
 	}
 
+	ac.sortAssets(assets)
+	return assets, truncated
+}
+
+// checkInfos проверяет ассеты параллельно и возвращает их в исходном порядке
+// вместе с адресами из проверенных таблиц стилей. Ассеты, проверка которых
+// прервана остановкой обхода, не возвращаются.
+func (ac *AssetChecker) checkInfos(ctx context.Context, assetInfos []parser.AssetInfo, pageURL *url.URL) ([]Asset, []string) {
 	semaphore := make(chan struct{}, ac.workers)
 	resultChan := make(chan assetWithIndex, len(assetInfos))
 	var wg sync.WaitGroup
@@ -73,8 +216,13 @@ func (ac *AssetChecker) CheckAssets(ctx context.Context, htmlContent string, pag
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			asset := ac.checkSingleAsset(ctx, assetURL, assetType)
-			resultChan <- assetWithIndex{asset: asset, index: index}
+			asset, result := ac.checkSingleAsset(ctx, assetURL, assetType, pageURL)
+			resultChan <- assetWithIndex{
+				asset:       asset,
+				refs:        result.CSSRefs,
+				interrupted: interrupted(ctx, result.Error),
+				index:       index,
+			}
 		}(i, info.URL, info.AssetType)
 	}
 
@@ -83,82 +231,186 @@ func (ac *AssetChecker) CheckAssets(ctx context.Context, htmlContent string, pag
 		close(resultChan)
 	}()
 
-	results := make(map[int]Asset)
+	results := make(map[int]assetWithIndex)
 	for result := range resultChan {
-		results[result.index] = result.asset
+		results[result.index] = result
 	}
 
-	assets := make([]Asset, len(assetInfos))
+	assets := make([]Asset, 0, len(assetInfos))
+	var refs []string
 	for i := 0; i < len(assetInfos); i++ {
-		assets[i] = results[i]
+		if results[i].interrupted {
+			continue
+		}
+		assets = append(assets, results[i].asset)
+		refs = append(refs, results[i].refs...)
 	}
+	return assets, refs
+}
 
-	sort.SliceStable(assets, func(i, j int) bool {
-		return assets[i].Type < assets[j].Type
-	})
+// cssAssetInfos превращает адреса из таблиц стилей в ассеты, пропуская уже
+// встреченные на странице. Тип определяется по расширению файла.
+func cssAssetInfos(refs []string, seen map[string]bool) []parser.AssetInfo {
+	var infos []parser.AssetInfo
+	for _, ref := range refs {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		infos = append(infos, parser.AssetInfo{URL: ref, AssetType: cssAssetType(ref)})
+	}
+	return infos
+}
 
-	return assets
+func cssAssetType(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "image"
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".css":
+		return "style"
+	case ".woff", ".woff2", ".ttf", ".otf", ".eot":
+		return "font"
+	default:
+		return "image"
+	}
 }
 
-func (ac *AssetChecker) checkSingleAsset(ctx context.Context, assetURL, assetType string) Asset {
-	ac.cacheMutex.RLock()
-	cached, found := ac.cache[assetURL]
-	ac.cacheMutex.RUnlock()
+// sortAssets упорядочивает ассеты по ac.order; исходный порядок — порядок документа
+func (ac *AssetChecker) sortAssets(assets []Asset) {
+	switch ac.order {
+	case AssetOrderType:
+		sort.SliceStable(assets, func(i, j int) bool {
+			return assets[i].Type < assets[j].Type
+		})
+	case AssetOrderURL:
+		sort.SliceStable(assets, func(i, j int) bool {
+			return assets[i].URL < assets[j].URL
+		})
+	}
+}
 
-	if found {
-		return cached
+func (ac *AssetChecker) filterAssets(infos []parser.AssetInfo, pageURL *url.URL) []parser.AssetInfo {
+	sameDomainOnly := ac.sameDomainOnly && pageURL != nil
+	if ac.filter == nil && !sameDomainOnly {
+		return infos
 	}
 
-	result := ac.fetchAsset(ctx, assetURL)
+	filtered := infos[:0]
+	for _, info := range infos {
+		assetURL, err := url.Parse(info.URL)
+		if err != nil {
+			continue
+		}
+		if sameDomainOnly && !urlutil.IsSameDomain(assetURL, pageURL) {
+			continue
+		}
+		if ac.filter == nil || ac.filter(assetURL, info.AssetType) {
+			filtered = append(filtered, info)
+		}
+	}
+	return filtered
+}
+
+// checkSingleAsset собирает Asset для страницы pageURL из результата
+// сетевой проверки (из кэша или нового запроса) и полей этой страницы.
+// Возвращает и сам результат проверки (адреса из таблицы стилей, ошибку).
+func (ac *AssetChecker) checkSingleAsset(ctx context.Context, assetURL, assetType string, pageURL *url.URL) (Asset, assetNetResult) {
+	result := ac.cachedResult(ctx, assetURL, assetType)
 
 	asset := Asset{
-		URL:        assetURL,
-		Type:       assetType,
-		StatusCode: result.StatusCode,
-		SizeBytes:  result.SizeBytes,
+		URL:                 assetURL,
+		Type:                assetType,
+		StatusCode:          result.StatusCode,
+		StatusText:          result.StatusText,
+		SizeBytes:           result.SizeBytes,
+		CompressedSizeBytes: result.CompressedSizeBytes,
+		Truncated:           result.Truncated,
+		MixedContent:        urlutil.IsMixedContent(pageURL, assetURL),
 	}
-
 	if result.Error != nil {
 		asset.Error = result.Error.Error()
 	}
+	return asset, result
+}
+
+// cachedResult возвращает результат сетевой проверки ассета: из кэша, если
+// запись не устарела, иначе выполняет запрос и кэширует его результат
+func (ac *AssetChecker) cachedResult(ctx context.Context, assetURL, assetType string) assetNetResult {
+	key := ac.keyFor(assetURL)
+
+	ac.cacheMutex.RLock()
+	cached, found := ac.cache[key]
+	ac.cacheMutex.RUnlock()
+
+	if found && (ac.cacheTTL <= 0 || ac.now().Sub(cached.checkedAt) < ac.cacheTTL) {
+		if logger := ac.fetcher.Logger(); logger != nil {
+			logger.Debug("asset cache hit", "url", assetURL)
+		}
+		return cached.result
+	}
+
+	result := ac.fetchAsset(ctx, assetURL, assetType)
+
+	if logger := ac.fetcher.Logger(); logger != nil {
+		if result.Error != nil {
+			logger.Debug("asset check failed", "url", assetURL, "type", assetType, "status", result.StatusCode, "error", result.Error)
+		} else {
+			logger.Debug("asset checked", "url", assetURL, "type", assetType, "status", result.StatusCode)
+		}
+	}
+
+	// Прерванная проверка не кэшируется: ассет не проверен
+	if interrupted(ctx, result.Error) {
+		return result
+	}
 
 	ac.cacheMutex.Lock()
-	ac.cache[assetURL] = asset
+	ac.cache[key] = cachedAsset{result: result, checkedAt: ac.now()}
 	ac.cacheMutex.Unlock()
 
-	return asset
+	return result
 }
 
-func (ac *AssetChecker) fetchAsset(ctx context.Context, assetURL string) AssetResult {
+func (ac *AssetChecker) keyFor(assetURL string) string {
+	if ac.cacheKey == nil {
+		return assetURL
+	}
+	u, err := url.Parse(assetURL)
+	if err != nil {
+		return assetURL
+	}
+	return ac.cacheKey(u)
+}
+
+func (ac *AssetChecker) fetchAsset(ctx context.Context, assetURL, assetType string) assetNetResult {
 	if rl := ac.fetcher.RateLimiter(); rl != nil {
 		if !rl.Wait(ctx) {
-			return AssetResult{Error: ctx.Err()}
+			return assetNetResult{Error: ctx.Err()}
 		}
 	}
 
 	timeoutCtx, cancel := context.WithTimeout(ctx, ac.fetcher.Timeout())
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodGet, assetURL, nil)
+	req, err := ac.fetcher.NewRequest(timeoutCtx, http.MethodGet, assetURL)
 	if err != nil {
-		return AssetResult{Error: err}
+		return assetNetResult{Error: err}
 	}
 
-	if ac.fetcher.UserAgent() != "" {
-		req.Header.Set("User-Agent", ac.fetcher.UserAgent())
-	}
-
-	resp, err := ac.fetcher.Client().Do(req)
+	resp, err := ac.fetcher.Do(req)
 	if err != nil {
-		return AssetResult{Error: err}
+		return assetNetResult{Error: err}
 	}
 
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
-	result := AssetResult{
+	result := assetNetResult{
 		StatusCode: resp.StatusCode,
+		StatusText: statusLine(resp),
 	}
 
 	if resp.StatusCode >= 400 {
@@ -166,19 +418,133 @@ func (ac *AssetChecker) fetchAsset(ctx context.Context, assetURL string) AssetRe
 		return result
 	}
 
+	if ac.parseCSS && assetType == "style" {
+		return ac.readStylesheet(resp, req.URL, result)
+	}
+
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return ac.measureCompressed(resp, encoding, result)
+	}
+
 	contentLength := resp.ContentLength
 
 	if contentLength >= 0 {
 		result.SizeBytes = contentLength
 		_, _ = io.Copy(io.Discard, resp.Body)
 	} else {
-		body, err := io.ReadAll(resp.Body)
+		size, truncated, err := ac.readSize(resp.Body)
 		if err != nil {
 			result.Error = fmt.Errorf("failed to read body: %w", err)
 			return result
 		}
-		result.SizeBytes = int64(len(body))
+		result.SizeBytes = size
+		result.Truncated = truncated
+	}
+
+	return result
+}
+
+// measureCompressed распаковывает сжатое тело: SizeBytes — размер после
+// распаковки, CompressedSizeBytes — число байт, полученных по сети
+func (ac *AssetChecker) measureCompressed(resp *http.Response, encoding string, result assetNetResult) assetNetResult {
+	wire := &countingReader{r: resp.Body}
+	body, err := httputil.Decompress(encoding, wire)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to decode body: %w", err)
+		return result
+	}
+
+	size, truncated, err := ac.readSize(body)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to decode body: %w", err)
+		return result
+	}
+	if !truncated {
+		// Остаток тела (например, трейлер после данных) тоже передан по сети
+		_, _ = io.Copy(io.Discard, wire)
+	}
+
+	result.SizeBytes = size
+	result.CompressedSizeBytes = wire.n
+	result.Truncated = truncated
+	return result
+}
+
+// readStylesheet читает тело таблицы стилей (не больше MaxBodyBytes) и
+// извлекает из него адреса, разрешённые относительно итогового URL
+// таблицы после редиректов
+func (ac *AssetChecker) readStylesheet(resp *http.Response, requestURL *url.URL, result assetNetResult) assetNetResult {
+	wire := &countingReader{r: resp.Body}
+	body := io.Reader(wire)
+	encoding := resp.Header.Get("Content-Encoding")
+	compressed := encoding != "" && !strings.EqualFold(encoding, "identity")
+	if compressed {
+		decoded, err := httputil.Decompress(encoding, wire)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to decode body: %w", err)
+			return result
+		}
+		body = decoded
 	}
 
+	limit := ac.fetcher.MaxBodyBytes()
+	css, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		result.Error = fmt.Errorf("failed to read body: %w", err)
+		return result
+	}
+	if int64(len(css)) > limit {
+		css = css[:limit]
+		result.Truncated = true
+	}
+
+	result.SizeBytes = int64(len(css))
+	if compressed {
+		if !result.Truncated {
+			_, _ = io.Copy(io.Discard, wire)
+		}
+		result.CompressedSizeBytes = wire.n
+	}
+
+	base := requestURL
+	if resp.Request != nil && resp.Request.URL != nil {
+		base = resp.Request.URL
+	}
+	result.CSSRefs = cssparse.ExtractURLs(string(css), base)
 	return result
 }
+
+// readSize считает байты тела, читая не больше MaxBodyBytes фетчера.
+// truncated — тело длиннее лимита, size равен лимиту.
+func (ac *AssetChecker) readSize(body io.Reader) (size int64, truncated bool, err error) {
+	limit := ac.fetcher.MaxBodyBytes()
+	size, err = io.Copy(io.Discard, io.LimitReader(body, limit+1))
+	if err != nil {
+		return 0, false, err
+	}
+	if size > limit {
+		return limit, true, nil
+	}
+	return size, false, nil
+}
+
+// countingReader считает прочитанные байты
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// statusLine возвращает строку статуса ответа ("404 Not Found").
+// Если клиент не заполнил Status, она строится по коду.
+func statusLine(resp *http.Response) string {
+	if resp.Status != "" {
+		return resp.Status
+	}
+	return strings.TrimSpace(fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)))
+}
@@ -1,12 +1,18 @@
 package checker
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -47,7 +53,7 @@ func TestAssetChecker_WithContentLength(t *testing.T) {
 	htmlParser := parser.NewHTMLParser()
 	checker := NewAssetChecker(fetcher, htmlParser, 4)
 
-	result := checker.fetchAsset(context.Background(), "https://example.com/logo.png")
+	result := checker.fetchAsset(context.Background(), "https://example.com/logo.png", "image")
 
 	if result.Error != nil {
 		t.Errorf("Expected no error, got: %v", result.Error)
@@ -84,7 +90,7 @@ func TestAssetChecker_WithoutContentLength(t *testing.T) {
 	htmlParser := parser.NewHTMLParser()
 	checker := NewAssetChecker(fetcher, htmlParser, 4)
 
-	result := checker.fetchAsset(context.Background(), "https://example.com/script.js")
+	result := checker.fetchAsset(context.Background(), "https://example.com/script.js", "script")
 
 	if result.Error != nil {
 		t.Errorf("Expected no error, got: %v", result.Error)
@@ -115,7 +121,7 @@ func TestAssetChecker_NotFound(t *testing.T) {
 	htmlParser := parser.NewHTMLParser()
 	checker := NewAssetChecker(fetcher, htmlParser, 4)
 
-	result := checker.fetchAsset(context.Background(), "https://example.com/missing.png")
+	result := checker.fetchAsset(context.Background(), "https://example.com/missing.png", "image")
 
 	if result.StatusCode != 404 {
 		t.Errorf("Expected status 404, got: %d", result.StatusCode)
@@ -142,7 +148,7 @@ func TestAssetChecker_NetworkError(t *testing.T) {
 	htmlParser := parser.NewHTMLParser()
 	checker := NewAssetChecker(fetcher, htmlParser, 4)
 
-	result := checker.fetchAsset(context.Background(), "https://example.com/logo.png")
+	result := checker.fetchAsset(context.Background(), "https://example.com/logo.png", "image")
 
 	if result.Error == nil {
 		t.Error("Expected network error")
@@ -205,3 +211,414 @@ func TestHTMLParser_ExtractAssets(t *testing.T) {
 		t.Errorf("Expected 1 style, got: %d", styleCount)
 	}
 }
+
+// Тест 6: Кэш с TTL — после истечения TTL ассет проверяется заново
+func TestAssetChecker_CacheTTL(t *testing.T) {
+	callCount := 0
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			callCount++
+			return &http.Response{
+				StatusCode:    200,
+				ContentLength: 10,
+				Body:          io.NopCloser(strings.NewReader("")),
+				Header:        http.Header{},
+			}, nil
+		},
+	}
+
+	cfg := httputil.FetcherConfig{
+		Client:  mockClient,
+		Timeout: 5 * time.Second,
+	}
+
+	fetcher := httputil.NewFetcher(cfg, nil)
+	checker := NewAssetChecker(fetcher, parser.NewHTMLParser(), 1)
+	checker.SetCacheTTL(time.Minute)
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	checker.now = func() time.Time { return now }
+
+	assetURL := "https://example.com/logo.png"
+	checker.checkSingleAsset(context.Background(), assetURL, "image", nil)
+	checker.checkSingleAsset(context.Background(), assetURL, "image", nil)
+	if callCount != 1 {
+		t.Fatalf("Expected cached result within TTL, got %d requests", callCount)
+	}
+
+	now = now.Add(2 * time.Minute)
+	checker.checkSingleAsset(context.Background(), assetURL, "image", nil)
+	if callCount != 2 {
+		t.Errorf("Expected re-fetch after TTL, got %d requests", callCount)
+	}
+}
+
+// Тест 7: Строка статуса ответа сохраняется в status_text
+func TestAssetChecker_StatusText(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/offline.png") {
+				return nil, errors.New("connection refused")
+			}
+			return &http.Response{
+				StatusCode: 404,
+				Status:     "404 Not Found",
+				Body:       io.NopCloser(strings.NewReader("")),
+				Header:     http.Header{},
+			}, nil
+		},
+	}
+
+	cfg := httputil.FetcherConfig{
+		Client:  mockClient,
+		Timeout: 5 * time.Second,
+	}
+
+	fetcher := httputil.NewFetcher(cfg, nil)
+	checker := NewAssetChecker(fetcher, parser.NewHTMLParser(), 1)
+
+	asset, _ := checker.checkSingleAsset(context.Background(), "https://example.com/missing.png", "image", nil)
+	if asset.StatusText != "404 Not Found" {
+		t.Errorf("Expected status_text %q, got %q", "404 Not Found", asset.StatusText)
+	}
+
+	asset, _ = checker.checkSingleAsset(context.Background(), "https://example.com/offline.png", "image", nil)
+	if asset.StatusText != "" {
+		t.Errorf("Expected empty status_text for network error, got %q", asset.StatusText)
+	}
+}
+
+func TestAssetChecker_CompressedSize(t *testing.T) {
+	content := strings.Repeat("body { color: red; }\n", 100)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, _ = gz.Write([]byte(content))
+	_ = gz.Close()
+	compressed := buf.Len()
+
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    200,
+				ContentLength: int64(compressed),
+				Header:        http.Header{"Content-Encoding": []string{"gzip"}},
+				Body:          io.NopCloser(bytes.NewReader(buf.Bytes())),
+			}, nil
+		},
+	}
+
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second}, nil)
+	checker := NewAssetChecker(fetcher, parser.NewHTMLParser(), 4)
+
+	result := checker.fetchAsset(context.Background(), "https://example.com/style.css", "style")
+
+	if result.Error != nil {
+		t.Fatalf("Expected no error, got: %v", result.Error)
+	}
+	if result.SizeBytes != int64(len(content)) {
+		t.Errorf("Expected uncompressed size %d, got: %d", len(content), result.SizeBytes)
+	}
+	if result.CompressedSizeBytes != int64(compressed) {
+		t.Errorf("Expected compressed size %d, got: %d", compressed, result.CompressedSizeBytes)
+	}
+}
+
+func TestAssetChecker_MaxBodyBytes(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    200,
+				ContentLength: -1,
+				Body:          io.NopCloser(strings.NewReader(strings.Repeat("a", 1000))),
+				Header:        http.Header{},
+			}, nil
+		},
+	}
+
+	cfg := httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second, MaxBodyBytes: 100}
+	checker := NewAssetChecker(httputil.NewFetcher(cfg, nil), parser.NewHTMLParser(), 4)
+
+	result := checker.fetchAsset(context.Background(), "https://example.com/big.js", "script")
+
+	if result.Error != nil {
+		t.Fatalf("Expected no error, got: %v", result.Error)
+	}
+	if result.SizeBytes != 100 || !result.Truncated {
+		t.Errorf("Expected size capped at 100 and truncated, got %d (truncated=%v)", result.SizeBytes, result.Truncated)
+	}
+}
+
+func TestAssetChecker_MixedContent(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    200,
+				ContentLength: 10,
+				Body:          io.NopCloser(strings.NewReader("")),
+				Header:        http.Header{},
+			}, nil
+		},
+	}
+
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second}, nil)
+	checker := NewAssetChecker(fetcher, parser.NewHTMLParser(), 4)
+	htmlContent := `<html><body><img src="http://cdn.example.com/logo.png"><img src="/secure.png"></body></html>`
+
+	pageURL, _ := url.Parse("https://example.com/")
+	mixed := map[string]bool{}
+	assets, _ := checker.CheckAssets(context.Background(), htmlContent, pageURL)
+	for _, asset := range assets {
+		mixed[asset.URL] = asset.MixedContent
+	}
+	if !mixed["http://cdn.example.com/logo.png"] {
+		t.Errorf("Expected http image on https page to be mixed content, got %v", mixed)
+	}
+	if mixed["https://example.com/secure.png"] {
+		t.Errorf("Expected https image not to be mixed content")
+	}
+
+	// Тот же (закэшированный) ассет на http-странице не помечается
+	pageURL, _ = url.Parse("http://example.com/")
+	assets, _ = checker.CheckAssets(context.Background(), htmlContent, pageURL)
+	for _, asset := range assets {
+		if asset.MixedContent {
+			t.Errorf("Expected no mixed content on http page, got %s", asset.URL)
+		}
+	}
+}
+
+func TestAssetChecker_Order(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    200,
+				ContentLength: 1,
+				Body:          io.NopCloser(strings.NewReader("")),
+				Header:        http.Header{},
+			}, nil
+		},
+	}
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second}, nil)
+	htmlContent := `<html><head>
+		<script src="/b.js"></script>
+		<link rel="stylesheet" href="/z.css">
+	</head><body>
+		<img src="/c.png">
+		<script src="/a.js"></script>
+	</body></html>`
+	pageURL, _ := url.Parse("https://example.com/")
+
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{order: "", want: "/b.js,/z.css,/c.png,/a.js"},
+		{order: AssetOrderDocument, want: "/b.js,/z.css,/c.png,/a.js"},
+		{order: AssetOrderType, want: "/c.png,/b.js,/a.js,/z.css"},
+		{order: AssetOrderURL, want: "/a.js,/b.js,/c.png,/z.css"},
+	}
+
+	for _, tt := range tests {
+		checker := NewAssetChecker(fetcher, parser.NewHTMLParser(), 4)
+		checker.SetOrder(tt.order)
+
+		var paths []string
+		assets, _ := checker.CheckAssets(context.Background(), htmlContent, pageURL)
+		for _, asset := range assets {
+			paths = append(paths, strings.TrimPrefix(asset.URL, "https://example.com"))
+		}
+		if got := strings.Join(paths, ","); got != tt.want {
+			t.Errorf("order %q: got %s, want %s", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestAssetChecker_CacheKeepsPageContext(t *testing.T) {
+	calls := 0
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{
+				StatusCode:    200,
+				ContentLength: 42,
+				Body:          io.NopCloser(strings.NewReader("")),
+				Header:        http.Header{},
+			}, nil
+		},
+	}
+
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second}, nil)
+	checker := NewAssetChecker(fetcher, parser.NewHTMLParser(), 4)
+	// Ключ кэша без учёта регистра: оба написания — один ассет
+	checker.SetCacheKey(func(u *url.URL) string { return strings.ToLower(u.String()) })
+
+	securePage, _ := url.Parse("https://example.com/")
+	plainPage, _ := url.Parse("http://example.com/")
+
+	first, _ := checker.checkSingleAsset(context.Background(), "http://cdn.example.com/Logo.png", "image", securePage)
+	second, _ := checker.checkSingleAsset(context.Background(), "http://cdn.example.com/logo.png", "image", plainPage)
+
+	if calls != 1 {
+		t.Fatalf("expected one network request for a cached asset, got %d", calls)
+	}
+	if first.SizeBytes != 42 || second.SizeBytes != 42 {
+		t.Errorf("expected cached size for both pages, got %d and %d", first.SizeBytes, second.SizeBytes)
+	}
+	if !first.MixedContent || second.MixedContent {
+		t.Errorf("expected mixed content to follow the page scheme, got %v and %v", first.MixedContent, second.MixedContent)
+	}
+	if second.URL != "http://cdn.example.com/logo.png" {
+		t.Errorf("expected asset to keep the URL found on its page, got %s", second.URL)
+	}
+}
+
+func TestAssetChecker_SameDomainOnly(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requested = append(requested, req.URL.String())
+			mu.Unlock()
+			return &http.Response{
+				StatusCode:    200,
+				ContentLength: 1,
+				Body:          io.NopCloser(strings.NewReader("")),
+				Header:        http.Header{},
+			}, nil
+		},
+	}
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second}, nil)
+	htmlContent := `<html><head>
+		<script src="https://www.googletagmanager.com/gtag.js"></script>
+		<link rel="stylesheet" href="/style.css">
+	</head><body>
+		<img src="https://cdn.example.net/hero.png">
+		<img src="//example.com/logo.png">
+	</body></html>`
+	pageURL, _ := url.Parse("https://example.com/")
+
+	checker := NewAssetChecker(fetcher, parser.NewHTMLParser(), 4)
+	checker.SetSameDomainOnly(true)
+
+	var checked []string
+	assets, _ := checker.CheckAssets(context.Background(), htmlContent, pageURL)
+	for _, asset := range assets {
+		checked = append(checked, asset.URL)
+	}
+	want := []string{"https://example.com/style.css", "https://example.com/logo.png"}
+	if !slices.Equal(checked, want) {
+		t.Errorf("expected only first-party assets in the result, got %v", checked)
+	}
+	slices.Sort(requested)
+	if !slices.Equal(requested, []string{"https://example.com/logo.png", "https://example.com/style.css"}) {
+		t.Errorf("expected only first-party assets to be fetched, got %v", requested)
+	}
+}
+
+func TestAssetChecker_MaxAssets(t *testing.T) {
+	var requests atomic.Int64
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			requests.Add(1)
+			return &http.Response{
+				StatusCode:    200,
+				ContentLength: 1,
+				Body:          io.NopCloser(strings.NewReader("")),
+				Header:        http.Header{},
+			}, nil
+		},
+	}
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second}, nil)
+
+	var html strings.Builder
+	html.WriteString("<html><body>")
+	for i := range 500 {
+		fmt.Fprintf(&html, `<img src="/img/%d.png">`, i)
+	}
+	html.WriteString("</body></html>")
+	pageURL, _ := url.Parse("https://example.com/")
+
+	checker := NewAssetChecker(fetcher, parser.NewHTMLParser(), 8)
+	checker.SetMaxAssets(50)
+
+	assets, truncated := checker.CheckAssets(context.Background(), html.String(), pageURL)
+	if !truncated {
+		t.Error("expected truncated to be true for 500 assets with a cap of 50")
+	}
+	if len(assets) != 50 || requests.Load() != 50 {
+		t.Fatalf("expected 50 checked assets, got %d assets and %d requests", len(assets), requests.Load())
+	}
+	for i, asset := range assets {
+		if want := fmt.Sprintf("https://example.com/img/%d.png", i); asset.URL != want {
+			t.Fatalf("expected the first 50 assets in document order, got %s at %d", asset.URL, i)
+		}
+	}
+
+	if _, truncated := checker.CheckAssets(context.Background(), `<img src="/one.png">`, pageURL); truncated {
+		t.Error("expected no truncation below the cap")
+	}
+}
+
+func TestAssetChecker_ParseCSS(t *testing.T) {
+	stylesheets := map[string]string{
+		"/css/main.css":  `@import "theme.css"; body { background: url(../img/bg.png); } .i { background: url("data:image/png;base64,iVBORw0KGgo="); } .a { background: url('/a.png'); }`,
+		"/css/theme.css": `@font-face { src: url(fonts/f.woff2); } .m { background: url(missing.png); }`,
+	}
+	var mu sync.Mutex
+	requests := map[string]int{}
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requests[req.URL.Path]++
+			mu.Unlock()
+			status := http.StatusOK
+			body, ok := stylesheets[req.URL.Path]
+			if req.URL.Path == "/css/missing.png" {
+				status = http.StatusNotFound
+			} else if !ok {
+				body = "binary"
+			}
+			return &http.Response{
+				StatusCode:    status,
+				ContentLength: -1,
+				Body:          io.NopCloser(strings.NewReader(body)),
+				Header:        http.Header{},
+				Request:       req,
+			}, nil
+		},
+	}
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second}, nil)
+	htmlContent := `<html><head><link rel="stylesheet" href="/css/main.css"></head><body><img src="/a.png"></body></html>`
+	pageURL, _ := url.Parse("https://example.com/")
+
+	checker := NewAssetChecker(fetcher, parser.NewHTMLParser(), 4)
+	checker.SetParseCSS(true)
+
+	assets, _ := checker.CheckAssets(context.Background(), htmlContent, pageURL)
+	var got []string
+	for _, asset := range assets {
+		got = append(got, fmt.Sprintf("%s %s %s %d", strings.TrimPrefix(asset.URL, "https://example.com"), asset.Type, asset.Origin, asset.StatusCode))
+	}
+	want := []string{
+		"/css/main.css style  200",
+		"/a.png image  200",
+		"/css/theme.css style css-referenced 200",
+		"/img/bg.png image css-referenced 200",
+		"/css/fonts/f.woff2 font css-referenced 200",
+		"/css/missing.png image css-referenced 404",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got assets\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if requests["/a.png"] != 1 || requests["/css/main.css"] != 1 {
+		t.Errorf("expected each asset to be fetched once, got %v", requests)
+	}
+
+	// Без SetParseCSS таблицы стилей не разбираются
+	plain := NewAssetChecker(fetcher, parser.NewHTMLParser(), 4)
+	if assets, _ := plain.CheckAssets(context.Background(), htmlContent, pageURL); len(assets) != 2 {
+		t.Errorf("expected only page assets without ParseCSS, got %d", len(assets))
+	}
+}
@@ -2,6 +2,7 @@ package checker
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"sync"
 	"time"
@@ -14,12 +15,37 @@ type BrokenLink struct {
 	URL        string `json:"url"`
 	StatusCode int    `json:"status_code"`
 	Error      string `json:"error"`
+	// FoundOn — страница, на которой найдена ссылка
+	FoundOn string `json:"found_on,omitempty"`
+}
+
+// CheckedLink — результат проверки ссылки, рабочей или битой
+type CheckedLink struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error,omitempty"`
+	Broken     bool   `json:"broken"`
+	// FoundOn — страница, на которой ссылка найдена впервые
+	FoundOn string `json:"found_on,omitempty"`
 }
 
 // LinkChecker проверяет доступность ссылок
 type LinkChecker struct {
 	fetcher *httputil.Fetcher
 	workers int
+	// isBroken решает, битая ли ссылка с таким статусом (nil — DefaultIsBrokenStatus)
+	isBroken func(statusCode int) bool
+}
+
+// DefaultIsBrokenStatus считает битыми ссылки со статусом вне 200–399
+func DefaultIsBrokenStatus(statusCode int) bool {
+	return statusCode < 200 || statusCode >= 400
+}
+
+// SetBrokenStatus задаёт, какие HTTP-статусы считаются битыми ссылками.
+// Сетевые ошибки битые всегда. nil — DefaultIsBrokenStatus.
+func (lc *LinkChecker) SetBrokenStatus(isBroken func(statusCode int) bool) {
+	lc.isBroken = isBroken
 }
 
 func NewLinkChecker(fetcher *httputil.Fetcher, workers int) *LinkChecker {
@@ -30,7 +56,8 @@ func NewLinkChecker(fetcher *httputil.Fetcher, workers int) *LinkChecker {
 }
 
 // CheckLinks проверяет список ссылок параллельно.
-// Возвращает только битые ссылки (после всех retry).
+// Возвращает только битые ссылки (после всех retry). Ссылки, проверка
+// которых прервана остановкой обхода, не возвращаются.
 func (lc *LinkChecker) CheckLinks(ctx context.Context, links []string) ([]BrokenLink, string) {
 	if len(links) == 0 {
 		return nil, time.Now().UTC().Format(time.RFC3339)
@@ -67,40 +94,95 @@ func (lc *LinkChecker) CheckLinks(ctx context.Context, links []string) ([]Broken
 	return brokenLinks, time.Now().UTC().Format(time.RFC3339)
 }
 
-func (lc *LinkChecker) checkSingleLink(ctx context.Context, linkURL string) (BrokenLink, bool) {
-	result := lc.headRequest(ctx, linkURL)
+// CheckAll проверяет ссылки параллельно и возвращает результаты всех
+// проверок, рабочих и битых, в порядке links. Прерванные остановкой
+// обхода проверки пропускаются.
+func (lc *LinkChecker) CheckAll(ctx context.Context, links []string) []CheckedLink {
+	checked := make([]CheckedLink, len(links))
+	done := make([]bool, len(links))
+	semaphore := make(chan struct{}, lc.workers)
+	var wg sync.WaitGroup
 
-	if result.StatusCode >= 200 && result.StatusCode < 400 && result.Error == nil {
+	for i, link := range links {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			checked[i], done[i] = lc.check(ctx, link)
+		}()
+	}
+	wg.Wait()
+
+	results := make([]CheckedLink, 0, len(links))
+	for i := range checked {
+		if done[i] {
+			results = append(results, checked[i])
+		}
+	}
+	return results
+}
+
+func (lc *LinkChecker) checkSingleLink(ctx context.Context, linkURL string) (BrokenLink, bool) {
+	checked, done := lc.check(ctx, linkURL)
+	if !done || !checked.Broken {
 		return BrokenLink{}, false
 	}
+	return BrokenLink{URL: linkURL, StatusCode: checked.StatusCode, Error: checked.Error}, true
+}
 
-	brokenLink := BrokenLink{URL: linkURL}
+// check отправляет HEAD (с повторами) и решает, битая ли ссылка.
+// done = false — проверка прервана остановкой обхода и ничего не говорит о ссылке.
+func (lc *LinkChecker) check(ctx context.Context, linkURL string) (checked CheckedLink, done bool) {
+	result := lc.headRequest(ctx, linkURL)
+	if interrupted(ctx, result.Error) {
+		return CheckedLink{}, false
+	}
+	if logger := lc.fetcher.Logger(); logger != nil {
+		if result.Error != nil {
+			logger.Debug("link check failed", "url", linkURL, "error", result.Error)
+		} else {
+			logger.Debug("link checked", "url", linkURL, "status", result.StatusCode)
+		}
+	}
+
+	isBroken := lc.isBroken
+	if isBroken == nil {
+		isBroken = DefaultIsBrokenStatus
+	}
 	if result.Error != nil {
-		brokenLink.Error = result.Error.Error()
-	} else {
-		brokenLink.StatusCode = result.StatusCode
+		return CheckedLink{URL: linkURL, Error: result.Error.Error(), Broken: true}, true
 	}
+	return CheckedLink{URL: linkURL, StatusCode: result.StatusCode, Broken: isBroken(result.StatusCode)}, true
+}
 
-	return brokenLink, true
+// interrupted сообщает, что запрос не выполнен из-за остановки обхода:
+// отменён ctx или исчерпан лимит запросов. Такой результат не означает,
+// что ресурс недоступен.
+func interrupted(ctx context.Context, err error) bool {
+	return err != nil && (ctx.Err() != nil || errors.Is(err, httputil.ErrRequestLimit))
 }
 
 func (lc *LinkChecker) headRequest(ctx context.Context, urlStr string) httputil.FetchResult {
 	maxRetries := 2
 
+	var result httputil.FetchResult
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if ctx.Err() != nil {
 			return httputil.FetchResult{Error: ctx.Err()}
 		}
 
+		// Та же экспоненциальная пауза, что и у Fetcher
 		if attempt > 0 {
-			select {
-			case <-ctx.Done():
+			httputil.LogRetry(lc.fetcher.Logger(), urlStr, attempt, result)
+			if !lc.fetcher.WaitRetry(ctx, attempt, result) {
 				return httputil.FetchResult{Error: ctx.Err()}
-			case <-time.After(100 * time.Millisecond):
 			}
 		}
 
-		result := lc.performHeadRequest(ctx, urlStr)
+		result = lc.performHeadRequest(ctx, urlStr)
+		result.Attempts = attempt + 1
 
 		if result.Error == nil && result.StatusCode < 500 && result.StatusCode != 429 {
 			return result
@@ -116,7 +198,8 @@ func (lc *LinkChecker) headRequest(ctx context.Context, urlStr string) httputil.
 
 func (lc *LinkChecker) shouldRetry(result httputil.FetchResult) bool {
 	if result.Error != nil {
-		return true
+		// После исчерпания лимита повтор тоже не будет отправлен
+		return !errors.Is(result.Error, httputil.ErrRequestLimit)
 	}
 	if result.StatusCode == 429 {
 		return true
@@ -137,16 +220,12 @@ func (lc *LinkChecker) performHeadRequest(ctx context.Context, urlStr string) ht
 	timeoutCtx, cancel := context.WithTimeout(ctx, lc.fetcher.Timeout())
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodHead, urlStr, nil)
+	req, err := lc.fetcher.NewRequest(timeoutCtx, http.MethodHead, urlStr)
 	if err != nil {
 		return httputil.FetchResult{Error: err}
 	}
 
-	if lc.fetcher.UserAgent() != "" {
-		req.Header.Set("User-Agent", lc.fetcher.UserAgent())
-	}
-
-	resp, err := lc.fetcher.Client().Do(req)
+	resp, err := lc.fetcher.Do(req)
 	if err != nil {
 		return httputil.FetchResult{Error: err}
 	}
@@ -157,5 +236,6 @@ func (lc *LinkChecker) performHeadRequest(ctx context.Context, urlStr string) ht
 
 	return httputil.FetchResult{
 		StatusCode: resp.StatusCode,
+		RetryAfter: httputil.ParseRetryAfter(resp.Header.Get("Retry-After")),
 	}
 }
@@ -0,0 +1,111 @@
+package checker
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"code/internal/httputil"
+)
+
+func TestLinkChecker_BrokenStatus(t *testing.T) {
+	statuses := map[string]int{"/ok": 200, "/private": 403, "/login": 401, "/missing": 404}
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: statuses[req.URL.Path],
+				Body:       io.NopCloser(strings.NewReader("")),
+				Header:     http.Header{},
+			}, nil
+		},
+	}
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second}, nil)
+
+	links := []string{
+		"https://example.com/ok",
+		"https://example.com/private",
+		"https://example.com/login",
+		"https://example.com/missing",
+	}
+	brokenURLs := func(lc *LinkChecker) map[string]bool {
+		broken, _ := lc.CheckLinks(context.Background(), links)
+		result := map[string]bool{}
+		for _, link := range broken {
+			result[strings.TrimPrefix(link.URL, "https://example.com")] = true
+		}
+		return result
+	}
+
+	// По умолчанию битые все статусы вне 200–399
+	lc := NewLinkChecker(fetcher, 2)
+	if got := brokenURLs(lc); len(got) != 3 || got["/ok"] {
+		t.Errorf("expected 401, 403 and 404 to be broken by default, got %v", got)
+	}
+
+	// Закрытые разделы (401/403) не считаются битыми
+	lc.SetBrokenStatus(func(statusCode int) bool {
+		return DefaultIsBrokenStatus(statusCode) && statusCode != http.StatusUnauthorized && statusCode != http.StatusForbidden
+	})
+	if got := brokenURLs(lc); len(got) != 1 || !got["/missing"] {
+		t.Errorf("expected only 404 to be broken, got %v", got)
+	}
+}
+
+func TestLinkChecker_CheckAll(t *testing.T) {
+	statuses := map[string]int{"/ok": 200, "/missing": 404}
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: statuses[req.URL.Path],
+				Body:       io.NopCloser(strings.NewReader("")),
+				Header:     http.Header{},
+			}, nil
+		},
+	}
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second}, nil)
+
+	checked := NewLinkChecker(fetcher, 2).CheckAll(context.Background(), []string{
+		"https://example.com/ok",
+		"https://example.com/missing",
+	})
+	if len(checked) != 2 {
+		t.Fatalf("expected 2 results, got %v", checked)
+	}
+	if checked[0].URL != "https://example.com/ok" || checked[0].StatusCode != 200 || checked[0].Broken {
+		t.Errorf("expected working link first, got %+v", checked[0])
+	}
+	if checked[1].URL != "https://example.com/missing" || checked[1].StatusCode != 404 || !checked[1].Broken {
+		t.Errorf("expected broken 404 link second, got %+v", checked[1])
+	}
+}
+
+func TestLinkChecker_RequestLimit(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader("")),
+				Header:     http.Header{},
+			}, nil
+		},
+	}
+	stats := httputil.NewStats()
+	stats.SetLimit(1, nil)
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: mockClient, Timeout: 5 * time.Second, Stats: stats}, nil)
+
+	started := time.Now()
+	broken, _ := NewLinkChecker(fetcher, 1).CheckLinks(context.Background(), []string{
+		"https://example.com/a",
+		"https://example.com/b",
+		"https://example.com/c",
+	})
+	if len(broken) != 0 {
+		t.Errorf("expected links past the request limit not to be reported as broken, got %+v", broken)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("expected no retries after the request limit, took %v", elapsed)
+	}
+}
@@ -0,0 +1,40 @@
+package cssparse
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"code/internal/urlutil"
+)
+
+var (
+	commentRe = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// referenceRe находит url(...) с адресом в кавычках или без них
+	// и @import со строкой вместо url(...)
+	referenceRe = regexp.MustCompile(`(?i)\burl\(\s*(?:"([^"]*)"|'([^']*)'|([^)"'\s]*))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+// ExtractURLs возвращает адреса из url(...) и @import стилей css,
+// разрешённые относительно base, без повторов в порядке появления.
+// Комментарии, data:-URI и ссылки на фрагменты (url(#id)) пропускаются.
+func ExtractURLs(css string, base *url.URL) []string {
+	css = commentRe.ReplaceAllString(css, "")
+
+	seen := make(map[string]bool)
+	urls := []string{}
+	for _, groups := range referenceRe.FindAllStringSubmatch(css, -1) {
+		// Совпадает ровно одна группа: адрес в "", в '' или без кавычек
+		raw := strings.TrimSpace(strings.Join(groups[1:], ""))
+		if strings.HasPrefix(strings.ToLower(raw), "data:") {
+			continue
+		}
+		resolved := urlutil.ResolveURL(raw, base)
+		if resolved == "" || seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+		urls = append(urls, resolved)
+	}
+	return urls
+}
@@ -0,0 +1,65 @@
+package cssparse
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestExtractURLs(t *testing.T) {
+	base, _ := url.Parse("https://example.com/css/main.css")
+
+	tests := []struct {
+		name string
+		css  string
+		want []string
+	}{
+		{
+			name: "double quoted",
+			css:  `body { background: url("../img/bg.png"); }`,
+			want: []string{"https://example.com/img/bg.png"},
+		},
+		{
+			name: "single quoted",
+			css:  `@font-face { src: url('fonts/a.woff2') format("woff2"); }`,
+			want: []string{"https://example.com/css/fonts/a.woff2"},
+		},
+		{
+			name: "unquoted with spaces",
+			css:  `.logo { background-image: URL( /logo.svg ); }`,
+			want: []string{"https://example.com/logo.svg"},
+		},
+		{
+			name: "imports",
+			css:  `@import "reset.css"; @import url(theme.css) screen; @import 'print.css' print;`,
+			want: []string{
+				"https://example.com/css/reset.css",
+				"https://example.com/css/theme.css",
+				"https://example.com/css/print.css",
+			},
+		},
+		{
+			name: "data URIs are skipped",
+			css:  `a { background: url(data:image/png;base64,iVBORw0KGgo=); } b { background: url("DATA:image/svg+xml;utf8,<svg></svg>"); }`,
+			want: []string{},
+		},
+		{
+			name: "fragments, comments and duplicates",
+			css:  `/* url(old.png) */ .a { filter: url(#blur); background: url(x.png); } .b { background: url("x.png"); }`,
+			want: []string{"https://example.com/css/x.png"},
+		},
+		{
+			name: "absolute URL",
+			css:  `@font-face { src: url(https://fonts.example.net/f.ttf); }`,
+			want: []string{"https://fonts.example.net/f.ttf"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractURLs(tt.css, base); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package httputil
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRetryBackoff — базовая пауза перед первой повторной попыткой
+	DefaultRetryBackoff = 100 * time.Millisecond
+	// maxRetryBackoff ограничивает рост паузы при удвоении и Retry-After
+	maxRetryBackoff = 30 * time.Second
+	// retryJitter — разброс паузы: ±20%
+	retryJitter = 0.2
+)
+
+// Sleeper ждёт d или отмены контекста. Возвращает false, если контекст отменён.
+type Sleeper func(ctx context.Context, d time.Duration) bool
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// backoff вычисляет паузы между повторными попытками:
+// base, 2*base, 4*base... (не больше maxRetryBackoff) со случайным разбросом ±20%
+type backoff struct {
+	base  time.Duration
+	sleep Sleeper
+	// random возвращает число из [0, 1) для вычисления разброса
+	random func() float64
+}
+
+func newBackoff(base time.Duration, sleeper Sleeper) backoff {
+	if base <= 0 {
+		base = DefaultRetryBackoff
+	}
+	if sleeper == nil {
+		sleeper = sleep
+	}
+	return backoff{base: base, sleep: sleeper, random: rand.Float64}
+}
+
+// delay возвращает паузу перед попыткой attempt (1 — первый повтор).
+// Retry-After ответа 429/503 (не больше maxRetryBackoff) используется
+// вместо вычисленной паузы.
+func (b backoff) delay(attempt int, result FetchResult) time.Duration {
+	if result.RetryAfter > 0 && (result.StatusCode == http.StatusTooManyRequests || result.StatusCode == http.StatusServiceUnavailable) {
+		return min(result.RetryAfter, maxRetryBackoff)
+	}
+
+	d := b.base
+	for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxRetryBackoff)
+
+	factor := 1 + retryJitter*(2*b.random()-1)
+	return time.Duration(float64(d) * factor)
+}
+
+// wait выдерживает паузу перед попыткой attempt
+func (b backoff) wait(ctx context.Context, attempt int, result FetchResult) bool {
+	return b.sleep(ctx, b.delay(attempt, result))
+}
+
+// ParseRetryAfter разбирает Retry-After: число секунд или HTTP-дату
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
@@ -0,0 +1,126 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingSleeper запоминает запрошенные паузы и не ждёт
+func recordingSleeper(delays *[]time.Duration) Sleeper {
+	return func(ctx context.Context, d time.Duration) bool {
+		*delays = append(*delays, d)
+		return ctx.Err() == nil
+	}
+}
+
+func statusClient(statuses []int, header http.Header) *mockClient {
+	call := 0
+	return &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			status := statuses[min(call, len(statuses)-1)]
+			call++
+			return &http.Response{
+				StatusCode: status,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader("")),
+			}, nil
+		},
+	}
+}
+
+func TestRetryBackoffDoubles(t *testing.T) {
+	var delays []time.Duration
+	fetcher := NewFetcher(FetcherConfig{
+		Client:       statusClient([]int{500}, http.Header{}),
+		Timeout:      time.Second,
+		MaxRetries:   4,
+		RetryBackoff: 100 * time.Millisecond,
+		Sleep:        recordingSleeper(&delays),
+	}, nil)
+	// Без разброса: множитель 1 + 0.2*(2*0.5-1) = 1
+	fetcher.backoff.random = func() float64 { return 0.5 }
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/")
+	if result.StatusCode != 500 {
+		t.Fatalf("expected final status 500, got %d", result.StatusCode)
+	}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+	}
+	if len(delays) != len(expected) {
+		t.Fatalf("expected delays %v, got %v", expected, delays)
+	}
+	for i := range expected {
+		if delays[i] != expected[i] {
+			t.Errorf("retry %d: expected %v, got %v", i+1, expected[i], delays[i])
+		}
+	}
+}
+
+func TestRetryBackoffJitterAndCap(t *testing.T) {
+	b := newBackoff(time.Second, nil)
+
+	b.random = func() float64 { return 0 }
+	if d := b.delay(1, FetchResult{}); d != 800*time.Millisecond {
+		t.Errorf("expected -20%% jitter to give 800ms, got %v", d)
+	}
+
+	b.random = func() float64 { return 1 }
+	if d := b.delay(2, FetchResult{}); d != 2400*time.Millisecond {
+		t.Errorf("expected +20%% jitter to give 2.4s, got %v", d)
+	}
+
+	b.random = func() float64 { return 0.5 }
+	if d := b.delay(30, FetchResult{}); d != maxRetryBackoff {
+		t.Errorf("expected delay capped at %v, got %v", maxRetryBackoff, d)
+	}
+}
+
+func TestRetryAfterOverridesBackoff(t *testing.T) {
+	var delays []time.Duration
+	fetcher := NewFetcher(FetcherConfig{
+		Client:       statusClient([]int{503, 200}, http.Header{"Retry-After": []string{"3"}}),
+		Timeout:      time.Second,
+		MaxRetries:   2,
+		RetryBackoff: 100 * time.Millisecond,
+		Sleep:        recordingSleeper(&delays),
+	}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/")
+	if result.StatusCode != 200 {
+		t.Fatalf("expected status 200 after retry, got %d", result.StatusCode)
+	}
+	if len(delays) != 1 || delays[0] != 3*time.Second {
+		t.Errorf("expected a single 3s Retry-After delay, got %v", delays)
+	}
+}
+
+func TestRetryAfterCapped(t *testing.T) {
+	b := newBackoff(time.Second, nil)
+	result := FetchResult{StatusCode: http.StatusTooManyRequests, RetryAfter: ParseRetryAfter("86400")}
+	if d := b.delay(1, result); d != maxRetryBackoff {
+		t.Errorf("expected Retry-After capped at %v, got %v", maxRetryBackoff, d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := ParseRetryAfter("120"); d != 2*time.Minute {
+		t.Errorf("expected 2m, got %v", d)
+	}
+	if d := ParseRetryAfter("soon"); d != 0 {
+		t.Errorf("expected 0 for invalid value, got %v", d)
+	}
+
+	at := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d := ParseRetryAfter(at); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expected about 1h for HTTP date, got %v", d)
+	}
+}
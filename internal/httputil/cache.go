@@ -0,0 +1,143 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ResponseCache хранит ответы GET и HEAD (статус, заголовки, тело) по методу,
+// URL и режиму редиректов в течение ttl, чтобы повторные запросы не шли в сеть. Кэш может
+// пережить Fetcher: один кэш передаётся в FetcherConfig.Cache при каждом
+// запуске обхода. Безопасен для одновременного использования.
+// nil-кэш ничего не хранит.
+type ResponseCache struct {
+	ttl time.Duration
+	// now возвращает текущее время; подменяется в тестах
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	storedAt   time.Time
+}
+
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]cachedResponse),
+	}
+}
+
+type noCacheKey struct{}
+
+// WithoutCache помечает запросы ctx как не использующие кэш: они всегда
+// уходят в сеть, а их ответы не сохраняются (например, повторная загрузка
+// страницы для проверки стабильности)
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// cacheable: кэшируются только безусловные GET и HEAD без WithoutCache
+func (c *ResponseCache) cacheable(req *http.Request) bool {
+	if c == nil || c.ttl <= 0 {
+		return false
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return false
+	}
+	skip, _ := req.Context().Value(noCacheKey{}).(bool)
+	return !skip
+}
+
+// cacheKey различает режимы редиректов: страницам Fetcher нужен сам ответ 3xx,
+// а проверке ссылок — ответ после редиректов
+func cacheKey(req *http.Request) string {
+	mode := "follow"
+	if manual, _ := req.Context().Value(manualRedirectsKey{}).(bool); manual {
+		mode = "manual"
+	}
+	return mode + " " + req.Method + " " + req.URL.String()
+}
+
+// get возвращает сохранённый ответ на req как новый *http.Response.
+// Устаревшая запись удаляется.
+func (c *ResponseCache) get(req *http.Request) (*http.Response, bool) {
+	key := cacheKey(req)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && c.now().Sub(entry.storedAt) >= c.ttl {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.statusCode, http.StatusText(entry.statusCode)),
+		StatusCode:    entry.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(entry.body)),
+		ContentLength: int64(len(entry.body)),
+		Request:       req,
+	}, true
+}
+
+// store сохраняет ответ и возвращает его с телом, которое можно прочитать
+// заново. Ошибки, 429, 5xx и 304 не сохраняются: их повтор должен уйти
+// в сеть. Тело длиннее limit байт тоже не сохраняется, как и ответ,
+// полученный после редиректов: из кэша он вернулся бы с Request исходного
+// URL, и относительные ссылки разрешались бы не от той базы.
+func (c *ResponseCache) store(req *http.Request, resp *http.Response, limit int64) *http.Response {
+	if resp.StatusCode == http.StatusNotModified || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return resp
+	}
+	if resp.Request != nil && resp.Request.URL.String() != req.URL.String() {
+		return resp
+	}
+
+	// Лишний байт показывает, что тело не уместилось в лимит
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil || int64(len(body)) > limit {
+		// Прочитанное начало возвращается вместе с остатком тела
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	c.mu.Lock()
+	c.entries[cacheKey(req)] = cachedResponse{
+		statusCode: resp.StatusCode,
+		header:     resp.Header.Clone(),
+		body:       body,
+		storedAt:   c.now(),
+	}
+	c.mu.Unlock()
+
+	return resp
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,185 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingPageClient отвечает HTML-страницей со статусом status и считает запросы
+func countingPageClient(calls *int, status int) *mockClient {
+	return &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			*calls++
+			return &http.Response{
+				StatusCode: status,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader("<html>page</html>")),
+				Request:    req,
+			}, nil
+		},
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	calls := 0
+	now := time.Now()
+	cache := NewResponseCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	stats := NewStats()
+	fetcher := NewFetcher(FetcherConfig{
+		Client:  countingPageClient(&calls, http.StatusOK),
+		Timeout: time.Second,
+		Stats:   stats,
+		Cache:   cache,
+	}, nil)
+	ctx := context.Background()
+
+	first := fetcher.Fetch(ctx, "https://example.com/")
+	second := fetcher.Fetch(ctx, "https://example.com/")
+	if calls != 1 {
+		t.Fatalf("expected second fetch within TTL to hit the cache, got %d requests", calls)
+	}
+	if second.StatusCode != http.StatusOK || second.HTMLContent != first.HTMLContent || second.ContentType != "text/html" {
+		t.Errorf("expected cached response to match the first, got %+v", second)
+	}
+	if stats.Requests() != 1 {
+		t.Errorf("expected cache hits not to be counted as requests, got %d", stats.Requests())
+	}
+
+	// HEAD кэшируется отдельно от GET
+	fetcher.Head(ctx, "https://example.com/")
+	fetcher.Head(ctx, "https://example.com/")
+	if calls != 2 {
+		t.Errorf("expected one HEAD request, got %d requests in total", calls)
+	}
+
+	// Повторная загрузка мимо кэша
+	fetcher.Fetch(WithoutCache(ctx), "https://example.com/")
+	if calls != 3 {
+		t.Errorf("expected WithoutCache to bypass the cache, got %d requests", calls)
+	}
+
+	// Условный запрос всегда уходит в сеть
+	fetcher.Fetch(WithValidators(ctx, "https://example.com/", Validators{ETag: `"v1"`}), "https://example.com/")
+	if calls != 4 {
+		t.Errorf("expected conditional request to bypass the cache, got %d requests", calls)
+	}
+
+	now = now.Add(time.Minute)
+	fetcher.Fetch(ctx, "https://example.com/")
+	if calls != 5 {
+		t.Errorf("expected expired entry to be fetched again, got %d requests", calls)
+	}
+}
+
+func TestResponseCacheSkipsServerErrors(t *testing.T) {
+	calls := 0
+	fetcher := NewFetcher(FetcherConfig{
+		Client:  countingPageClient(&calls, http.StatusServiceUnavailable),
+		Timeout: time.Second,
+		Cache:   NewResponseCache(time.Minute),
+	}, nil)
+
+	fetcher.Fetch(context.Background(), "https://example.com/")
+	fetcher.Fetch(context.Background(), "https://example.com/")
+	if calls != 2 {
+		t.Errorf("expected 5xx responses not to be cached, got %d requests", calls)
+	}
+}
+
+func TestResponseCacheSharedAcrossFetchers(t *testing.T) {
+	calls := 0
+	cache := NewResponseCache(time.Minute)
+	for range 2 {
+		fetcher := NewFetcher(FetcherConfig{
+			Client:  countingPageClient(&calls, http.StatusOK),
+			Timeout: time.Second,
+			Cache:   cache,
+		}, nil)
+		if result := fetcher.Fetch(context.Background(), "https://example.com/"); result.HTMLContent != "<html>page</html>" {
+			t.Errorf("unexpected body %q", result.HTMLContent)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the cache to outlive the fetcher, got %d requests", calls)
+	}
+}
+
+func TestResponseCacheSeparatesRedirectModes(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = io.WriteString(w, "<html>new</html>")
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherConfig{
+		Client:       NewClient(),
+		Timeout:      time.Second,
+		MaxRedirects: 5,
+		Cache:        NewResponseCache(time.Minute),
+	}, nil)
+	ctx := context.Background()
+	oldURL := server.URL + "/old"
+
+	// Проверка ссылки следует редиректу и получает 200 с /new
+	for range 2 {
+		req, err := fetcher.NewRequest(ctx, http.MethodHead, oldURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := fetcher.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/new" {
+			t.Fatalf("expected the link check to follow the redirect, got %d from %s", resp.StatusCode, resp.Request.URL)
+		}
+	}
+	if hits["HEAD /old"] != 2 {
+		t.Errorf("expected a response obtained through a redirect not to be cached, got %d requests to /old", hits["HEAD /old"])
+	}
+
+	// Head видит сам редирект, а не закэшированный итог проверки ссылки
+	for range 2 {
+		result := fetcher.Head(ctx, oldURL)
+		if result.Error != nil {
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+		if result.StatusCode != http.StatusMovedPermanently {
+			t.Errorf("expected HEAD to report the redirect, got %d", result.StatusCode)
+		}
+	}
+	if hits["HEAD /old"] != 3 {
+		t.Errorf("expected the manual 301 to be cached separately, got %d requests to /old", hits["HEAD /old"])
+	}
+
+	// Закэшированный 301 не подменяет ответ проверке ссылки
+	req, err := fetcher.NewRequest(ctx, http.MethodHead, oldURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := fetcher.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the link check not to get the cached 301, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,95 @@
+package httputil
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// metaPrescanBytes — сколько байт начала документа просматривается в поисках
+// <meta charset> (как в алгоритме prescan спецификации HTML)
+const metaPrescanBytes = 1024
+
+// DetectCharset возвращает объявленную кодировку документа: параметр charset
+// заголовка Content-Type, иначе <meta charset> или <meta http-equiv>.
+// Имя приводится к каноническому ("windows-1251"); пустая строка — кодировка
+// не объявлена или неизвестна.
+func DetectCharset(contentType string, body []byte) string {
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if name := canonicalCharset(params["charset"]); name != "" {
+			return name
+		}
+	}
+	return canonicalCharset(metaCharset(body))
+}
+
+// ToUTF8 перекодирует тело из объявленной кодировки в UTF-8.
+// Тело без кодировки или уже в UTF-8 возвращается как есть.
+func ToUTF8(body []byte, charsetName string) []byte {
+	if charsetName == "" || charsetName == "utf-8" {
+		return body
+	}
+
+	enc, _ := charset.Lookup(charsetName)
+	if enc == nil {
+		return body
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body
+	}
+	return decoded
+}
+
+func canonicalCharset(label string) string {
+	if label == "" {
+		return ""
+	}
+	_, name := charset.Lookup(label)
+	return name
+}
+
+// metaCharset ищет кодировку в <meta charset> и
+// <meta http-equiv="Content-Type" content="...; charset=...">
+func metaCharset(body []byte) string {
+	if len(body) > metaPrescanBytes {
+		body = body[:metaPrescanBytes]
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "meta" || !hasAttr {
+				continue
+			}
+
+			var httpEquiv, content string
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				switch string(key) {
+				case "charset":
+					return strings.TrimSpace(string(val))
+				case "http-equiv":
+					httpEquiv = strings.ToLower(string(val))
+				case "content":
+					content = string(val)
+				}
+			}
+
+			if httpEquiv == "content-type" {
+				if _, params, err := mime.ParseMediaType(content); err == nil && params["charset"] != "" {
+					return params["charset"]
+				}
+			}
+		}
+	}
+}
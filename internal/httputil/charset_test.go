@@ -0,0 +1,74 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// "Привет" в windows-1251
+var cp1251Greeting = []byte{0xcf, 0xf0, 0xe8, 0xe2, 0xe5, 0xf2}
+
+func TestDetectCharset(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{name: "header", contentType: "text/html; charset=windows-1251", body: "<html></html>", want: "windows-1251"},
+		{name: "header alias", contentType: "text/html; charset=cp1251", body: "<html></html>", want: "windows-1251"},
+		{name: "meta charset", contentType: "text/html", body: `<html><head><meta charset="koi8-r"></head></html>`, want: "koi8-r"},
+		{name: "meta http-equiv", contentType: "text/html", body: `<meta http-equiv="Content-Type" content="text/html; charset=ISO-8859-1">`, want: "windows-1252"},
+		{name: "header wins over meta", contentType: "text/html; charset=utf-8", body: `<meta charset="koi8-r">`, want: "utf-8"},
+		{name: "not declared", contentType: "text/html", body: "<html><title>t</title></html>", want: ""},
+		{name: "unknown", contentType: "text/html; charset=x-unknown", body: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectCharset(tt.contentType, []byte(tt.body)); got != tt.want {
+				t.Fatalf("DetectCharset() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	if got := string(ToUTF8(cp1251Greeting, "windows-1251")); got != "Привет" {
+		t.Fatalf("expected transcoded text, got %q", got)
+	}
+	if got := string(ToUTF8([]byte("Привет"), "utf-8")); got != "Привет" {
+		t.Fatalf("expected UTF-8 body unchanged, got %q", got)
+	}
+	if got := ToUTF8(cp1251Greeting, ""); !bytes.Equal(got, cp1251Greeting) {
+		t.Fatalf("expected body without charset unchanged, got %q", got)
+	}
+}
+
+func TestFetchTranscodesBody(t *testing.T) {
+	body := append([]byte(`<html><head><meta charset="windows-1251"><title>`), cp1251Greeting...)
+	body = append(body, []byte(`</title></head></html>`)...)
+
+	client := &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(bytes.NewReader(body)),
+			}, nil
+		},
+	}
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/")
+	if result.Charset != "windows-1251" {
+		t.Errorf("expected charset windows-1251, got %q", result.Charset)
+	}
+	if !bytes.Contains([]byte(result.HTMLContent), []byte("<title>Привет</title>")) {
+		t.Fatalf("expected transcoded title, got %q", result.HTMLContent)
+	}
+}
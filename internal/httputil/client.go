@@ -0,0 +1,169 @@
+package httputil
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxIdleConnsPerHost — сколько простаивающих соединений с одним
+	// хостом сохраняется для повторного использования (в net/http — всего 2,
+	// из-за чего параллельные воркеры постоянно открывают новые соединения)
+	DefaultMaxIdleConnsPerHost = 16
+	// maxIdleConns ограничивает общее число простаивающих соединений,
+	// чтобы при обходе многих хостов они не накапливались
+	maxIdleConns = 100
+	// idleConnTimeout — через сколько закрывается простаивающее соединение
+	idleConnTimeout = 90 * time.Second
+	// dialTimeout и dialKeepAlive — как у http.DefaultTransport
+	dialTimeout   = 30 * time.Second
+	dialKeepAlive = 30 * time.Second
+)
+
+// ClientConfig — настройки HTTP-клиента по умолчанию
+type ClientConfig struct {
+	// MaxIdleConnsPerHost — простаивающих соединений на хост
+	// (0 — DefaultMaxIdleConnsPerHost)
+	MaxIdleConnsPerHost int
+	// HostOverrides — IP-адреса для имён хостов (hostname → IP) вместо DNS.
+	// Меняется только адрес подключения: Host и SNI остаются прежними.
+	HostOverrides map[string]string
+	// InsecureSkipVerify отключает проверку сертификатов сервера
+	InsecureSkipVerify bool
+	// Certificates — клиентские сертификаты для mTLS
+	Certificates []tls.Certificate
+	// Proxy — прокси для всех запросов (http, https или socks5);
+	// nil — прокси из переменных окружения, как у http.DefaultTransport
+	Proxy *url.URL
+	// Jar — хранилище cookie клиента: cookie из ответов отправляются
+	// в следующих запросах (nil — без хранилища)
+	Jar http.CookieJar
+	// ConnectTimeout — предел на установку TCP-соединения и, отдельно,
+	// на TLS-рукопожатие (0 — как у http.DefaultTransport)
+	ConnectTimeout time.Duration
+	// ResponseHeaderTimeout — сколько ждать заголовков ответа после отправки
+	// запроса; чтение тела им не ограничено (0 — без предела)
+	ResponseHeaderTimeout time.Duration
+	// Transport заменяет транспорт из NewTransport; настройки соединений
+	// выше (прокси, TLS, таймауты, HostOverrides) тогда не применяются
+	Transport http.RoundTripper
+}
+
+type manualRedirectsKey struct{}
+
+// withManualRedirects помечает запрос: редиректы для него обрабатывает Fetcher,
+// а клиент из NewClient должен вернуть ответ 3xx как есть
+func withManualRedirects(ctx context.Context) context.Context {
+	return context.WithValue(ctx, manualRedirectsKey{}, true)
+}
+
+// NewClient создаёт HTTP-клиент по умолчанию.
+// Для запросов страниц редиректы не выполняются автоматически — ими управляет
+// Fetcher (MaxRedirects). Остальные запросы (ассеты, HEAD) следуют редиректам как обычно.
+func NewClient() *http.Client {
+	return NewClientWithConfig(ClientConfig{})
+}
+
+// NewClientWithConfig создаёт клиент как NewClient, с транспортом из
+// NewTransport или ClientConfig.Transport
+func NewClientWithConfig(cfg ClientConfig) *http.Client {
+	var transport http.RoundTripper = cfg.Transport
+	if transport == nil {
+		transport = NewTransport(cfg)
+	}
+	return &http.Client{
+		Transport: transport,
+		Jar:       cfg.Jar,
+		// Только так Fetcher получает ответы 3xx страниц: сторонний клиент,
+		// следующий редиректам сам, отдаёт лишь итоговый ответ
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if manual, _ := req.Context().Value(manualRedirectsKey{}).(bool); manual {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+}
+
+// NewTransport создаёт транспорт, настроенный для обхода: соединения с хостом
+// переиспользуются воркерами, простаивающие закрываются по таймауту, HTTP/2
+// включается и для транспорта с изменёнными настройками.
+// Прокси (если не задан ClientConfig.Proxy) и таймауты подключения (если
+// не задан ClientConfig.ConnectTimeout) берутся из http.DefaultTransport.
+func NewTransport(cfg ClientConfig) *http.Transport {
+	perHost := cfg.MaxIdleConnsPerHost
+	if perHost <= 0 {
+		perHost = DefaultMaxIdleConnsPerHost
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = max(maxIdleConns, perHost)
+	transport.MaxIdleConnsPerHost = perHost
+	transport.IdleConnTimeout = idleConnTimeout
+	transport.ForceAttemptHTTP2 = true
+	if cfg.Proxy != nil {
+		transport.Proxy = http.ProxyURL(cfg.Proxy)
+	}
+	if len(cfg.HostOverrides) > 0 || cfg.ConnectTimeout > 0 {
+		connectTimeout := dialTimeout
+		if cfg.ConnectTimeout > 0 {
+			connectTimeout = cfg.ConnectTimeout
+			transport.TLSHandshakeTimeout = cfg.ConnectTimeout
+		}
+		transport.DialContext = overrideDialer(cfg.HostOverrides, connectTimeout)
+	}
+	transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	if cfg.InsecureSkipVerify || len(cfg.Certificates) > 0 {
+		// Clone копирует tls.Config, поэтому настройки HTTP/2 (NextProtos) сохраняются
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = cfg.InsecureSkipVerify
+		transport.TLSClientConfig.Certificates = cfg.Certificates
+	}
+	return transport
+}
+
+// ParseProxyURL разбирает адрес прокси и проверяет, что его схема
+// поддерживается транспортом: http, https, socks5 или socks5h
+func ParseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+	return u, nil
+}
+
+// overrideDialer подключается к IP из overrides вместо разрешения имени
+// хоста; порт адреса сохраняется. Остальные хосты разрешаются как обычно.
+// Подключение ограничено timeout.
+func overrideDialer(overrides map[string]string, timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	hosts := make(map[string]string, len(overrides))
+	for host, ip := range overrides {
+		hosts[strings.TrimSuffix(strings.ToLower(host), ".")] = ip
+	}
+
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: dialKeepAlive}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if ip, ok := hosts[strings.TrimSuffix(strings.ToLower(host), ".")]; ok {
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
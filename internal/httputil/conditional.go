@@ -0,0 +1,44 @@
+package httputil
+
+import (
+	"context"
+	"net/http"
+)
+
+// Validators — валидаторы ранее загруженной версии страницы (ETag и
+// Last-Modified), по которым сервер может ответить 304 Not Modified
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+type conditionalKey struct{}
+
+type conditional struct {
+	url        string
+	validators Validators
+}
+
+// WithValidators помечает загрузку urlStr как условную: GET-запрос страницы
+// отправляется с If-None-Match / If-Modified-Since. Запросы к другим URL
+// (например, после редиректа) остаются обычными.
+func WithValidators(ctx context.Context, urlStr string, v Validators) context.Context {
+	if v.ETag == "" && v.LastModified == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, conditionalKey{}, conditional{url: urlStr, validators: v})
+}
+
+// applyValidators добавляет условные заголовки, если загрузка urlStr помечена WithValidators
+func applyValidators(ctx context.Context, req *http.Request, urlStr string) {
+	cond, ok := ctx.Value(conditionalKey{}).(conditional)
+	if !ok || cond.url != urlStr {
+		return
+	}
+	if cond.validators.ETag != "" {
+		req.Header.Set("If-None-Match", cond.validators.ETag)
+	}
+	if cond.validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cond.validators.LastModified)
+	}
+}
@@ -0,0 +1,50 @@
+package httputil
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// AcceptEncoding — сжатия, которые краулер умеет распаковывать
+const AcceptEncoding = "gzip, deflate, br"
+
+// Decompress возвращает распакованное содержимое r по значению заголовка
+// Content-Encoding. Без сжатия (пустой заголовок или identity) r возвращается
+// как есть.
+func Decompress(contentEncoding string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return r, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		return newDeflateReader(r)
+	case "br":
+		return brotli.NewReader(r), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", contentEncoding)
+	}
+}
+
+// newDeflateReader читает deflate в обёртке zlib (RFC 9110) и, для
+// серверов, которые отправляют его без обёртки, «сырой» deflate
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	// Заголовок zlib: метод сжатия 8 и контрольная сумма кратна 31
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
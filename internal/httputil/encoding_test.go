@@ -0,0 +1,118 @@
+package httputil
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const samplePage = "<html><head><title>Compressed</title></head><body>hello</body></html>"
+
+func compress(t *testing.T, encoding, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown encoding %q", encoding)
+	}
+
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		encoding string
+	}{
+		{name: "gzip", header: "gzip", encoding: "gzip"},
+		{name: "deflate zlib", header: "deflate", encoding: "deflate"},
+		{name: "deflate raw", header: "deflate", encoding: "raw-deflate"},
+		{name: "brotli", header: "br", encoding: "br"},
+		{name: "header case", header: "GZIP", encoding: "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Decompress(tt.header, bytes.NewReader(compress(t, tt.encoding, samplePage)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected read error: %v", err)
+			}
+			if string(got) != samplePage {
+				t.Fatalf("expected %q, got %q", samplePage, got)
+			}
+		})
+	}
+}
+
+func TestDecompressIdentityAndUnsupported(t *testing.T) {
+	r, err := Decompress("", strings.NewReader(samplePage))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != samplePage {
+		t.Fatalf("expected body unchanged, got %q", got)
+	}
+
+	if _, err := Decompress("zstd", strings.NewReader(samplePage)); err == nil {
+		t.Fatal("expected error for unsupported encoding")
+	}
+}
+
+func TestFetchDecompressesBody(t *testing.T) {
+	var acceptEncoding string
+	client := &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			acceptEncoding = req.Header.Get("Accept-Encoding")
+			return &http.Response{
+				StatusCode: 200,
+				Header: http.Header{
+					"Content-Type":     []string{"text/html"},
+					"Content-Encoding": []string{"gzip"},
+				},
+				Body: io.NopCloser(bytes.NewReader(compress(t, "gzip", samplePage))),
+			}, nil
+		},
+	}
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/")
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if acceptEncoding != AcceptEncoding {
+		t.Errorf("expected Accept-Encoding %q, got %q", AcceptEncoding, acceptEncoding)
+	}
+	if result.HTMLContent != samplePage {
+		t.Fatalf("expected decompressed HTML, got %q", result.HTMLContent)
+	}
+}
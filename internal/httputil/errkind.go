@@ -0,0 +1,66 @@
+package httputil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+)
+
+// Категории сетевых ошибок в FetchResult.ErrorKind
+const (
+	ErrorKindDNS        = "dns"
+	ErrorKindConnection = "connection"
+	ErrorKindTLS        = "tls"
+	ErrorKindTimeout    = "timeout"
+	ErrorKindOther      = "other"
+)
+
+// ClassifyError относит ошибку запроса к одной из категорий ErrorKind*.
+// Таймаут проверяется первым: истёкший DNS-запрос или TLS-рукопожатие —
+// это timeout. Для nil возвращается пустая строка.
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorKindTimeout
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorKindDNS
+	}
+
+	if isTLSError(err) {
+		return ErrorKindTLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ErrorKindConnection
+	}
+
+	return ErrorKindOther
+}
+
+// isTLSError: ошибка проверки сертификата или TLS-рукопожатия
+func isTLSError(err error) bool {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		verification     *tls.CertificateVerificationError
+		recordHeader     tls.RecordHeaderError
+		alert            tls.AlertError
+	)
+	return errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostname) ||
+		errors.As(err, &invalid) ||
+		errors.As(err, &verification) ||
+		errors.As(err, &recordHeader) ||
+		errors.As(err, &alert)
+}
@@ -0,0 +1,46 @@
+package httputil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	// Ошибки приходят обёрнутыми, как их возвращает http.Client
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com/", Err: err}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"dns", wrap(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}}), ErrorKindDNS},
+		{"connection refused", wrap(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), ErrorKindConnection},
+		{"connection reset", wrap(&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}), ErrorKindConnection},
+		{"unknown authority", wrap(fmt.Errorf("tls: failed to verify certificate: %w", x509.UnknownAuthorityError{})), ErrorKindTLS},
+		{"hostname mismatch", wrap(x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}), ErrorKindTLS},
+		{"expired certificate", wrap(&tls.CertificateVerificationError{Err: x509.CertificateInvalidError{Reason: x509.Expired}}), ErrorKindTLS},
+		{"deadline exceeded", wrap(context.DeadlineExceeded), ErrorKindTimeout},
+		{"dns timeout", wrap(&net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}), ErrorKindTimeout},
+		{"canceled", wrap(context.Canceled), ErrorKindOther},
+		{"other", errors.New("something went wrong"), ErrorKindOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,18 @@ package httputil
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
+
+	"code/internal/urlutil"
 )
 
 // HTTPClient — интерфейс для выполнения HTTP запросов
@@ -13,11 +21,53 @@ type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// DefaultMaxBodyBytes — сколько байт тела ответа читается по умолчанию
+const DefaultMaxBodyBytes int64 = 10 << 20
+
 // FetchResult содержит результат HTTP-запроса
 type FetchResult struct {
 	StatusCode  int
+	ContentType string
+	// ContentLength — заголовок Content-Length ответа (-1, если неизвестен)
+	ContentLength int64
+	// Charset — объявленная кодировка страницы (заголовок или <meta charset>);
+	// HTMLContent всегда в UTF-8
+	Charset     string
 	HTMLContent string
-	Error       error
+	// Body — тело ответа после распаковки в исходной кодировке, из которого
+	// получен HTMLContent (nil, если тело не читалось)
+	Body []byte
+	// Truncated — тело длиннее MaxBodyBytes, Body и HTMLContent содержат
+	// только начало
+	Truncated bool
+	// Location — заголовок Location ответа 3xx
+	Location string
+	// RedirectTarget — Location последнего ответа 3xx, разрешённый в абсолютный
+	// URL (заполняется, когда Fetch не прошёл редирект)
+	RedirectTarget string
+	// FinalURL — адрес, с которого получен ответ (после редиректов)
+	FinalURL string
+	// RedirectChain — запрошенные по порядку URL, если были редиректы
+	RedirectChain []string
+	// Server и PoweredBy — заголовки Server и X-Powered-By ответа
+	Server    string
+	PoweredBy string
+	// RetryAfter — пауза из заголовка Retry-After (0, если заголовка нет)
+	RetryAfter time.Duration
+	// Validators — заголовки ETag и Last-Modified ответа
+	Validators Validators
+	// Headers — заголовки из FetcherConfig.CaptureHeaders, присутствующие
+	// в ответе (nil, если таких нет). У Fetch — заголовки последнего ответа.
+	Headers map[string]string
+	Error   error
+	// ErrorKind — категория сетевой ошибки (ErrorKind*), пусто без ошибки
+	// и для ошибок, не связанных с сетью (например, цикла редиректов)
+	ErrorKind string
+	// Attempts — число выполненных попыток запроса (1 — успех с первой).
+	// У Fetch — попытки последнего запроса цепочки редиректов.
+	Attempts int
+	// TLS — состояние TLS-соединения ответа (nil для http:// и ответов из кэша)
+	TLS *tls.ConnectionState
 }
 
 type FetcherConfig struct {
@@ -25,25 +75,90 @@ type FetcherConfig struct {
 	UserAgent  string
 	Timeout    time.Duration
 	MaxRetries int
+	Headers    map[string]string
+	Cookies    []*http.Cookie
+	// BasicAuthUser/BasicAuthPass и BearerToken задают заголовок Authorization
+	BasicAuthUser string
+	BasicAuthPass string
+	BearerToken   string
+	// Stats — общие счётчики запросов (может быть nil)
+	Stats *Stats
+	// MaxRedirects — сколько редиректов Fetch проходит для страницы (0 — не следовать)
+	MaxRedirects int
+	// DryRun — Fetch отправляет HEAD и никогда не читает тело ответа
+	DryRun bool
+	// RetryBackoff — базовая пауза перед повтором, удваивается с каждой
+	// попыткой (0 — DefaultRetryBackoff)
+	RetryBackoff time.Duration
+	// Sleep выдерживает паузы между повторами (nil — обычное ожидание).
+	// Подменяется в тестах.
+	Sleep Sleeper
+	// MaxConcurrentHosts — сколько хостов могут одновременно обрабатывать
+	// запросы (0 — без ограничения)
+	MaxConcurrentHosts int
+	// MaxBodyBytes — сколько байт тела (после распаковки) читается
+	// из ответа (0 — DefaultMaxBodyBytes)
+	MaxBodyBytes int64
+	// Logger получает события запросов (повторы и т.п.); nil — без логирования
+	Logger *slog.Logger
+	// CaptureHeaders — имена заголовков ответа, копируемые в FetchResult.Headers
+	CaptureHeaders []string
+	// Cache — кэш ответов GET и HEAD (nil — без кэша)
+	Cache *ResponseCache
 }
 
 // Fetcher выполняет HTTP-запросы с retry логикой и rate limiting
 type Fetcher struct {
-	client      HTTPClient
-	userAgent   string
-	timeout     time.Duration
-	maxRetries  int
-	rateLimiter *RateLimiter
+	client       HTTPClient
+	userAgent    string
+	timeout      time.Duration
+	maxRetries   int
+	maxRedirects int
+	dryRun       bool
+	headers      map[string]string
+	cookies      []*http.Cookie
+	basicUser    string
+	basicPass    string
+	bearerToken  string
+	stats        *Stats
+	rateLimiter  *RateLimiter
+	backoff      backoff
+	hostGate     *HostGate
+	maxBodyBytes int64
+	logger       *slog.Logger
+	// captureHeaders — канонические имена из FetcherConfig.CaptureHeaders
+	captureHeaders []string
+	cache          *ResponseCache
 }
 
 func NewFetcher(cfg FetcherConfig, rateLimiter *RateLimiter) *Fetcher {
-	return &Fetcher{
-		client:      cfg.Client,
-		userAgent:   cfg.UserAgent,
-		timeout:     cfg.Timeout,
-		maxRetries:  cfg.MaxRetries,
-		rateLimiter: rateLimiter,
+	f := &Fetcher{
+		client:       cfg.Client,
+		userAgent:    cfg.UserAgent,
+		timeout:      cfg.Timeout,
+		maxRetries:   cfg.MaxRetries,
+		maxRedirects: cfg.MaxRedirects,
+		dryRun:       cfg.DryRun,
+		headers:      cfg.Headers,
+		cookies:      cfg.Cookies,
+		basicUser:    cfg.BasicAuthUser,
+		basicPass:    cfg.BasicAuthPass,
+		bearerToken:  cfg.BearerToken,
+		stats:        cfg.Stats,
+		rateLimiter:  rateLimiter,
+		backoff:      newBackoff(cfg.RetryBackoff, cfg.Sleep),
+		hostGate:     NewHostGate(cfg.MaxConcurrentHosts),
+		maxBodyBytes: cfg.MaxBodyBytes,
+		logger:       cfg.Logger,
+		cache:        cfg.Cache,
+	}
+	if f.maxBodyBytes <= 0 {
+		f.maxBodyBytes = DefaultMaxBodyBytes
 	}
+	for _, name := range cfg.CaptureHeaders {
+		f.captureHeaders = append(f.captureHeaders, http.CanonicalHeaderKey(strings.TrimSpace(name)))
+	}
+	return f
 }
 
 func (f *Fetcher) Timeout() time.Duration {
@@ -62,22 +177,252 @@ func (f *Fetcher) RateLimiter() *RateLimiter {
 	return f.rateLimiter
 }
 
-// Fetch выполняет HTTP-запрос с retry логикой.
+func (f *Fetcher) Stats() *Stats {
+	return f.stats
+}
+
+// Logger возвращает логгер запросов (nil — логирование выключено)
+func (f *Fetcher) Logger() *slog.Logger {
+	return f.logger
+}
+
+// MaxBodyBytes возвращает, сколько байт тела ответа читается не больше
+func (f *Fetcher) MaxBodyBytes() int64 {
+	return f.maxBodyBytes
+}
+
+// WaitRetry выдерживает паузу перед повторной попыткой attempt (1 — первый
+// повтор) после неудачного result. Возвращает false, если контекст отменён.
+func (f *Fetcher) WaitRetry(ctx context.Context, attempt int, result FetchResult) bool {
+	return f.backoff.wait(ctx, attempt, result)
+}
+
+// Do отправляет запрос через HTTP-клиент и учитывает его в статистике.
+// Все компоненты краулера отправляют запросы только через этот метод.
+// После исчерпания лимита Stats запрос не отправляется (ErrRequestLimit).
+// При MaxConcurrentHosts запрос ждёт слота своего хоста; слот занят,
+// пока не закрыто тело ответа. С FetcherConfig.Cache ответ на GET или HEAD
+// берётся из кэша без обращения к сети и без учёта в статистике.
+func (f *Fetcher) Do(req *http.Request) (*http.Response, error) {
+	if !f.cache.cacheable(req) {
+		return f.send(req)
+	}
+	if resp, ok := f.cache.get(req); ok {
+		if f.logger != nil {
+			f.logger.Debug("response cache hit", "method", req.Method, "url", req.URL.String())
+		}
+		return resp, nil
+	}
+
+	resp, err := f.send(req)
+	if err != nil {
+		return nil, err
+	}
+	return f.cache.store(req, resp, f.maxBodyBytes), nil
+}
+
+// send отправляет запрос в сеть (см. Do)
+func (f *Fetcher) send(req *http.Request) (*http.Response, error) {
+	if f.hostGate == nil {
+		if !f.stats.reserveRequest() {
+			return nil, ErrRequestLimit
+		}
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		f.countResponse(resp)
+		return resp, nil
+	}
+
+	host := req.URL.Host
+	if err := f.hostGate.Acquire(req.Context(), host); err != nil {
+		return nil, err
+	}
+
+	if !f.stats.reserveRequest() {
+		f.hostGate.Release(host)
+		return nil, ErrRequestLimit
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		f.hostGate.Release(host)
+		return nil, err
+	}
+	f.countResponse(resp)
+
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: func() { f.hostGate.Release(host) }}
+	return resp, nil
+}
+
+// countResponse учитывает ответ в Stats: класс кода, редиректы и байты,
+// прочитанные из тела
+func (f *Fetcher) countResponse(resp *http.Response) {
+	if f.stats == nil {
+		return
+	}
+
+	f.stats.addResponse(resp.StatusCode)
+	f.stats.addRedirects(countRedirects(resp))
+	if resp.Body != nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, stats: f.stats}
+	}
+}
+
+// countRedirects считает ответы 3xx: редиректы, пройденные самим клиентом
+// (http.Client сохраняет их в Request.Response), и сам ответ, если он 3xx
+func countRedirects(resp *http.Response) int64 {
+	var n int64
+	if isRedirect(resp.StatusCode) {
+		n++
+	}
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		n++
+	}
+	return n
+}
+
+// NewRequest создаёт запрос с общими для всех компонентов заголовками:
+// User-Agent, Accept-Encoding, пользовательские заголовки, cookies и авторизация.
+// Тела сжатых ответов распаковываются через Decompress.
+// Пользовательские заголовки применяются после User-Agent, поэтому явно
+// заданный User-Agent имеет приоритет над --user-agent.
+func (f *Fetcher) NewRequest(ctx context.Context, method, urlStr string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.userAgent != "" {
+		req.Header.Set("User-Agent", f.userAgent)
+	}
+	req.Header.Set("Accept-Encoding", AcceptEncoding)
+
+	for key, value := range f.headers {
+		req.Header.Set(key, value)
+	}
+
+	for _, cookie := range f.cookies {
+		req.AddCookie(cookie)
+	}
+
+	switch {
+	case f.basicUser != "":
+		req.SetBasicAuth(f.basicUser, f.basicPass)
+	case f.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+f.bearerToken)
+	}
+
+	return req, nil
+}
+
+// ErrRedirectLoop — цепочка редиректов вернулась к уже посещённому URL
+var ErrRedirectLoop = errors.New("redirect loop")
+
+// Fetch загружает страницу, следуя не более чем MaxRedirects редиректам.
+// Если цепочка редиректов возвращается к уже посещённому URL, возвращается
+// результат с ошибкой ErrRedirectLoop, а RedirectChain заканчивается
+// повторённым URL.
+// В режиме DryRun страница запрашивается через HEAD.
+func (f *Fetcher) Fetch(ctx context.Context, urlStr string) FetchResult {
+	return f.fetch(ctx, f.pageMethod(), urlStr)
+}
+
+// Get загружает документ как Fetch, но всегда через GET, в том числе
+// в режиме DryRun: тело нужно самому краулеру (например, sitemap)
+func (f *Fetcher) Get(ctx context.Context, urlStr string) FetchResult {
+	return f.fetch(ctx, http.MethodGet, urlStr)
+}
+
+func (f *Fetcher) fetch(ctx context.Context, method, urlStr string) FetchResult {
+	chain := []string{urlStr}
+	current := urlStr
+	// Цикл ищется по нормализованным URL, а запрашиваются адреса ровно
+	// такие, как в Location
+	visited := map[string]bool{redirectKey(urlStr): true}
+
+	for {
+		result := f.fetchWithRetry(ctx, method, current)
+		result.FinalURL = current
+
+		if !isRedirect(result.StatusCode) || result.Location == "" {
+			if len(chain) > 1 {
+				result.RedirectChain = chain
+			}
+			return result
+		}
+
+		next, err := resolveLocation(current, result.Location)
+		if err == nil {
+			result.RedirectTarget = next
+		}
+
+		if f.maxRedirects <= 0 {
+			if len(chain) > 1 {
+				result.RedirectChain = chain
+			}
+			return result
+		}
+
+		if err != nil {
+			result.Error = fmt.Errorf("invalid redirect location %q: %w", result.Location, err)
+			result.RedirectChain = chain
+			return result
+		}
+
+		if visited[redirectKey(next)] {
+			result.RedirectChain = append(chain, next)
+			result.Error = fmt.Errorf("%w: %s", ErrRedirectLoop, strings.Join(result.RedirectChain, " -> "))
+			return result
+		}
+
+		if len(chain) > f.maxRedirects {
+			result.RedirectChain = chain
+			result.Error = fmt.Errorf("stopped after %d redirects", f.maxRedirects)
+			return result
+		}
+
+		chain = append(chain, next)
+		visited[redirectKey(next)] = true
+		current = next
+	}
+}
+
+// Head отправляет HEAD-запрос (с теми же повторами, что и Fetch) и возвращает
+// статус и заголовки ответа, не читая тело. Редиректы не проходятся.
+func (f *Fetcher) Head(ctx context.Context, urlStr string) FetchResult {
+	result := f.fetchWithRetry(ctx, http.MethodHead, urlStr)
+	result.FinalURL = urlStr
+	return result
+}
+
+// pageMethod — метод загрузки страниц: GET, а в режиме DryRun — HEAD
+func (f *Fetcher) pageMethod() string {
+	if f.dryRun {
+		return http.MethodHead
+	}
+	return http.MethodGet
+}
+
+// fetchWithRetry выполняет HTTP-запрос с retry логикой.
 // Retry выполняется при: сетевых ошибках, HTTP 429, HTTP 5xx.
-func (f *Fetcher) Fetch(ctx context.Context, url string) FetchResult {
+func (f *Fetcher) fetchWithRetry(ctx context.Context, method, url string) FetchResult {
+	var result FetchResult
 	for attempt := 0; attempt <= f.maxRetries; attempt++ {
 		if ctx.Err() != nil {
 			return FetchResult{Error: ctx.Err()}
 		}
 
-		// Задержка 100ms перед повторной попыткой
+		// Экспоненциальная пауза перед повторной попыткой
 		if attempt > 0 {
-			if !f.waitForRetry(ctx) {
+			LogRetry(f.logger, url, attempt, result)
+			if !f.WaitRetry(ctx, attempt, result) {
 				return FetchResult{Error: ctx.Err()}
 			}
 		}
 
-		result := f.performRequest(ctx, url)
+		result = f.performRequest(ctx, method, url)
+		result.Attempts = attempt + 1
 
 		// Успех — не требует retry
 		if result.Error == nil && result.StatusCode < 500 && result.StatusCode != 429 {
@@ -92,10 +437,24 @@ func (f *Fetcher) Fetch(ctx context.Context, url string) FetchResult {
 	return FetchResult{}
 }
 
-// shouldRetry: сетевые ошибки, 429 Too Many Requests, 5xx Server Errors
+// LogRetry сообщает о повторной попытке attempt запроса urlStr после результата
+// result. Без логгера ничего не делает и не аллоцирует.
+func LogRetry(logger *slog.Logger, urlStr string, attempt int, result FetchResult) {
+	if logger == nil {
+		return
+	}
+	if result.Error != nil {
+		logger.Debug("retrying request", "url", urlStr, "attempt", attempt, "error", result.Error)
+		return
+	}
+	logger.Debug("retrying request", "url", urlStr, "attempt", attempt, "status", result.StatusCode)
+}
+
+// shouldRetry: сетевые ошибки, 429 Too Many Requests, 5xx Server Errors.
+// Исчерпанный лимит запросов повтором не лечится.
 func (f *Fetcher) shouldRetry(result FetchResult) bool {
 	if result.Error != nil {
-		return true
+		return !errors.Is(result.Error, ErrRequestLimit)
 	}
 	if result.StatusCode == 429 {
 		return true
@@ -106,57 +465,163 @@ func (f *Fetcher) shouldRetry(result FetchResult) bool {
 	return false
 }
 
-func (f *Fetcher) performRequest(ctx context.Context, urlStr string) FetchResult {
+func (f *Fetcher) performRequest(ctx context.Context, method, urlStr string) FetchResult {
 	if f.rateLimiter != nil {
 		if !f.rateLimiter.Wait(ctx) {
 			return FetchResult{Error: ctx.Err()}
 		}
 	}
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	timeoutCtx, cancel := context.WithTimeout(withManualRedirects(ctx), f.timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodGet, urlStr, nil)
+	req, err := f.NewRequest(timeoutCtx, method, urlStr)
 	if err != nil {
 		return FetchResult{Error: err}
 	}
-
-	if f.userAgent != "" {
-		req.Header.Set("User-Agent", f.userAgent)
+	if method == http.MethodGet {
+		applyValidators(ctx, req, urlStr)
 	}
 
-	resp, err := f.client.Do(req)
+	resp, err := f.Do(req)
 	if err != nil {
-		return FetchResult{Error: err}
+		return FetchResult{Error: err, ErrorKind: ClassifyError(err)}
 	}
 
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
-	result := FetchResult{StatusCode: resp.StatusCode}
+	result := FetchResult{
+		StatusCode:    resp.StatusCode,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		Location:      resp.Header.Get("Location"),
+		Server:        resp.Header.Get("Server"),
+		PoweredBy:     resp.Header.Get("X-Powered-By"),
+		RetryAfter:    ParseRetryAfter(resp.Header.Get("Retry-After")),
+		Validators: Validators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+		Headers: f.capturedHeaders(resp.Header),
+		TLS:     resp.TLS,
+	}
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		if isTextContent(resp.Header.Get("Content-Type")) {
-			body, err := io.ReadAll(resp.Body)
-			if err == nil {
-				result.HTMLContent = string(body)
-			}
+	if f.readsBody(method, resp) {
+		body, truncated, err := readBody(resp, f.maxBodyBytes)
+		if err == nil {
+			result.Body = body
+			result.Truncated = truncated
+			result.Charset = DetectCharset(result.ContentType, body)
+			result.HTMLContent = string(ToUTF8(body, result.Charset))
 		}
 	}
 
 	return result
 }
 
-func (f *Fetcher) waitForRetry(ctx context.Context) bool {
-	select {
-	case <-ctx.Done():
+// readsBody решает, читать ли тело ответа в HTMLContent. Читаются только
+// текстовые ответы на GET, которые станут итоговым результатом Fetch:
+// 2xx, а также 3xx (кроме 304), по которым Fetch дальше не пойдёт, —
+// редиректы отключены (MaxRedirects 0) или в ответе нет Location.
+// Тела ошибок (4xx, 5xx) и 304 не читаются.
+func (f *Fetcher) readsBody(method string, resp *http.Response) bool {
+	if method != http.MethodGet || !IsTextContent(resp.Header.Get("Content-Type")) {
 		return false
-	case <-time.After(100 * time.Millisecond):
+	}
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
 		return true
+	case isRedirect(resp.StatusCode):
+		return f.maxRedirects <= 0 || resp.Header.Get("Location") == ""
+	default:
+		return false
 	}
 }
 
-func isTextContent(contentType string) bool {
+// capturedHeaders копирует из ответа заголовки captureHeaders; несколько
+// значений одного заголовка объединяются через ", "
+func (f *Fetcher) capturedHeaders(header http.Header) map[string]string {
+	var captured map[string]string
+	for _, name := range f.captureHeaders {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if captured == nil {
+			captured = make(map[string]string, len(f.captureHeaders))
+		}
+		captured[name] = strings.Join(values, ", ")
+	}
+	return captured
+}
+
+// isRedirect: ответ 3xx, кроме 304 Not Modified (он ни на что не перенаправляет)
+func isRedirect(statusCode int) bool {
+	return statusCode >= 300 && statusCode < 400 && statusCode != http.StatusNotModified
+}
+
+// resolveLocation разрешает Location относительно URL запроса
+// (поддерживаются абсолютные, корневые и относительные пути)
+func resolveLocation(requestURL, location string) (string, error) {
+	base, err := url.Parse(requestURL)
+	if err != nil {
+		return "", err
+	}
+	loc, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(loc).String(), nil
+}
+
+// redirectKey — ключ URL для поиска цикла редиректов: https://example.com/
+// и https://EXAMPLE.com — один адрес. Порядок query-параметров сохраняется:
+// редирект /p?b=2&a=1 → /p?a=1&b=2 приводит URL к каноническому виду
+// и циклом не считается.
+func redirectKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	withoutQuery := *u
+	withoutQuery.RawQuery = ""
+	key := urlutil.NormalizeURL(&withoutQuery)
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
+
+// IsTextContent сообщает, что Fetch читает тело ответа с таким Content-Type
+// (HTML и XML); тела остальных ответов не загружаются
+func IsTextContent(contentType string) bool {
 	return strings.Contains(contentType, "text/html") || strings.Contains(contentType, "xml")
 }
+
+// IsHTMLContent сообщает, что Content-Type — HTML-страница (text/html);
+// XML, в отличие от IsTextContent, сюда не входит
+func IsHTMLContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/html"
+}
+
+// readBody читает не больше limit байт тела ответа, распаковывая его по
+// Content-Encoding. truncated — тело оказалось длиннее limit.
+func readBody(resp *http.Response, limit int64) (body []byte, truncated bool, err error) {
+	r, err := Decompress(resp.Header.Get("Content-Encoding"), resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Лишний байт показывает, что тело не уместилось в лимит
+	body, err = io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(body)) > limit {
+		return body[:limit], true, nil
+	}
+	return body, false, nil
+}
@@ -0,0 +1,561 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockClient struct {
+	doFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockClient) Do(req *http.Request) (*http.Response, error) {
+	return m.doFunc(req)
+}
+
+// redirectClient отвечает 302 по карте path -> Location, остальные пути — 200
+func redirectClient(redirects map[string]string) *mockClient {
+	return &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			if location, ok := redirects[req.URL.Path]; ok {
+				return &http.Response{
+					StatusCode: 302,
+					Header:     http.Header{"Location": []string{location}},
+					Body:       io.NopCloser(strings.NewReader("")),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader("<html></html>")),
+			}, nil
+		},
+	}
+}
+
+func TestFetchFollowsRedirects(t *testing.T) {
+	client := redirectClient(map[string]string{"/a": "/b", "/b": "https://example.com/c"})
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxRedirects: 5}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/a")
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if result.StatusCode != 200 || result.FinalURL != "https://example.com/c" {
+		t.Fatalf("expected 200 from /c, got %d from %s", result.StatusCode, result.FinalURL)
+	}
+
+	expected := "https://example.com/a https://example.com/b https://example.com/c"
+	if got := strings.Join(result.RedirectChain, " "); got != expected {
+		t.Fatalf("expected chain %q, got %q", expected, got)
+	}
+}
+
+func TestResolveLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		expected string
+	}{
+		{"absolute", "https://other.com/target", "https://other.com/target"},
+		{"root-relative", "/new-path", "https://example.com/new-path"},
+		{"path-relative", "next", "https://example.com/docs/next"},
+		{"parent-relative", "../up", "https://example.com/up"},
+		{"query only", "?page=2", "https://example.com/docs/old?page=2"},
+		{"scheme-relative", "//cdn.example.com/file", "https://cdn.example.com/file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveLocation("https://example.com/docs/old", tt.location)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("resolveLocation(%q) = %q, want %q", tt.location, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFetchFollowsRelativeRedirects(t *testing.T) {
+	client := redirectClient(map[string]string{
+		"/docs/old": "new",
+		"/docs/new": "../moved/",
+		"/moved/":   "https://example.com/final",
+	})
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxRedirects: 5}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/docs/old")
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+
+	expected := "https://example.com/docs/old https://example.com/docs/new https://example.com/moved/ https://example.com/final"
+	if got := strings.Join(result.RedirectChain, " "); got != expected {
+		t.Fatalf("expected chain %q, got %q", expected, got)
+	}
+	if result.FinalURL != "https://example.com/final" {
+		t.Errorf("expected final URL https://example.com/final, got %s", result.FinalURL)
+	}
+}
+
+func TestFetchDetectsRedirectLoop(t *testing.T) {
+	client := redirectClient(map[string]string{"/a": "/b", "/b": "/a"})
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxRedirects: 10}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/a")
+	if !errors.Is(result.Error, ErrRedirectLoop) {
+		t.Fatalf("expected ErrRedirectLoop, got %v", result.Error)
+	}
+
+	expected := "https://example.com/a https://example.com/b https://example.com/a"
+	if got := strings.Join(result.RedirectChain, " "); got != expected {
+		t.Fatalf("expected chain %q, got %q", expected, got)
+	}
+}
+
+func TestFetchDetectsRedirectLoopToSeed(t *testing.T) {
+	client := redirectClient(map[string]string{"/": "/a", "/a": "https://EXAMPLE.com"})
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxRedirects: 10}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/")
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "redirect loop") {
+		t.Fatalf("expected redirect loop error, got %v", result.Error)
+	}
+
+	expected := "https://example.com/ https://example.com/a https://EXAMPLE.com"
+	if got := strings.Join(result.RedirectChain, " "); got != expected {
+		t.Fatalf("expected loop to be caught at the first repeat, got chain %q", got)
+	}
+}
+
+func TestFetchQueryReorderIsNotLoop(t *testing.T) {
+	client := &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.RawQuery == "b=2&a=1" {
+				return &http.Response{
+					StatusCode: 301,
+					Header:     http.Header{"Location": []string{"/p?a=1&b=2"}},
+					Body:       io.NopCloser(strings.NewReader("")),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader("<html></html>")),
+			}, nil
+		},
+	}
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxRedirects: 5}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/p?b=2&a=1")
+	if result.Error != nil {
+		t.Fatalf("canonicalizing redirect must not be a loop: %v", result.Error)
+	}
+	if result.StatusCode != 200 || result.FinalURL != "https://example.com/p?a=1&b=2" {
+		t.Errorf("unexpected result: status %d, final URL %s", result.StatusCode, result.FinalURL)
+	}
+}
+
+func TestFetchRequestsExactLocation(t *testing.T) {
+	var requested []string
+	redirects := redirectClient(map[string]string{"/a": "/B?z=1&a=2"})
+	client := &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			requested = append(requested, req.URL.String())
+			return redirects.Do(req)
+		},
+	}
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxRedirects: 5}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/a")
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if len(requested) != 2 || requested[1] != "https://example.com/B?z=1&a=2" {
+		t.Errorf("expected the redirect target to be requested as given in Location, got %v", requested)
+	}
+	if result.FinalURL != "https://example.com/B?z=1&a=2" {
+		t.Errorf("unexpected final URL %s", result.FinalURL)
+	}
+}
+
+func TestFetchRedirectsDisabled(t *testing.T) {
+	client := redirectClient(map[string]string{"/a": "/b"})
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/a")
+	if result.StatusCode != 302 || result.Location != "/b" || result.RedirectChain != nil {
+		t.Fatalf("expected unfollowed 302, got %+v", result)
+	}
+}
+
+func TestFetchReadsBody(t *testing.T) {
+	cases := []struct {
+		name         string
+		status       int
+		contentType  string
+		location     string
+		maxRedirects int
+		method       string
+		expectBody   bool
+	}{
+		{"2xx html", 200, "text/html", "", 0, http.MethodGet, true},
+		{"2xx xml", 200, "application/xml", "", 0, http.MethodGet, true},
+		{"2xx json", 200, "application/json", "", 0, http.MethodGet, false},
+		{"2xx head", 200, "text/html", "", 0, http.MethodHead, false},
+		{"3xx redirects disabled", 302, "text/html", "/next", 0, http.MethodGet, true},
+		{"3xx non-text", 302, "application/octet-stream", "/next", 0, http.MethodGet, false},
+		{"3xx without location", 300, "text/html", "", 5, http.MethodGet, true},
+		{"3xx followed", 302, "text/html", "/next", 5, http.MethodGet, false},
+		{"304", 304, "text/html", "", 0, http.MethodGet, false},
+		{"4xx", 404, "text/html", "", 0, http.MethodGet, false},
+		{"5xx", 500, "text/html", "", 0, http.MethodGet, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockClient{
+				doFunc: func(req *http.Request) (*http.Response, error) {
+					header := http.Header{"Content-Type": []string{tc.contentType}}
+					if tc.location != "" {
+						header.Set("Location", tc.location)
+					}
+					return &http.Response{
+						StatusCode: tc.status,
+						Header:     header,
+						Body:       io.NopCloser(strings.NewReader("<html>body</html>")),
+					}, nil
+				},
+			}
+			fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxRedirects: tc.maxRedirects}, nil)
+
+			result := fetcher.performRequest(context.Background(), tc.method, "https://example.com/")
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, result.StatusCode)
+			}
+			if got := result.HTMLContent != ""; got != tc.expectBody {
+				t.Errorf("expected body read = %v, got %q", tc.expectBody, result.HTMLContent)
+			}
+		})
+	}
+}
+
+func TestFetchStopsAfterMaxRedirects(t *testing.T) {
+	client := redirectClient(map[string]string{"/a": "/b", "/b": "/c", "/c": "/d"})
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxRedirects: 2}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/a")
+	if result.Error == nil || result.StatusCode != 302 {
+		t.Fatalf("expected error after 2 redirects, got %+v", result)
+	}
+}
+
+func TestNewClientLeavesPageRedirectsToFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherConfig{Client: NewClient(), Timeout: time.Second}, nil)
+
+	result := fetcher.Fetch(context.Background(), server.URL+"/old")
+	if result.StatusCode != http.StatusMovedPermanently {
+		t.Fatalf("expected page fetch to see 301, got %d", result.StatusCode)
+	}
+
+	req, _ := fetcher.NewRequest(context.Background(), http.MethodHead, server.URL+"/old")
+	resp, err := fetcher.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected other requests to follow redirects, got %d", resp.StatusCode)
+	}
+}
+
+func TestStatsCountRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	stats := NewStats()
+	fetcher := NewFetcher(FetcherConfig{Client: NewClient(), Timeout: time.Second, MaxRedirects: 5, Stats: stats}, nil)
+
+	// Страница: редиректы проходит Fetcher, каждый 3xx — отдельный запрос
+	if result := fetcher.Fetch(context.Background(), server.URL+"/a"); result.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 after redirects, got %d", result.StatusCode)
+	}
+
+	// Прочие запросы: редиректы проходит сам клиент
+	req, _ := fetcher.NewRequest(context.Background(), http.MethodHead, server.URL+"/a")
+	resp, err := fetcher.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if got := stats.Redirects(); got != 4 {
+		t.Fatalf("expected 4 redirects, got %d", got)
+	}
+}
+
+func TestFetchMaxBodyBytes(t *testing.T) {
+	body := "<html><body>" + strings.Repeat("x", 100) + "</body></html>"
+	client := &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}, nil
+		},
+	}
+
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxBodyBytes: 32}, nil)
+	result := fetcher.Fetch(context.Background(), "https://example.com/")
+	if !result.Truncated || result.HTMLContent != body[:32] {
+		t.Fatalf("expected body truncated to 32 bytes, got %d bytes (truncated=%v)", len(result.HTMLContent), result.Truncated)
+	}
+
+	fetcher = NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxBodyBytes: int64(len(body))}, nil)
+	result = fetcher.Fetch(context.Background(), "https://example.com/")
+	if result.Truncated || result.HTMLContent != body {
+		t.Fatalf("expected body that fits the limit to be read whole, got %d bytes", len(result.HTMLContent))
+	}
+
+	if got := NewFetcher(FetcherConfig{Client: client}, nil).MaxBodyBytes(); got != DefaultMaxBodyBytes {
+		t.Fatalf("expected default limit %d, got %d", DefaultMaxBodyBytes, got)
+	}
+}
+
+func TestStatsSnapshot(t *testing.T) {
+	statuses := map[string]int{"/ok": 200, "/missing": 404, "/error": 500}
+	client := &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/down" {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return &http.Response{
+				StatusCode: statuses[req.URL.Path],
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				Body:       io.NopCloser(strings.NewReader("<html>12345</html>")),
+			}, nil
+		},
+	}
+
+	stats := NewStats()
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, Stats: stats}, nil)
+	for _, path := range []string{"/ok", "/ok", "/missing", "/error", "/down"} {
+		fetcher.Fetch(context.Background(), "https://example.com"+path)
+	}
+
+	if stats.Requests() != 5 {
+		t.Errorf("expected 5 requests, got %d", stats.Requests())
+	}
+	snapshot := stats.Snapshot()
+	// Тело читается только у ответов 2xx
+	if snapshot.BytesRead != 2*int64(len("<html>12345</html>")) {
+		t.Errorf("expected bytes of two 200 bodies, got %d", snapshot.BytesRead)
+	}
+	expected := map[string]int64{"2xx": 2, "4xx": 1, "5xx": 1}
+	if len(snapshot.StatusClasses) != len(expected) {
+		t.Fatalf("expected classes %v, got %v", expected, snapshot.StatusClasses)
+	}
+	for class, n := range expected {
+		if snapshot.StatusClasses[class] != n {
+			t.Errorf("expected %d %s responses, got %d", n, class, snapshot.StatusClasses[class])
+		}
+	}
+}
+
+func TestFetchLogsRetries(t *testing.T) {
+	calls := 0
+	client := &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			calls++
+			status := http.StatusServiceUnavailable
+			if calls > 1 {
+				status = http.StatusOK
+			}
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+		},
+	}
+
+	var logs bytes.Buffer
+	var delays []time.Duration
+	fetcher := NewFetcher(FetcherConfig{
+		Client:     client,
+		Timeout:    time.Second,
+		MaxRetries: 2,
+		Sleep:      recordingSleeper(&delays),
+		Logger:     slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/")
+	if result.StatusCode != http.StatusOK {
+		t.Fatalf("expected retry to succeed, got %d", result.StatusCode)
+	}
+	if result.Attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", result.Attempts)
+	}
+	if !strings.Contains(logs.String(), `msg="retrying request" url=https://example.com/ attempt=1 status=503`) {
+		t.Errorf("expected retry to be logged, got %q", logs.String())
+	}
+}
+
+func TestLogRetryWithoutLoggerDoesNotAllocate(t *testing.T) {
+	result := FetchResult{StatusCode: http.StatusServiceUnavailable}
+	allocs := testing.AllocsPerRun(100, func() {
+		LogRetry(nil, "https://example.com/", 1, result)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations without a logger, got %v", allocs)
+	}
+}
+
+func TestFetcherHead(t *testing.T) {
+	var methods []string
+	client := &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			methods = append(methods, req.Method)
+			return &http.Response{
+				StatusCode:    200,
+				Header:        http.Header{"Content-Type": []string{"application/pdf"}},
+				ContentLength: 5 << 20,
+				Body:          io.NopCloser(strings.NewReader("")),
+			}, nil
+		},
+	}
+
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second}, nil)
+	result := fetcher.Head(context.Background(), "https://example.com/doc.pdf")
+	if result.Error != nil || result.StatusCode != 200 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.ContentType != "application/pdf" || result.ContentLength != 5<<20 {
+		t.Errorf("expected HEAD headers, got type %q length %d", result.ContentType, result.ContentLength)
+	}
+	if strings.Join(methods, ",") != http.MethodHead {
+		t.Errorf("expected a single HEAD request, got %v", methods)
+	}
+}
+
+func TestFetchWithValidators(t *testing.T) {
+	client := &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/old" {
+				return &http.Response{
+					StatusCode: http.StatusMovedPermanently,
+					Header:     http.Header{"Location": []string{"/new"}},
+					Body:       io.NopCloser(strings.NewReader("")),
+				}, nil
+			}
+			if req.Header.Get("If-None-Match") == `"v1"` && req.Header.Get("If-Modified-Since") == "Mon, 01 Jan 2024 00:00:00 GMT" {
+				return &http.Response{StatusCode: http.StatusNotModified, Body: io.NopCloser(strings.NewReader(""))}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"text/html"}, "Etag": []string{`"v2"`}},
+				Body:       io.NopCloser(strings.NewReader("<html></html>")),
+			}, nil
+		},
+	}
+	stats := NewStats()
+	fetcher := NewFetcher(FetcherConfig{Client: client, Timeout: time.Second, MaxRedirects: 5, Stats: stats}, nil)
+	validators := Validators{ETag: `"v1"`, LastModified: "Mon, 01 Jan 2024 00:00:00 GMT"}
+
+	result := fetcher.Fetch(WithValidators(context.Background(), "https://example.com/page", validators), "https://example.com/page")
+	if result.StatusCode != http.StatusNotModified || result.Error != nil {
+		t.Fatalf("expected 304 for a conditional request, got %d (%v)", result.StatusCode, result.Error)
+	}
+	if stats.Redirects() != 0 {
+		t.Errorf("expected 304 not to be counted as a redirect, got %d", stats.Redirects())
+	}
+
+	result = fetcher.Fetch(context.Background(), "https://example.com/page")
+	if result.StatusCode != 200 || result.Validators.ETag != `"v2"` {
+		t.Fatalf("expected 200 with ETag for a plain request, got %d %+v", result.StatusCode, result.Validators)
+	}
+
+	// Валидаторы относятся к исходному URL и не отправляются после редиректа
+	result = fetcher.Fetch(WithValidators(context.Background(), "https://example.com/old", validators), "https://example.com/old")
+	if result.StatusCode != 200 {
+		t.Fatalf("expected redirect target to be fetched unconditionally, got %d", result.StatusCode)
+	}
+}
+
+func TestFetchCapturesHeadersOfFinalResponse(t *testing.T) {
+	client := &mockClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/old" {
+				return &http.Response{
+					StatusCode: http.StatusMovedPermanently,
+					Header: http.Header{
+						"Location":        []string{"/new"},
+						"Cache-Control":   []string{"max-age=60"},
+						"X-Frame-Options": []string{"SAMEORIGIN"},
+					},
+					Body: io.NopCloser(strings.NewReader("")),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header: http.Header{
+					"Content-Type":            []string{"text/html"},
+					"Content-Security-Policy": []string{"default-src 'self'"},
+					"Cache-Control":           []string{"no-cache", "no-store"},
+				},
+				Body: io.NopCloser(strings.NewReader("<html></html>")),
+			}, nil
+		},
+	}
+	fetcher := NewFetcher(FetcherConfig{
+		Client:         client,
+		Timeout:        time.Second,
+		MaxRedirects:   5,
+		CaptureHeaders: []string{"content-security-policy", "Cache-Control", "X-Frame-Options"},
+	}, nil)
+
+	result := fetcher.Fetch(context.Background(), "https://example.com/old")
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+
+	expected := map[string]string{
+		"Content-Security-Policy": "default-src 'self'",
+		"Cache-Control":           "no-cache, no-store",
+	}
+	if !maps.Equal(result.Headers, expected) {
+		t.Errorf("expected headers of the final response %v, got %v", expected, result.Headers)
+	}
+
+	// Без CaptureHeaders заголовки не копируются
+	result = NewFetcher(FetcherConfig{Client: client, Timeout: time.Second}, nil).Fetch(context.Background(), "https://example.com/new")
+	if result.Headers != nil {
+		t.Errorf("expected no captured headers, got %v", result.Headers)
+	}
+}
@@ -0,0 +1,88 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// HostGate ограничивает число хостов, к которым одновременно выполняются
+// запросы. Запросы к уже активному хосту проходят без ожидания; запрос к
+// новому хосту ждёт, пока активных хостов станет меньше max.
+// Нулевой *HostGate ничего не ограничивает.
+type HostGate struct {
+	max     int
+	mu      sync.Mutex
+	active  map[string]int
+	changed chan struct{}
+}
+
+func NewHostGate(maxHosts int) *HostGate {
+	if maxHosts <= 0 {
+		return nil
+	}
+	return &HostGate{
+		max:     maxHosts,
+		active:  make(map[string]int),
+		changed: make(chan struct{}),
+	}
+}
+
+// Acquire занимает слот хоста. Возвращает ошибку контекста, если он
+// отменён раньше, чем освободился слот.
+func (g *HostGate) Acquire(ctx context.Context, host string) error {
+	if g == nil {
+		return nil
+	}
+
+	for {
+		g.mu.Lock()
+		if g.active[host] > 0 || len(g.active) < g.max {
+			g.active[host]++
+			g.mu.Unlock()
+			return nil
+		}
+		wait := g.changed
+		g.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+// Release освобождает слот, занятый Acquire
+func (g *HostGate) Release(host string) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.active[host]--
+	if g.active[host] > 0 {
+		return
+	}
+	delete(g.active, host)
+
+	// Будим всех ожидающих: освободился слот для нового хоста
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// releaseOnClose освобождает слот хоста при закрытии тела ответа:
+// запрос считается активным, пока тело не дочитано и не закрыто
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
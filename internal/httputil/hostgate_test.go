@@ -0,0 +1,57 @@
+package httputil
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHostGateLimitsHosts(t *testing.T) {
+	gate := NewHostGate(1)
+
+	if err := gate.Acquire(context.Background(), "a.example.com"); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	// Второй запрос к активному хосту не ждёт
+	if err := gate.Acquire(context.Background(), "a.example.com"); err != nil {
+		t.Fatalf("Acquire for active host failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := gate.Acquire(ctx, "b.example.com"); err == nil {
+		t.Fatal("expected second host to wait while the first is active")
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		_ = gate.Acquire(context.Background(), "b.example.com")
+		close(acquired)
+	}()
+
+	gate.Release("a.example.com")
+	select {
+	case <-acquired:
+		t.Fatal("host slot released while a request was still active")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	gate.Release("a.example.com")
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected second host to proceed after release")
+	}
+}
+
+func TestNilHostGate(t *testing.T) {
+	var gate *HostGate
+	if err := gate.Acquire(context.Background(), "example.com"); err != nil {
+		t.Errorf("nil gate should not block: %v", err)
+	}
+	gate.Release("example.com")
+
+	if NewHostGate(0) != nil {
+		t.Error("expected NewHostGate(0) to disable the limit")
+	}
+}
@@ -0,0 +1,140 @@
+package httputil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrRequestLimit возвращается Fetcher.Do, когда исчерпан лимит запросов
+var ErrRequestLimit = errors.New("request limit reached")
+
+// Stats — общие счётчики HTTP-запросов всех компонентов краулера
+type Stats struct {
+	requests  atomic.Int64
+	redirects atomic.Int64
+	bytesRead atomic.Int64
+	// statusClasses — число ответов по классам: индекс 1 — 1xx, ..., 5 — 5xx;
+	// 0 — коды вне этих классов
+	statusClasses [6]atomic.Int64
+
+	// limit — максимум запросов (0 — без ограничения)
+	limit     int64
+	onLimit   func()
+	limitOnce sync.Once
+}
+
+func NewStats() *Stats {
+	return &Stats{}
+}
+
+// SetLimit ограничивает общее число запросов. onReached вызывается один раз,
+// при первой попытке превысить лимит. Вызывается до начала обхода.
+func (s *Stats) SetLimit(limit int64, onReached func()) {
+	s.limit = limit
+	s.onLimit = onReached
+}
+
+// Requests возвращает общее число отправленных запросов
+func (s *Stats) Requests() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.requests.Load()
+}
+
+// reserveRequest учитывает запрос, если лимит не исчерпан
+func (s *Stats) reserveRequest() bool {
+	if s == nil {
+		return true
+	}
+
+	for {
+		n := s.requests.Load()
+		if s.limit > 0 && n >= s.limit {
+			if s.onLimit != nil {
+				s.limitOnce.Do(s.onLimit)
+			}
+			return false
+		}
+		if s.requests.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// Redirects возвращает число полученных ответов 3xx
+func (s *Stats) Redirects() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.redirects.Load()
+}
+
+// addRedirects учитывает n ответов 3xx
+func (s *Stats) addRedirects(n int64) {
+	if s == nil || n == 0 {
+		return
+	}
+	s.redirects.Add(n)
+}
+
+// StatsSnapshot — значения счётчиков Stats на момент вызова Snapshot.
+// Число запросов в него не входит: это Stats.Requests, в отчёте — только
+// summary.total_requests.
+type StatsSnapshot struct {
+	// BytesRead — байт тел ответов, прочитанных из сети (до распаковки)
+	BytesRead int64 `json:"bytes_read"`
+	// StatusClasses — число ответов по классам кода ("2xx", "4xx"...);
+	// запросы без ответа (сетевые ошибки) не учитываются
+	StatusClasses map[string]int64 `json:"status_classes"`
+}
+
+// Snapshot возвращает текущие значения счётчиков
+func (s *Stats) Snapshot() StatsSnapshot {
+	snapshot := StatsSnapshot{StatusClasses: map[string]int64{}}
+	if s == nil {
+		return snapshot
+	}
+
+	snapshot.BytesRead = s.bytesRead.Load()
+	for class := range s.statusClasses {
+		n := s.statusClasses[class].Load()
+		if n == 0 {
+			continue
+		}
+		name := "other"
+		if class > 0 {
+			name = fmt.Sprintf("%dxx", class)
+		}
+		snapshot.StatusClasses[name] = n
+	}
+	return snapshot
+}
+
+// addResponse учитывает класс кода ответа
+func (s *Stats) addResponse(statusCode int) {
+	if s == nil {
+		return
+	}
+
+	class := statusCode / 100
+	if class < 1 || class > 5 {
+		class = 0
+	}
+	s.statusClasses[class].Add(1)
+}
+
+// countingBody учитывает прочитанные из тела ответа байты в Stats
+type countingBody struct {
+	io.ReadCloser
+	stats *Stats
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.stats.bytesRead.Add(int64(n))
+	return n, err
+}
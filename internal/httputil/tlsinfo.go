@@ -0,0 +1,43 @@
+package httputil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+)
+
+// CertInfo — сведения о сертификате сервера из TLS-соединения
+type CertInfo struct {
+	// NotAfter — окончание срока действия конечного сертификата
+	NotAfter time.Time
+	// Issuer — издатель конечного сертификата
+	Issuer string
+	// Valid — цепочка сертификатов проверяется для имени host
+	Valid bool
+}
+
+// PeerCertInfo возвращает сведения о конечном сертификате соединения.
+// Если транспорт проверил цепочку при рукопожатии, она считается
+// действительной; иначе (InsecureSkipVerify) цепочка проверяется по
+// системным корневым сертификатам. ok = false, если сертификатов нет.
+func PeerCertInfo(state *tls.ConnectionState, host string) (info CertInfo, ok bool) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return CertInfo{}, false
+	}
+
+	leaf := state.PeerCertificates[0]
+	info = CertInfo{
+		NotAfter: leaf.NotAfter,
+		Issuer:   leaf.Issuer.String(),
+		Valid:    len(state.VerifiedChains) > 0,
+	}
+	if !info.Valid {
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Intermediates: intermediates})
+		info.Valid = err == nil
+	}
+	return info, true
+}
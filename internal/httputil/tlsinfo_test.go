@@ -0,0 +1,42 @@
+package httputil
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPeerCertInfo(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	get := func(client *http.Client) *tls.ConnectionState {
+		t.Helper()
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.TLS
+	}
+
+	// Клиент сервера доверяет его сертификату: цепочка проверена при рукопожатии
+	info, ok := PeerCertInfo(get(server.Client()), "example.com")
+	if !ok {
+		t.Fatal("expected certificate info")
+	}
+	if !info.NotAfter.Equal(server.Certificate().NotAfter) || info.Issuer != server.Certificate().Issuer.String() || !info.Valid {
+		t.Errorf("unexpected certificate info %+v", info)
+	}
+
+	// Без проверки при рукопожатии тестовый сертификат не проходит проверку по системным корням
+	insecure := NewClientWithConfig(ClientConfig{InsecureSkipVerify: true})
+	if info, ok := PeerCertInfo(get(insecure), "example.com"); !ok || info.Valid {
+		t.Errorf("expected self-signed certificate to be invalid, got %+v", info)
+	}
+
+	if _, ok := PeerCertInfo(nil, "example.com"); ok {
+		t.Error("expected no info without TLS")
+	}
+}
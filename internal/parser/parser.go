@@ -2,6 +2,7 @@ package parser
 
 import (
 	"net/url"
+	"sort"
 	"strings"
 
 	"golang.org/x/net/html"
@@ -15,30 +16,61 @@ type AssetInfo struct {
 }
 
 // HTMLParser парсит HTML и извлекает ссылки и ассеты
-type HTMLParser struct{}
+type HTMLParser struct {
+	// includeNoscript — извлекать ссылки и ассеты из <noscript>
+	includeNoscript bool
+}
 
 func NewHTMLParser() *HTMLParser {
 	return &HTMLParser{}
 }
 
-// ExtractLinks извлекает все ссылки из HTML
+// SetIncludeNoscript задаёт, извлекаются ли ссылки и ассеты из содержимого
+// <noscript>. По умолчанию нет: браузер с JavaScript это содержимое не
+// показывает. Вызывается до начала разбора страниц.
+func (p *HTMLParser) SetIncludeNoscript(include bool) {
+	p.includeNoscript = include
+}
+
+// parse разбирает документ для ExtractLinks и ExtractAssets. С включённым
+// скриптингом (как в браузере) содержимое <noscript> остаётся текстом,
+// поэтому для includeNoscript скриптинг выключается и оно разбирается как
+// разметка. Комментарии всегда остаются узлами-комментариями.
+func (p *HTMLParser) parse(htmlContent string) (*html.Node, error) {
+	return html.ParseWithOptions(strings.NewReader(htmlContent), html.ParseOptionEnableScripting(!p.includeNoscript))
+}
+
+// prune сообщает, что поддерево узла не содержит ссылок и ассетов страницы:
+// комментарии и (без includeNoscript) <noscript>
+func (p *HTMLParser) prune(n *html.Node) bool {
+	switch n.Type {
+	case html.CommentNode:
+		return true
+	case html.ElementNode:
+		return n.Data == "noscript" && !p.includeNoscript
+	}
+	return false
+}
+
+// ExtractLinks извлекает навигационные ссылки из HTML: <a href>, <area href>
+// (карты изображений), <link rel="next"/"prev"> (пагинация) и <iframe src>
+// на том же домене, что и страница. <link> с другими rel (stylesheet, icon и
+// т.п.) ссылками не считаются.
 func (p *HTMLParser) ExtractLinks(htmlContent string, pageURL *url.URL) []string {
 	links := []string{}
-	doc, err := html.Parse(strings.NewReader(htmlContent))
+	doc, err := p.parse(htmlContent)
 	if err != nil {
 		return links
 	}
 
 	var extract func(*html.Node)
 	extract = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			for _, attr := range n.Attr {
-				if attr.Key == "href" {
-					if link := urlutil.ResolveURL(attr.Val, pageURL); link != "" {
-						links = append(links, link)
-					}
-					break
-				}
+		if p.prune(n) {
+			return
+		}
+		if n.Type == html.ElementNode {
+			if link := linkTarget(n, pageURL); link != "" {
+				links = append(links, link)
 			}
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -50,36 +82,209 @@ func (p *HTMLParser) ExtractLinks(htmlContent string, pageURL *url.URL) []string
 	return links
 }
 
+// linkTarget возвращает абсолютный URL навигационной ссылки элемента
+// или пустую строку, если элемент ссылкой не является
+func linkTarget(n *html.Node, pageURL *url.URL) string {
+	switch n.Data {
+	case "a", "area":
+		return urlutil.ResolveURL(getAttr(n, "href"), pageURL)
+	case "link":
+		if !isPaginationRel(getAttr(n, "rel")) {
+			return ""
+		}
+		return urlutil.ResolveURL(getAttr(n, "href"), pageURL)
+	case "iframe":
+		link := urlutil.ResolveURL(getAttr(n, "src"), pageURL)
+		if link == "" || pageURL == nil {
+			return link
+		}
+		// Встраивания с чужих доменов (видео, виджеты) не относятся к сайту
+		if u, err := url.Parse(link); err != nil || !urlutil.IsSameDomain(u, pageURL) {
+			return ""
+		}
+		return link
+	}
+	return ""
+}
+
+// isPaginationRel: rel содержит next или prev (значение — список через пробел)
+func isPaginationRel(rel string) bool {
+	for _, token := range strings.Fields(rel) {
+		if strings.EqualFold(token, "next") || strings.EqualFold(token, "prev") {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractCanonical возвращает абсолютный URL из <link rel="canonical"> или пустую строку
+func (p *HTMLParser) ExtractCanonical(htmlContent string, pageURL *url.URL) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+
+	canonical := ""
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if canonical != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" && strings.EqualFold(getAttr(n, "rel"), "canonical") {
+			canonical = urlutil.ResolveURL(getAttr(n, "href"), pageURL)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+
+	find(doc)
+	return canonical
+}
+
+// ExtractMetaRefresh возвращает абсолютный URL перехода из первого
+// <meta http-equiv="refresh"> страницы или пустую строку, если его нет
+// или content не содержит URL (обновление той же страницы)
+func (p *HTMLParser) ExtractMetaRefresh(htmlContent string, pageURL *url.URL) string {
+	doc, err := p.parse(htmlContent)
+	if err != nil {
+		return ""
+	}
+
+	target := ""
+	found := false
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if found || p.prune(n) {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "meta" && strings.EqualFold(strings.TrimSpace(getAttr(n, "http-equiv")), "refresh") {
+			found = true
+			if raw := parseRefreshURL(getAttr(n, "content")); raw != "" {
+				target = urlutil.ResolveURL(raw, pageURL)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+
+	find(doc)
+	return target
+}
+
+// parseRefreshURL извлекает URL из content мета-обновления: "5; url=/next",
+// "0;URL='/next'", "0, /next". Задержка, разделитель, префикс "url="
+// (в любом регистре) и кавычки вокруг URL необязательны.
+func parseRefreshURL(content string) string {
+	const space = " \t\n\r\f"
+
+	rest := strings.TrimLeft(content, space)
+	rest = strings.TrimLeft(rest, "0123456789.")
+	rest = strings.TrimLeft(rest, space)
+	rest = strings.TrimPrefix(rest, ";")
+	rest = strings.TrimPrefix(rest, ",")
+	rest = strings.TrimLeft(rest, space)
+
+	if len(rest) >= 3 && strings.EqualFold(rest[:3], "url") {
+		if after := strings.TrimLeft(rest[3:], space); strings.HasPrefix(after, "=") {
+			rest = strings.TrimLeft(after[1:], space)
+		}
+	}
+
+	if len(rest) > 0 && (rest[0] == '\'' || rest[0] == '"') {
+		quote := rest[0]
+		rest = rest[1:]
+		if end := strings.IndexByte(rest, quote); end >= 0 {
+			rest = rest[:end]
+		}
+	}
+	return strings.TrimSpace(rest)
+}
+
+// ExtractDuplicateIDs возвращает отсортированные значения атрибута id,
+// встречающиеся на странице больше одного раза (каждое — один раз)
+func (p *HTMLParser) ExtractDuplicateIDs(htmlContent string) []string {
+	duplicates := []string{}
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return duplicates
+	}
+
+	counts := make(map[string]int)
+	var count func(*html.Node)
+	count = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id := strings.TrimSpace(getAttr(n, "id")); id != "" {
+				counts[id]++
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			count(c)
+		}
+	}
+
+	count(doc)
+	for id, n := range counts {
+		if n > 1 {
+			duplicates = append(duplicates, id)
+		}
+	}
+	sort.Strings(duplicates)
+	return duplicates
+}
+
 func (p *HTMLParser) ExtractAssets(htmlContent string, pageURL *url.URL) []AssetInfo {
 	assets := []AssetInfo{}
-	doc, err := html.Parse(strings.NewReader(htmlContent))
+	doc, err := p.parse(htmlContent)
 	if err != nil {
 		return assets
 	}
 
+	add := func(rawURL, assetType string) {
+		if rawURL == "" {
+			return
+		}
+		if resolved := urlutil.ResolveURL(rawURL, pageURL); resolved != "" {
+			assets = append(assets, AssetInfo{URL: resolved, AssetType: assetType})
+		}
+	}
+
 	var extract func(*html.Node)
 	extract = func(n *html.Node) {
+		if p.prune(n) {
+			return
+		}
 		if n.Type == html.ElementNode {
 			switch n.Data {
 			case "img":
-				if src := getAttr(n, "src"); src != "" {
-					if resolved := urlutil.ResolveURL(src, pageURL); resolved != "" {
-						assets = append(assets, AssetInfo{URL: resolved, AssetType: "image"})
-					}
+				add(getAttr(n, "src"), "image")
+				for _, candidate := range parseSrcset(getAttr(n, "srcset"), pageURL) {
+					add(candidate, "image")
 				}
 			case "script":
-				if src := getAttr(n, "src"); src != "" {
-					if resolved := urlutil.ResolveURL(src, pageURL); resolved != "" {
-						assets = append(assets, AssetInfo{URL: resolved, AssetType: "script"})
-					}
-				}
+				add(getAttr(n, "src"), "script")
 			case "link":
-				if rel := getAttr(n, "rel"); rel == "stylesheet" {
-					if href := getAttr(n, "href"); href != "" {
-						if resolved := urlutil.ResolveURL(href, pageURL); resolved != "" {
-							assets = append(assets, AssetInfo{URL: resolved, AssetType: "style"})
-						}
-					}
+				switch rel := getAttr(n, "rel"); {
+				case rel == "stylesheet":
+					add(getAttr(n, "href"), "style")
+				case rel == "preload" && getAttr(n, "as") == "font":
+					add(getAttr(n, "href"), "font")
+				}
+			case "video", "audio":
+				add(getAttr(n, "src"), n.Data)
+				// Постер видео — это изображение
+				add(getAttr(n, "poster"), "image")
+			case "source":
+				// <source src> внутри <video>/<audio> получает тип родителя
+				if n.Parent != nil && (n.Parent.Data == "video" || n.Parent.Data == "audio") {
+					add(getAttr(n, "src"), n.Parent.Data)
+				}
+				// <source srcset> внутри <picture> — варианты изображения
+				for _, candidate := range parseSrcset(getAttr(n, "srcset"), pageURL) {
+					add(candidate, "image")
 				}
 			}
 		}
@@ -93,6 +298,26 @@ func (p *HTMLParser) ExtractAssets(htmlContent string, pageURL *url.URL) []Asset
 	return assets
 }
 
+// parseSrcset разбирает srcset="a.jpg 1x, b.jpg 480w" и возвращает
+// абсолютные URL кандидатов без дескрипторов, без повторов
+func parseSrcset(srcset string, pageURL *url.URL) []string {
+	candidates := []string{}
+	seen := make(map[string]bool)
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		resolved := urlutil.ResolveURL(fields[0], pageURL)
+		if resolved == "" || seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+		candidates = append(candidates, resolved)
+	}
+	return candidates
+}
+
 func getAttr(n *html.Node, key string) string {
 	for _, attr := range n.Attr {
 		if attr.Key == key {
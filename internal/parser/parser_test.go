@@ -2,6 +2,7 @@ package parser
 
 import (
 	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -43,3 +44,221 @@ func TestExtractLinks(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractCanonical(t *testing.T) {
+	parser := NewHTMLParser()
+	base, _ := url.Parse("https://example.com/page?ref=1")
+
+	html := `<html><head><link rel="Canonical" href="/page#top"></head></html>`
+	if got := parser.ExtractCanonical(html, base); got != "https://example.com/page" {
+		t.Fatalf("expected resolved canonical, got %s", got)
+	}
+
+	if got := parser.ExtractCanonical(`<html><head></head></html>`, base); got != "" {
+		t.Fatalf("expected empty canonical, got %s", got)
+	}
+}
+
+func TestExtractMetaRefresh(t *testing.T) {
+	parser := NewHTMLParser()
+	base, _ := url.Parse("https://example.com/dir/page")
+
+	cases := map[string]string{
+		`<meta http-equiv="refresh" content="0; url=/next">`:            "https://example.com/next",
+		`<meta http-equiv="Refresh" content="5;URL='next'">`:            "https://example.com/dir/next",
+		`<meta http-equiv="REFRESH" content='0, url = "/quoted"'>`:      "https://example.com/quoted",
+		`<meta http-equiv="refresh" content="3; https://other.test/a">`: "https://other.test/a",
+		`<meta http-equiv="refresh" content="0;url=/a?b=1#top">`:        "https://example.com/a?b=1",
+		`<meta http-equiv="refresh" content="30">`:                      "",
+		`<meta name="refresh" content="0; url=/ignored">`:               "",
+	}
+	for meta, expected := range cases {
+		html := `<html><head>` + meta + `</head><body></body></html>`
+		if got := parser.ExtractMetaRefresh(html, base); got != expected {
+			t.Errorf("%s: expected %q, got %q", meta, expected, got)
+		}
+	}
+
+	html := `<html><head><noscript><meta http-equiv="refresh" content="0; url=/nojs"></noscript></head></html>`
+	if got := parser.ExtractMetaRefresh(html, base); got != "" {
+		t.Errorf("expected refresh inside noscript to be skipped, got %q", got)
+	}
+}
+
+func TestExtractAssetsMediaAndFonts(t *testing.T) {
+	html := `
+        <html>
+        <head>
+            <link rel="preload" as="font" href="/fonts/main.woff2" crossorigin>
+            <link rel="preload" as="script" href="/preloaded.js">
+            <link rel="icon" href="/favicon.ico">
+            <link rel="stylesheet" href="/style.css">
+        </head>
+        <body>
+            <video poster="/poster.jpg">
+                <source src="/movie.webm" type="video/webm">
+                <source src="/movie.mp4" type="video/mp4">
+            </video>
+            <audio src="/intro.mp3">
+                <source src="/song.ogg" type="audio/ogg">
+            </audio>
+        </body>
+        </html>
+    `
+
+	parser := NewHTMLParser()
+	base, _ := url.Parse("https://example.com/")
+
+	counts := map[string]int{}
+	for _, asset := range parser.ExtractAssets(html, base) {
+		counts[asset.AssetType]++
+	}
+
+	expected := map[string]int{"font": 1, "style": 1, "video": 2, "audio": 2, "image": 1}
+	for assetType, count := range expected {
+		if counts[assetType] != count {
+			t.Errorf("expected %d %s assets, got %d", count, assetType, counts[assetType])
+		}
+	}
+	if len(counts) != len(expected) {
+		t.Errorf("unexpected asset types: %v", counts)
+	}
+}
+
+func TestExtractAssetsSrcset(t *testing.T) {
+	html := `
+        <html>
+        <body>
+            <img srcset="/small.jpg 480w, /medium.jpg 800w,/large.jpg 1200w">
+            <img src="/logo.png" srcset="/logo.png 1x, /logo@2x.png 2x, /logo@2x.png 3x">
+            <picture>
+                <source srcset="/hero.webp 1x, /hero@2x.webp 2x" type="image/webp">
+            </picture>
+        </body>
+        </html>
+    `
+
+	parser := NewHTMLParser()
+	base, _ := url.Parse("https://example.com/page/")
+
+	got := []string{}
+	for _, asset := range parser.ExtractAssets(html, base) {
+		if asset.AssetType != "image" {
+			t.Errorf("expected image asset, got %s for %s", asset.AssetType, asset.URL)
+		}
+		got = append(got, asset.URL)
+	}
+
+	expected := []string{
+		"https://example.com/small.jpg",
+		"https://example.com/medium.jpg",
+		"https://example.com/large.jpg",
+		"https://example.com/logo.png",
+		"https://example.com/logo.png",
+		"https://example.com/logo@2x.png",
+		"https://example.com/hero.webp",
+		"https://example.com/hero@2x.webp",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d assets, got %d: %v", len(expected), len(got), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("asset %d: expected %s, got %s", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestExtractDuplicateIDs(t *testing.T) {
+	html := `
+        <html>
+        <body>
+            <div id="main"><h2 id="intro">Intro</h2></div>
+            <section id="intro">Again</section>
+            <p id="intro">And again</p>
+            <span id="unique"></span>
+        </body>
+        </html>
+    `
+
+	duplicates := NewHTMLParser().ExtractDuplicateIDs(html)
+	if len(duplicates) != 1 || duplicates[0] != "intro" {
+		t.Errorf("expected [intro], got %v", duplicates)
+	}
+}
+
+func TestExtractLinksNavigationElements(t *testing.T) {
+	html := `
+        <html>
+        <head>
+            <link rel="stylesheet" href="/style.css">
+            <link rel="icon" href="/favicon.ico">
+            <link rel="canonical" href="/list">
+            <link rel="next" href="/list?page=3">
+            <link rel="PREV" href="/list?page=1">
+        </head>
+        <body>
+            <img src="/map.png" usemap="#nav">
+            <map name="nav">
+                <area shape="rect" coords="0,0,10,10" href="/region">
+                <area shape="rect" coords="10,10,20,20" href="#top">
+            </map>
+            <iframe src="/embed/widget"></iframe>
+            <iframe src="https://www.youtube.com/embed/xyz"></iframe>
+        </body>
+        </html>
+    `
+
+	base, _ := url.Parse("https://example.com/list?page=2")
+	links := NewHTMLParser().ExtractLinks(html, base)
+
+	got := strings.Join(links, ",")
+	want := strings.Join([]string{
+		"https://example.com/list?page=3",
+		"https://example.com/list?page=1",
+		"https://example.com/region",
+		"https://example.com/embed/widget",
+	}, ",")
+	if got != want {
+		t.Fatalf("ExtractLinks() = %s, want %s", got, want)
+	}
+}
+
+func TestExtractSkipsCommentsAndNoscript(t *testing.T) {
+	html := `<html><head>
+        <noscript><link rel="stylesheet" href="/noscript.css"></noscript>
+        </head><body>
+            <a href="/visible">Visible</a>
+            <!-- <a href="/commented">Old link</a> <img src="/commented.png"> -->
+            <!--[if IE]><a href="/ie-only">IE</a><![endif]-->
+            <noscript><a href="/noscript">Enable JS</a><img src="/pixel.gif"></noscript>
+            <img src="/logo.png">
+        </body></html>`
+	base, _ := url.Parse("https://example.com/")
+
+	parser := NewHTMLParser()
+	if links := strings.Join(parser.ExtractLinks(html, base), ","); links != "https://example.com/visible" {
+		t.Errorf("expected only the visible link, got %s", links)
+	}
+	var assets []string
+	for _, asset := range parser.ExtractAssets(html, base) {
+		assets = append(assets, asset.URL)
+	}
+	if got := strings.Join(assets, ","); got != "https://example.com/logo.png" {
+		t.Errorf("expected only the visible image, got %s", got)
+	}
+
+	// С IncludeNoscript содержимое <noscript> разбирается, комментарии — нет
+	parser.SetIncludeNoscript(true)
+	if links := strings.Join(parser.ExtractLinks(html, base), ","); links != "https://example.com/visible,https://example.com/noscript" {
+		t.Errorf("expected noscript link to be included, got %s", links)
+	}
+	assets = nil
+	for _, asset := range parser.ExtractAssets(html, base) {
+		assets = append(assets, asset.URL)
+	}
+	expected := "https://example.com/noscript.css,https://example.com/pixel.gif,https://example.com/logo.png"
+	if got := strings.Join(assets, ","); got != expected {
+		t.Errorf("expected noscript assets to be included, got %s", got)
+	}
+}
@@ -2,12 +2,18 @@ package report
 
 import (
 	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
 	"net/url"
 	"sort"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"code/internal/checker"
+	"code/internal/httputil"
 	"code/internal/seo"
 )
 
@@ -22,35 +28,204 @@ type Page struct {
 	DiscoveredAt string               `json:"discovered_at"`
 	SEO          *seo.SEO             `json:"seo"`
 	Assets       []checker.Asset      `json:"assets"`
+	// AssetsTruncated — ассетов больше MaxAssetsPerPage, проверены первые
+	AssetsTruncated bool     `json:"assets_truncated,omitempty"`
+	RedirectChain   []string `json:"redirect_chain,omitempty"`
+	Canonical       string   `json:"canonical,omitempty"`
+	NonCanonical    bool     `json:"non_canonical,omitempty"`
+	Server          string   `json:"server,omitempty"`
+	PoweredBy       string   `json:"powered_by,omitempty"`
+	Unstable        bool     `json:"unstable,omitempty"`
+	ExternalDomains []string `json:"external_domains,omitempty"`
+	RedirectTarget  string   `json:"redirect_target,omitempty"`
+	DuplicateIDs    []string `json:"duplicate_ids,omitempty"`
+	ParseTimeMs     int64    `json:"parse_time_ms"`
+	SessionIDInURL  bool     `json:"session_id_in_url,omitempty"`
+	// ContentType — заголовок Content-Type ответа
+	ContentType string `json:"content_type,omitempty"`
+	// Charset — объявленная кодировка страницы; тело перед разбором
+	// перекодируется из неё в UTF-8
+	Charset string `json:"charset,omitempty"`
+	// Truncated — тело страницы длиннее MaxBodyBytes, разобрано только начало
+	Truncated bool `json:"truncated,omitempty"`
+	// ArchivePath — файл с HTML страницы относительно Options.ArchiveDir;
+	// при Truncated в нём только начало тела
+	ArchivePath string `json:"archive_path,omitempty"`
+	// Warnings — некритичные проблемы обработки страницы (например, ошибка
+	// записи в архив), не влияющие на её статус
+	Warnings []string `json:"warnings,omitempty"`
+	// CanonicalOf — первая страница с тем же содержимым (для статуса duplicate)
+	CanonicalOf string `json:"canonical_of,omitempty"`
+	// HeadOnly — страница проверена только HEAD-запросом (HeadFirst): тело
+	// не HTML или больше MaxBodyBytes и не загружалось
+	HeadOnly bool `json:"head_only,omitempty"`
+	// ETag и LastModified — валидаторы ответа (заголовки ETag и Last-Modified)
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Headers — заголовки ответа из Options.CaptureHeaders, которые в нём есть
+	Headers map[string]string `json:"headers,omitempty"`
+	// Links — все ссылки страницы без повторов (только с IncludeAllLinks);
+	// InternalLinkCount и ExternalLinkCount — сколько из них ведут на обходимый
+	// сайт и за его пределы
+	Links             []string `json:"links,omitempty"`
+	InternalLinkCount int      `json:"internal_link_count,omitempty"`
+	ExternalLinkCount int      `json:"external_link_count,omitempty"`
+	// ErrorKind — категория сетевой ошибки: dns, connection, tls, timeout, other
+	ErrorKind string `json:"error_kind,omitempty"`
+	// Attempts — число попыток загрузки страницы; больше 1 — понадобились повторы
+	Attempts int `json:"attempts,omitempty"`
+	// MetaRefresh — URL перехода из <meta http-equiv="refresh"> (только с FollowMetaRefresh)
+	MetaRefresh string `json:"meta_refresh,omitempty"`
+	// SEOIssues — нарушенные SEO-правила OK-страницы (см. константы SEOIssue*);
+	// вычисляется в AddPage
+	SEOIssues []string `json:"seo_issues"`
 }
 
+// SEO-проблемы страницы в Page.SEOIssues
+const (
+	SEOIssueMissingTitle       = "missing_title"
+	SEOIssueMissingDescription = "missing_meta_description"
+	SEOIssueMissingH1          = "missing_h1"
+	SEOIssueMultipleH1         = "multiple_h1"
+	SEOIssueTitleTooLong       = "title_too_long"
+)
+
+// MaxTitleLength — длина title в символах, после которой он считается слишком длинным
+const MaxTitleLength = 60
+
+// Summary содержит сводные показатели обхода
+type Summary struct {
+	TotalPages       int     `json:"total_pages"`
+	OKPages          int     `json:"ok_pages"`
+	ErrorPages       int     `json:"error_pages"`
+	TotalBrokenLinks int     `json:"total_broken_links"`
+	TotalAssets      int     `json:"total_assets"`
+	BrokenAssets     int     `json:"broken_assets"`
+	TotalRequests    int64   `json:"total_requests"`
+	EffectiveRPS     float64 `json:"effective_rps"`
+	// TotalRedirects — число редиректов (3xx, кроме 304) на все запросы (страницы, ссылки, ассеты)
+	TotalRedirects int64 `json:"total_redirects"`
+	// ParseTimeMs — суммарное время разбора HTML всех страниц
+	ParseTimeMs int64 `json:"parse_time_ms"`
+	// SEOCompleteRatio — доля OK-страниц с title, description и H1 (0, если OK-страниц нет)
+	SEOCompleteRatio float64 `json:"seo_complete_ratio"`
+	// TechStack — число страниц для каждого значения Server / X-Powered-By
+	TechStack map[string]int `json:"tech_stack,omitempty"`
+	// PagesByDepth — число страниц на каждой глубине (в JSON ключи — строки "0", "1"...)
+	PagesByDepth map[int]int `json:"pages_by_depth"`
+}
+
+// SchemaVersion — версия формата отчёта. Увеличивается при изменении
+// структуры JSON, чтобы потребители могли проверить совместимость.
+const SchemaVersion = "3.0"
+
 // Report содержит результат обхода сайта
 type Report struct {
-	RootURL     string `json:"root_url"`
-	Depth       int    `json:"depth"`
+	SchemaVersion string `json:"schema_version"`
+	RootURL       string `json:"root_url"`
+	Depth         int    `json:"depth"`
+	// GeneratedAt — время сериализации отчёта (обновляется при каждом Encode)
 	GeneratedAt string `json:"generated_at"`
-	Pages       []Page `json:"pages"`
+	// StartedAt — начало обхода, CompletedAt — его завершение (первая
+	// сериализация отчёта), DurationMs — время между ними
+	StartedAt   string `json:"started_at"`
+	CompletedAt string `json:"completed_at"`
+	DurationMs  int64  `json:"duration_ms"`
+	// StopReason — почему обход завершён досрочно (пусто, если обход полный)
+	StopReason string `json:"stop_reason,omitempty"`
+	// TimedOut — обход остановлен по истечении общего таймаута обхода
+	// (в отличие от отмены вызывающим кодом)
+	TimedOut bool    `json:"timed_out,omitempty"`
+	Summary  Summary `json:"summary"`
+	// Stats — счётчики HTTP-клиента за весь обход
+	Stats *httputil.StatsSnapshot `json:"stats,omitempty"`
+	Pages []Page                  `json:"pages"`
+	// SkippedURLs — ссылки, не поставленные в очередь защитой от ловушек
+	// обхода (по URL, без повторов)
+	SkippedURLs []SkippedURL `json:"skipped_urls,omitempty"`
+	// ExternalLinks — результаты проверки внешних ссылок всего обхода
+	// (по одной на URL, рабочие и битые); только с ValidateExternalLinks
+	ExternalLinks []checker.CheckedLink `json:"external_links,omitempty"`
+	// Hosts — сведения о TLS-сертификатах HTTPS-хостов обойдённых страниц
+	// (ключ — хост страницы, с портом, если он указан в URL)
+	Hosts map[string]HostInfo `json:"hosts,omitempty"`
+}
+
+// HostInfo — сертификат хоста на момент первого обращения к нему
+type HostInfo struct {
+	// CertNotAfter — окончание срока действия сертификата (RFC3339)
+	CertNotAfter string `json:"cert_not_after"`
+	CertIssuer   string `json:"cert_issuer"`
+	// CertValid — цепочка сертификатов проверяется для имени хоста
+	CertValid bool `json:"cert_valid"`
+}
+
+// SkippedURL — ссылка, которую обход пропустил, и причина пропуска
+type SkippedURL struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// Причины пропуска в SkippedURL.Reason
+const (
+	SkipReasonURLTooLong         = "url_too_long"
+	SkipReasonTooManyQueryParams = "too_many_query_params"
+	SkipReasonQueueFull          = "queue_full"
+)
+
+// PageSink получает страницы отчёта по мере обхода, например для записи
+// в базу данных вместо хранения в памяти. AddPage вызывается
+// последовательно, не из нескольких горутин одновременно.
+type PageSink interface {
+	AddPage(page Page) error
 }
 
 // Builder собирает отчёт о обходе сайта (потокобезопасно)
 type Builder struct {
-	report *Report
-	mu     sync.Mutex
+	report  *Report
+	skipped map[string]bool
+	// sink, streamed, sinkErr — приёмник страниц (см. SetSink), показатели
+	// переданных ему страниц и первая ошибка приёмника
+	sink     PageSink
+	streamed pageTotals
+	sinkErr  error
+	// startedAt, completedAt — границы обхода (см. SetStartedAt и stamp)
+	startedAt   time.Time
+	completedAt time.Time
+	mu          sync.Mutex
 }
 
+// NewBuilder создаёт построитель отчёта. Началом обхода считается момент
+// создания, пока не вызван SetStartedAt.
 func NewBuilder(rootURL *url.URL, depth int) *Builder {
-	return &Builder{
+	rb := &Builder{
 		report: &Report{
-			RootURL:     rootURL.String(),
-			Depth:       depth,
-			GeneratedAt: time.Now().UTC().Format(time.RFC3339),
-			Pages:       []Page{},
+			SchemaVersion: SchemaVersion,
+			RootURL:       rootURL.String(),
+			Depth:         depth,
+			Pages:         []Page{},
 		},
 	}
+	rb.SetStartedAt(time.Now())
+	return rb
+}
+
+// SetStartedAt записывает время начала обхода
+func (rb *Builder) SetStartedAt(t time.Time) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.startedAt = t
+	rb.report.StartedAt = t.UTC().Format(time.RFC3339)
 }
 
 // AddPage добавляет страницу в отчет
 func (rb *Builder) AddPage(page Page) {
+	if page.SEO != nil && page.SEO.OpenGraph == nil {
+		page.SEO.OpenGraph = map[string]string{}
+	}
+
+	page.SEOIssues = seoIssues(page)
+
 	if page.Error != "" {
 		page.BrokenLinks = nil
 		page.Assets = nil
@@ -65,16 +240,131 @@ func (rb *Builder) AddPage(page Page) {
 
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
-	rb.report.Pages = append(rb.report.Pages, page)
+
+	if rb.sink == nil {
+		rb.report.Pages = append(rb.report.Pages, page)
+		return
+	}
+	// После первой ошибки приёмника страницы отбрасываются: отчёт
+	// всё равно неполон, а ошибку вернёт SinkErr
+	if rb.sinkErr != nil {
+		return
+	}
+	if err := rb.sink.AddPage(page); err != nil {
+		rb.sinkErr = err
+		return
+	}
+	rb.streamed.add(page)
+}
+
+// SetSink направляет страницы в sink вместо списка в памяти. Страницы
+// передаются в AddPage уже подготовленными (со статусом и SEO-проблемами),
+// а в отчёте остаются только сводка по ним и служебные поля: Pages пуст,
+// UpdatePages переданные страницы не меняет. Вызывается до первого AddPage.
+func (rb *Builder) SetSink(sink PageSink) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.sink = sink
+}
+
+// SinkErr возвращает первую ошибку PageSink (nil, если ошибок не было)
+func (rb *Builder) SinkErr() error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.sinkErr
+}
+
+// UpdatePages вызывает update для каждой уже добавленной страницы
+// (страницы, переданные в PageSink, не затрагиваются)
+func (rb *Builder) UpdatePages(update func(page *Page)) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	for i := range rb.report.Pages {
+		update(&rb.report.Pages[i])
+	}
+}
+
+// SetStopReason записывает причину досрочной остановки обхода
+func (rb *Builder) SetStopReason(reason string) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.report.StopReason = reason
+}
+
+// SetTimedOut отмечает, что обход остановлен по общему таймауту
+func (rb *Builder) SetTimedOut() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.report.TimedOut = true
+}
+
+// AddSkippedURL записывает пропущенную ссылку; повторы того же URL
+// не записываются
+func (rb *Builder) AddSkippedURL(urlStr, reason string) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.skipped == nil {
+		rb.skipped = make(map[string]bool)
+	}
+	if rb.skipped[urlStr] {
+		return
+	}
+	rb.skipped[urlStr] = true
+	rb.report.SkippedURLs = append(rb.report.SkippedURLs, SkippedURL{URL: urlStr, Reason: reason})
+}
+
+// AddExternalLinks записывает результаты проверки внешних ссылок
+func (rb *Builder) AddExternalLinks(links []checker.CheckedLink) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.report.ExternalLinks = append(rb.report.ExternalLinks, links...)
+}
+
+// AddHost записывает сведения о хосте; повторные записи того же хоста
+// не меняют первую
+func (rb *Builder) AddHost(host string, info HostInfo) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.report.Hosts == nil {
+		rb.report.Hosts = make(map[string]HostInfo)
+	}
+	if _, ok := rb.report.Hosts[host]; !ok {
+		rb.report.Hosts[host] = info
+	}
+}
+
+// SetStats записывает счётчики HTTP-клиента
+func (rb *Builder) SetStats(stats httputil.StatsSnapshot) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.report.Stats = &stats
+}
+
+// SetTotalRedirects записывает число ответов 3xx за весь обход
+func (rb *Builder) SetTotalRedirects(n int64) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.report.Summary.TotalRedirects = n
+}
+
+// SetTotalRequests записывает число запросов за обход; фактическая
+// скорость по нему считается при завершении обхода (см. stamp)
+func (rb *Builder) SetTotalRequests(n int64) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.report.Summary.TotalRequests = n
 }
 
 func (rb *Builder) Encode(indent bool) ([]byte, error) {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 
-	sort.SliceStable(rb.report.Pages, func(i, j int) bool {
-		return rb.report.Pages[i].URL < rb.report.Pages[j].URL
-	})
+	rb.sortPages()
+	rb.computeSummary()
+	rb.stamp()
 
 	if indent {
 		return json.MarshalIndent(rb.report, "", "  ")
@@ -82,7 +372,237 @@ func (rb *Builder) Encode(indent bool) ([]byte, error) {
 	return json.Marshal(rb.report)
 }
 
+// Report возвращает собранный отчёт с тем же порядком страниц и сводкой,
+// что и в Encode
+func (rb *Builder) Report() *Report {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.sortPages()
+	rb.computeSummary()
+	rb.stamp()
+	return rb.report
+}
+
+// EncodeTo кодирует отчёт в w через json.Encoder, не собирая JSON целиком
+// в памяти. Содержимое то же, что у Encode, с переводом строки в конце.
+func (rb *Builder) EncodeTo(w io.Writer, indent bool) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.sortPages()
+	rb.computeSummary()
+	rb.stamp()
+
+	encoder := json.NewEncoder(w)
+	if indent {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(rb.report)
+}
+
+// PageChunk — часть отчёта для постраничной выдачи
+type PageChunk struct {
+	SchemaVersion string `json:"schema_version"`
+	TotalPages    int    `json:"total_pages"`
+	Offset        int    `json:"offset"`
+	Limit         int    `json:"limit"`
+	Pages         []Page `json:"pages"`
+}
+
+// EncodePage кодирует не более limit страниц, начиная с offset, вместе
+// с общим числом страниц. Порядок страниц тот же, что и в Encode.
+// limit <= 0 означает "все страницы после offset".
+func (rb *Builder) EncodePage(offset, limit int) ([]byte, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.sortPages()
+
+	total := len(rb.report.Pages)
+	offset = min(max(offset, 0), total)
+	end := total
+	if limit > 0 {
+		end = min(offset+limit, total)
+	}
+
+	return json.Marshal(PageChunk{
+		SchemaVersion: rb.report.SchemaVersion,
+		TotalPages:    total,
+		Offset:        offset,
+		Limit:         limit,
+		Pages:         rb.report.Pages[offset:end],
+	})
+}
+
+// sortPages упорядочивает страницы (пропущенные и внешние ссылки) по URL,
+// чтобы вывод был детерминированным
+func (rb *Builder) sortPages() {
+	sort.SliceStable(rb.report.Pages, func(i, j int) bool {
+		return rb.report.Pages[i].URL < rb.report.Pages[j].URL
+	})
+	sort.Slice(rb.report.SkippedURLs, func(i, j int) bool {
+		return rb.report.SkippedURLs[i].URL < rb.report.SkippedURLs[j].URL
+	})
+	sort.Slice(rb.report.ExternalLinks, func(i, j int) bool {
+		return rb.report.ExternalLinks[i].URL < rb.report.ExternalLinks[j].URL
+	})
+}
+
+// stamp записывает время сериализации отчёта. Первая сериализация
+// считается завершением обхода: CompletedAt, DurationMs и EffectiveRPS после
+// неё не меняются. Скорость считается по длительности в миллисекундах, чтобы
+// effective_rps == total_requests / (duration_ms / 1000) выполнялось точно.
+func (rb *Builder) stamp() {
+	now := time.Now()
+	if rb.completedAt.IsZero() {
+		rb.completedAt = now
+		rb.report.CompletedAt = now.UTC().Format(time.RFC3339)
+		rb.report.DurationMs = now.Sub(rb.startedAt).Milliseconds()
+		if rb.report.DurationMs > 0 {
+			rb.report.Summary.EffectiveRPS = float64(rb.report.Summary.TotalRequests) * 1000 / float64(rb.report.DurationMs)
+		}
+	}
+	rb.report.GeneratedAt = now.UTC().Format(time.RFC3339)
+}
+
+// StatusRedirectLoop — цепочка редиректов вернулась к уже посещённому URL
+const StatusRedirectLoop = "redirect_loop"
+
+// StatusDuplicate — содержимое страницы совпадает с уже обойдённой (CanonicalOf)
+const StatusDuplicate = "duplicate"
+
+// StatusSoft404 — ответ 2xx, тело которого похоже на страницу "не найдено"
+// (совпало с Soft404Patterns краулера)
+const StatusSoft404 = "soft_404"
+
+// StatusNotModified — сервер ответил 304 на условный запрос: страница не изменилась
+const StatusNotModified = "not_modified"
+
+// computeSummary пересчитывает сводку по текущему списку страниц
+// и страницам, уже переданным в PageSink
+func (rb *Builder) computeSummary() {
+	totals := rb.streamed.clone()
+	for _, page := range rb.report.Pages {
+		totals.add(page)
+	}
+	totals.applyTo(&rb.report.Summary)
+}
+
+// pageTotals — постраничные показатели сводки
+type pageTotals struct {
+	summary     Summary
+	seoComplete int
+}
+
+// clone возвращает копию показателей, не разделяющую с ними карты
+func (t *pageTotals) clone() *pageTotals {
+	c := *t
+	c.summary.TechStack = maps.Clone(t.summary.TechStack)
+	c.summary.PagesByDepth = maps.Clone(t.summary.PagesByDepth)
+	if c.summary.PagesByDepth == nil {
+		c.summary.PagesByDepth = make(map[int]int)
+	}
+	return &c
+}
+
+// add учитывает страницу в показателях
+func (t *pageTotals) add(page Page) {
+	summary := &t.summary
+	summary.TotalPages++
+	if summary.PagesByDepth == nil {
+		summary.PagesByDepth = make(map[int]int)
+	}
+	summary.PagesByDepth[page.Depth]++
+
+	switch page.Status {
+	case "ok":
+		summary.OKPages++
+		if page.SEO.Complete() {
+			t.seoComplete++
+		}
+	case "redirect", StatusDuplicate, StatusNotModified:
+	default:
+		summary.ErrorPages++
+	}
+
+	summary.ParseTimeMs += page.ParseTimeMs
+	summary.TotalBrokenLinks += len(page.BrokenLinks)
+	summary.TotalAssets += len(page.Assets)
+	for _, asset := range page.Assets {
+		if isBrokenAsset(asset) {
+			summary.BrokenAssets++
+		}
+	}
+
+	for _, value := range []string{page.Server, page.PoweredBy} {
+		if value == "" {
+			continue
+		}
+		if summary.TechStack == nil {
+			summary.TechStack = make(map[string]int)
+		}
+		summary.TechStack[value]++
+	}
+}
+
+// applyTo записывает постраничные показатели в сводку; остальные поля
+// (число запросов, длительность, редиректы) не меняются
+func (t *pageTotals) applyTo(summary *Summary) {
+	summary.TotalPages = t.summary.TotalPages
+	summary.OKPages = t.summary.OKPages
+	summary.ErrorPages = t.summary.ErrorPages
+	summary.TotalBrokenLinks = t.summary.TotalBrokenLinks
+	summary.TotalAssets = t.summary.TotalAssets
+	summary.BrokenAssets = t.summary.BrokenAssets
+	summary.ParseTimeMs = t.summary.ParseTimeMs
+	summary.TechStack = t.summary.TechStack
+	summary.PagesByDepth = t.summary.PagesByDepth
+
+	summary.SEOCompleteRatio = 0
+	if summary.OKPages > 0 {
+		summary.SEOCompleteRatio = float64(t.seoComplete) / float64(summary.OKPages)
+	}
+}
+
+// seoIssues проверяет SEO-правила для OK-страницы с разобранным HTML.
+// Для остальных страниц возвращает пустой список.
+func seoIssues(page Page) []string {
+	issues := []string{}
+	if page.Status != "ok" || page.HeadOnly || page.SEO == nil {
+		return issues
+	}
+
+	s := page.SEO
+	if !s.HasTitle || strings.TrimSpace(s.Title) == "" {
+		issues = append(issues, SEOIssueMissingTitle)
+	} else if utf8.RuneCountInString(strings.TrimSpace(s.Title)) > MaxTitleLength {
+		issues = append(issues, SEOIssueTitleTooLong)
+	}
+	if !s.HasDescription || strings.TrimSpace(s.Description) == "" {
+		issues = append(issues, SEOIssueMissingDescription)
+	}
+	switch {
+	case !s.HasH1:
+		issues = append(issues, SEOIssueMissingH1)
+	case s.H1Count > 1:
+		issues = append(issues, SEOIssueMultipleH1)
+	}
+
+	return issues
+}
+
+// isBrokenAsset: ассет не загрузился или вернул статус 4xx/5xx
+func isBrokenAsset(asset checker.Asset) bool {
+	return asset.Error != "" || asset.StatusCode == 0 || asset.StatusCode >= 400
+}
+
 func SetPageStatus(page *Page) {
+	if page.HTTPStatus == http.StatusNotModified {
+		page.Status = StatusNotModified
+		return
+	}
+
 	if page.HTTPStatus == 0 {
 		page.Status = "error"
 		if page.Error == "" {
@@ -0,0 +1,551 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"code/internal/checker"
+	"code/internal/seo"
+)
+
+func newTestBuilder() *Builder {
+	root, _ := url.Parse("https://example.com")
+	return NewBuilder(root, 1)
+}
+
+func decodeReport(t *testing.T, rb *Builder) Report {
+	t.Helper()
+	data, err := rb.Encode(false)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	var rep Report
+	if err := json.Unmarshal(data, &rep); err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+	return rep
+}
+
+func TestSummaryCounts(t *testing.T) {
+	rb := newTestBuilder()
+	rb.AddPage(Page{URL: "https://example.com/b", Status: "ok", HTTPStatus: 200,
+		BrokenLinks: []checker.BrokenLink{{URL: "https://example.com/x", StatusCode: 404}},
+		Assets: []checker.Asset{
+			{URL: "https://example.com/a.png", StatusCode: 200},
+			{URL: "https://example.com/b.png", StatusCode: 404, Error: "HTTP 404"},
+		}})
+	rb.AddPage(Page{URL: "https://example.com/a", Status: "server_error", HTTPStatus: 500})
+	rb.AddPage(Page{URL: "https://example.com/c", Status: "redirect", HTTPStatus: 301})
+	rb.AddPage(Page{URL: "https://example.com/d", Status: "error", Error: "timeout"})
+
+	summary := decodeReport(t, rb).Summary
+
+	if summary.TotalPages != 4 || summary.OKPages != 1 || summary.ErrorPages != 2 {
+		t.Errorf("unexpected page counts: %+v", summary)
+	}
+	if summary.TotalBrokenLinks != 1 {
+		t.Errorf("expected 1 broken link, got %d", summary.TotalBrokenLinks)
+	}
+	if summary.TotalAssets != 2 || summary.BrokenAssets != 1 {
+		t.Errorf("expected 2 assets with 1 broken, got %d/%d", summary.TotalAssets, summary.BrokenAssets)
+	}
+}
+
+func TestSummaryPagesByDepth(t *testing.T) {
+	rb := newTestBuilder()
+	rb.AddPage(Page{URL: "https://example.com/", Depth: 0, Status: "ok", HTTPStatus: 200})
+	rb.AddPage(Page{URL: "https://example.com/a", Depth: 1, Status: "ok", HTTPStatus: 200})
+	rb.AddPage(Page{URL: "https://example.com/b", Depth: 1, Status: "client_error", HTTPStatus: 404})
+	rb.AddPage(Page{URL: "https://example.com/a/1", Depth: 2, Status: "ok", HTTPStatus: 200})
+
+	data, err := rb.Encode(false)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"pages_by_depth":{"0":1,"1":2,"2":1}`) {
+		t.Errorf("expected pages_by_depth keyed by depth, got %s", data)
+	}
+
+	var rep Report
+	if err := json.Unmarshal(data, &rep); err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+	if fmt.Sprint(rep.Summary.PagesByDepth) != "map[0:1 1:2 2:1]" {
+		t.Errorf("unexpected pages_by_depth: %v", rep.Summary.PagesByDepth)
+	}
+
+	if byDepth := decodeReport(t, newTestBuilder()).Summary.PagesByDepth; byDepth == nil || len(byDepth) != 0 {
+		t.Errorf("expected an empty pages_by_depth without pages, got %v", byDepth)
+	}
+}
+
+func TestSummaryEmptyCrawl(t *testing.T) {
+	data, err := newTestBuilder().Encode(false)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	var raw struct {
+		Summary map[string]interface{} `json:"summary"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+
+	for _, key := range []string{"total_pages", "ok_pages", "error_pages", "total_broken_links", "total_assets", "broken_assets"} {
+		if value, ok := raw.Summary[key]; !ok || value != float64(0) {
+			t.Errorf("expected summary.%s to be 0, got %v", key, value)
+		}
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	data, err := newTestBuilder().Encode(false)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+
+	var version string
+	if err := json.Unmarshal(raw["schema_version"], &version); err != nil {
+		t.Fatalf("schema_version missing or invalid: %v", err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("expected schema_version %q, got %q", SchemaVersion, version)
+	}
+
+	prefix := `{"schema_version":`
+	if string(data[:len(prefix)]) != prefix {
+		t.Errorf("expected schema_version to be the first key, got %s", data)
+	}
+}
+
+// schemaFields — поля JSON-отчёта версии schemaFieldsVersion (вложенные —
+// через точку). Если TestSchemaFields упал, структура отчёта изменилась:
+// обновите список и увеличьте SchemaVersion.
+const schemaFieldsVersion = "3.0"
+
+var schemaFields = []string{
+	"completed_at",
+	"depth",
+	"duration_ms",
+	"external_links",
+	"external_links.broken",
+	"external_links.error",
+	"external_links.found_on",
+	"external_links.status_code",
+	"external_links.url",
+	"generated_at",
+	"hosts",
+	"hosts.cert_issuer",
+	"hosts.cert_not_after",
+	"hosts.cert_valid",
+	"pages",
+	"pages.archive_path",
+	"pages.assets",
+	"pages.assets.compressed_size_bytes",
+	"pages.assets.error",
+	"pages.assets.found_on",
+	"pages.assets.mixed_content",
+	"pages.assets.origin",
+	"pages.assets.size_bytes",
+	"pages.assets.status_code",
+	"pages.assets.status_text",
+	"pages.assets.truncated",
+	"pages.assets.type",
+	"pages.assets.url",
+	"pages.assets_truncated",
+	"pages.attempts",
+	"pages.broken_links",
+	"pages.broken_links.error",
+	"pages.broken_links.found_on",
+	"pages.broken_links.status_code",
+	"pages.broken_links.url",
+	"pages.canonical",
+	"pages.canonical_of",
+	"pages.charset",
+	"pages.content_type",
+	"pages.depth",
+	"pages.discovered_at",
+	"pages.duplicate_ids",
+	"pages.error",
+	"pages.error_kind",
+	"pages.etag",
+	"pages.external_domains",
+	"pages.external_link_count",
+	"pages.head_only",
+	"pages.headers",
+	"pages.http_status",
+	"pages.internal_link_count",
+	"pages.last_modified",
+	"pages.links",
+	"pages.meta_refresh",
+	"pages.non_canonical",
+	"pages.parse_time_ms",
+	"pages.powered_by",
+	"pages.redirect_chain",
+	"pages.redirect_target",
+	"pages.seo",
+	"pages.seo.description",
+	"pages.seo.description_length",
+	"pages.seo.h1_count",
+	"pages.seo.has_description",
+	"pages.seo.has_h1",
+	"pages.seo.has_title",
+	"pages.seo.hreflang",
+	"pages.seo.mixed_content_count",
+	"pages.seo.open_graph",
+	"pages.seo.text_ratio",
+	"pages.seo.title",
+	"pages.seo.title_length",
+	"pages.seo.word_count",
+	"pages.seo_issues",
+	"pages.server",
+	"pages.session_id_in_url",
+	"pages.status",
+	"pages.truncated",
+	"pages.unstable",
+	"pages.url",
+	"pages.warnings",
+	"root_url",
+	"schema_version",
+	"skipped_urls",
+	"skipped_urls.reason",
+	"skipped_urls.url",
+	"started_at",
+	"stats",
+	"stats.bytes_read",
+	"stats.status_classes",
+	"stop_reason",
+	"summary",
+	"summary.broken_assets",
+	"summary.effective_rps",
+	"summary.error_pages",
+	"summary.ok_pages",
+	"summary.pages_by_depth",
+	"summary.parse_time_ms",
+	"summary.seo_complete_ratio",
+	"summary.tech_stack",
+	"summary.total_assets",
+	"summary.total_broken_links",
+	"summary.total_pages",
+	"summary.total_redirects",
+	"summary.total_requests",
+	"timed_out",
+}
+
+func TestSchemaFields(t *testing.T) {
+	var fields []string
+	collectJSONFields(reflect.TypeOf(Report{}), "", &fields, map[reflect.Type]bool{})
+
+	if added, removed := diffFields(schemaFields, fields); len(added) > 0 || len(removed) > 0 {
+		t.Errorf("report fields changed (added %v, removed %v): update schemaFields and bump SchemaVersion", added, removed)
+	}
+	if SchemaVersion != schemaFieldsVersion {
+		t.Errorf("SchemaVersion %q does not match the pinned field list %q: update schemaFields", SchemaVersion, schemaFieldsVersion)
+	}
+}
+
+// collectJSONFields добавляет в out JSON-имена полей typ и вложенных структур
+func collectJSONFields(typ reflect.Type, prefix string, out *[]string, visiting map[reflect.Type]bool) {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || visiting[typ] {
+		return
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			collectJSONFields(field.Type, prefix, out, visiting)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		*out = append(*out, prefix+name)
+		collectJSONFields(field.Type, prefix+name+".", out, visiting)
+	}
+}
+
+func diffFields(pinned, actual []string) (added, removed []string) {
+	for _, field := range actual {
+		if !slices.Contains(pinned, field) {
+			added = append(added, field)
+		}
+	}
+	for _, field := range pinned {
+		if !slices.Contains(actual, field) {
+			removed = append(removed, field)
+		}
+	}
+	return added, removed
+}
+
+func TestCrawlTimes(t *testing.T) {
+	rb := newTestBuilder()
+	started := time.Now().Add(-2 * time.Second)
+	rb.SetStartedAt(started)
+	rb.SetTotalRequests(10)
+
+	rep := decodeReport(t, rb)
+	startedAt, err := time.Parse(time.RFC3339, rep.StartedAt)
+	if err != nil {
+		t.Fatalf("started_at is not RFC3339: %v", err)
+	}
+	completedAt, err := time.Parse(time.RFC3339, rep.CompletedAt)
+	if err != nil {
+		t.Fatalf("completed_at is not RFC3339: %v", err)
+	}
+	if completedAt.Before(startedAt) {
+		t.Errorf("expected completed_at %s >= started_at %s", rep.CompletedAt, rep.StartedAt)
+	}
+	if rep.DurationMs < 2000 {
+		t.Errorf("expected duration_ms of at least 2000, got %d", rep.DurationMs)
+	}
+	// Скорость считается по той же длительности, что и duration_ms
+	if expected := float64(10) * 1000 / float64(rep.DurationMs); rep.Summary.EffectiveRPS != expected {
+		t.Errorf("expected effective_rps %f from duration_ms %d, got %f", expected, rep.DurationMs, rep.Summary.EffectiveRPS)
+	}
+
+	// Повторная сериализация не сдвигает завершение обхода
+	again := decodeReport(t, rb)
+	if again.CompletedAt != rep.CompletedAt || again.DurationMs != rep.DurationMs {
+		t.Errorf("expected completion to be fixed at first Encode, got %s/%d then %s/%d",
+			rep.CompletedAt, rep.DurationMs, again.CompletedAt, again.DurationMs)
+	}
+	if _, err := time.Parse(time.RFC3339, again.GeneratedAt); err != nil {
+		t.Errorf("generated_at is not RFC3339: %v", err)
+	}
+}
+
+func TestEncodePage(t *testing.T) {
+	rb := newTestBuilder()
+	for i := 0; i < 20; i++ {
+		rb.AddPage(Page{URL: fmt.Sprintf("https://example.com/%02d", i), Status: "ok", HTTPStatus: 200})
+	}
+
+	data, err := rb.EncodePage(10, 5)
+	if err != nil {
+		t.Fatalf("EncodePage failed: %v", err)
+	}
+
+	var chunk PageChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		t.Fatalf("failed to unmarshal chunk: %v", err)
+	}
+
+	if chunk.TotalPages != 20 {
+		t.Errorf("expected total 20, got %d", chunk.TotalPages)
+	}
+	if len(chunk.Pages) != 5 {
+		t.Fatalf("expected 5 pages, got %d", len(chunk.Pages))
+	}
+	for i, page := range chunk.Pages {
+		expected := fmt.Sprintf("https://example.com/%02d", 10+i)
+		if page.URL != expected {
+			t.Errorf("page %d: expected %s, got %s", i, expected, page.URL)
+		}
+	}
+
+	data, err = rb.EncodePage(18, 5)
+	if err != nil {
+		t.Fatalf("EncodePage failed: %v", err)
+	}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		t.Fatalf("failed to unmarshal chunk: %v", err)
+	}
+	if len(chunk.Pages) != 2 {
+		t.Errorf("expected 2 pages at the tail, got %d", len(chunk.Pages))
+	}
+}
+
+func TestSummarySEOCompleteRatio(t *testing.T) {
+	complete := &seo.SEO{HasTitle: true, HasDescription: true, HasH1: true}
+	noH1 := &seo.SEO{HasTitle: true, HasDescription: true}
+
+	rb := newTestBuilder()
+	rb.AddPage(Page{URL: "https://example.com/a", Status: "ok", HTTPStatus: 200, SEO: complete})
+	rb.AddPage(Page{URL: "https://example.com/b", Status: "ok", HTTPStatus: 200, SEO: complete})
+	rb.AddPage(Page{URL: "https://example.com/c", Status: "ok", HTTPStatus: 200, SEO: noH1})
+	rb.AddPage(Page{URL: "https://example.com/d", Status: "ok", HTTPStatus: 200, SEO: &seo.SEO{}})
+	// Страницы с ошибками не учитываются
+	rb.AddPage(Page{URL: "https://example.com/e", Status: "client_error", HTTPStatus: 404, SEO: &seo.SEO{}})
+
+	if ratio := decodeReport(t, rb).Summary.SEOCompleteRatio; ratio != 0.5 {
+		t.Errorf("expected seo_complete_ratio 0.5, got %v", ratio)
+	}
+
+	if ratio := decodeReport(t, newTestBuilder()).Summary.SEOCompleteRatio; ratio != 0 {
+		t.Errorf("expected seo_complete_ratio 0 without OK pages, got %v", ratio)
+	}
+}
+
+func TestSEOIssues(t *testing.T) {
+	complete := seo.SEO{
+		HasTitle: true, Title: "Title", HasDescription: true, Description: "Description",
+		HasH1: true, H1Count: 1,
+	}
+
+	tests := []struct {
+		name     string
+		page     Page
+		expected []string
+	}{
+		{
+			name:     "complete",
+			page:     Page{Status: "ok", SEO: &complete},
+			expected: []string{},
+		},
+		{
+			name:     "missing title",
+			page:     Page{Status: "ok", SEO: &seo.SEO{HasDescription: true, Description: "d", HasH1: true, H1Count: 1}},
+			expected: []string{SEOIssueMissingTitle},
+		},
+		{
+			name:     "empty title",
+			page:     Page{Status: "ok", SEO: &seo.SEO{HasTitle: true, Title: "  ", HasDescription: true, Description: "d", HasH1: true, H1Count: 1}},
+			expected: []string{SEOIssueMissingTitle},
+		},
+		{
+			name:     "missing description",
+			page:     Page{Status: "ok", SEO: &seo.SEO{HasTitle: true, Title: "t", HasH1: true, H1Count: 1}},
+			expected: []string{SEOIssueMissingDescription},
+		},
+		{
+			name:     "missing h1",
+			page:     Page{Status: "ok", SEO: &seo.SEO{HasTitle: true, Title: "t", HasDescription: true, Description: "d"}},
+			expected: []string{SEOIssueMissingH1},
+		},
+		{
+			name:     "multiple h1",
+			page:     Page{Status: "ok", SEO: &seo.SEO{HasTitle: true, Title: "t", HasDescription: true, Description: "d", HasH1: true, H1Count: 3}},
+			expected: []string{SEOIssueMultipleH1},
+		},
+		{
+			name:     "title too long",
+			page:     Page{Status: "ok", SEO: &seo.SEO{HasTitle: true, Title: strings.Repeat("a", MaxTitleLength+1), HasDescription: true, Description: "d", HasH1: true, H1Count: 1}},
+			expected: []string{SEOIssueTitleTooLong},
+		},
+		{
+			// Длина считается в символах, а не в байтах
+			name:     "title at limit in cyrillic",
+			page:     Page{Status: "ok", SEO: &seo.SEO{HasTitle: true, Title: strings.Repeat("я", MaxTitleLength), HasDescription: true, Description: "d", HasH1: true, H1Count: 1}},
+			expected: []string{},
+		},
+		{
+			name:     "empty seo",
+			page:     Page{Status: "ok", SEO: &seo.SEO{}},
+			expected: []string{SEOIssueMissingTitle, SEOIssueMissingDescription, SEOIssueMissingH1},
+		},
+		{
+			name:     "error page",
+			page:     Page{Status: "client_error", HTTPStatus: 404, Error: "404 Not Found", SEO: &seo.SEO{}},
+			expected: []string{},
+		},
+		{
+			name:     "head only page",
+			page:     Page{Status: "ok", HeadOnly: true, SEO: &seo.SEO{}},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rb := newTestBuilder()
+			tt.page.URL = "https://example.com/page"
+			rb.AddPage(tt.page)
+
+			rep := decodeReport(t, rb)
+			issues := rep.Pages[0].SEOIssues
+			if issues == nil {
+				t.Fatal("expected seo_issues to be an array, got null")
+			}
+			if strings.Join(issues, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected issues %v, got %v", tt.expected, issues)
+			}
+		})
+	}
+}
+
+// countingSink считает переданные страницы и может вернуть ошибку
+type countingSink struct {
+	pages []Page
+	err   error
+}
+
+func (s *countingSink) AddPage(page Page) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.pages = append(s.pages, page)
+	return nil
+}
+
+func TestPageSink(t *testing.T) {
+	sink := &countingSink{}
+	rb := newTestBuilder()
+	rb.SetSink(sink)
+	rb.AddPage(Page{URL: "https://example.com/", Status: "ok", HTTPStatus: 200, SEO: &seo.SEO{}})
+	rb.AddPage(Page{URL: "https://example.com/a", Depth: 1, Status: "client_error", HTTPStatus: 404})
+	rb.AddPage(Page{URL: "https://example.com/b", Depth: 1, Status: "ok", HTTPStatus: 200,
+		Assets: []checker.Asset{{URL: "https://example.com/x.png", StatusCode: 404}}})
+
+	if len(sink.pages) != 3 {
+		t.Fatalf("expected 3 pages in sink, got %d", len(sink.pages))
+	}
+	// Страницы приходят в приёмник подготовленными, как в отчёте
+	if first := sink.pages[0]; first.SEOIssues == nil || first.BrokenLinks == nil || first.SEO.OpenGraph == nil {
+		t.Errorf("expected prepared page in sink, got %+v", first)
+	}
+
+	rep := decodeReport(t, rb)
+	if len(rep.Pages) != 0 {
+		t.Errorf("expected no pages in memory with a sink, got %d", len(rep.Pages))
+	}
+	summary := rep.Summary
+	if summary.TotalPages != 3 || summary.OKPages != 2 || summary.ErrorPages != 1 {
+		t.Errorf("expected summary over streamed pages, got %+v", summary)
+	}
+	if summary.TotalAssets != 1 || summary.BrokenAssets != 1 || fmt.Sprint(summary.PagesByDepth) != "map[0:1 1:2]" {
+		t.Errorf("unexpected streamed totals: %+v", summary)
+	}
+	if rb.SinkErr() != nil {
+		t.Errorf("unexpected sink error: %v", rb.SinkErr())
+	}
+
+	failing := &countingSink{err: errors.New("disk full")}
+	rb = newTestBuilder()
+	rb.SetSink(failing)
+	rb.AddPage(Page{URL: "https://example.com/", Status: "ok", HTTPStatus: 200})
+	failing.err = nil
+	rb.AddPage(Page{URL: "https://example.com/a", Status: "ok", HTTPStatus: 200})
+
+	if err := rb.SinkErr(); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected first sink error, got %v", err)
+	}
+	if len(failing.pages) != 0 || decodeReport(t, rb).Summary.TotalPages != 0 {
+		t.Errorf("expected pages after a sink error to be dropped, got %d", len(failing.pages))
+	}
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// StatusChange описывает изменение статуса страницы между отчётами
+type StatusChange struct {
+	URL           string `json:"url"`
+	OldStatus     string `json:"old_status"`
+	NewStatus     string `json:"new_status"`
+	OldHTTPStatus int    `json:"old_http_status"`
+	NewHTTPStatus int    `json:"new_http_status"`
+}
+
+// BrokenLinkChange — битая ссылка, появившаяся или исправленная на странице
+type BrokenLinkChange struct {
+	PageURL string `json:"page_url"`
+	URL     string `json:"url"`
+}
+
+// ReportDiff содержит только изменения между предыдущим и текущим отчётом
+type ReportDiff struct {
+	NewPages         []string           `json:"new_pages"`
+	RemovedPages     []string           `json:"removed_pages"`
+	StatusChanges    []StatusChange     `json:"status_changes"`
+	NewBrokenLinks   []BrokenLinkChange `json:"new_broken_links"`
+	FixedBrokenLinks []BrokenLinkChange `json:"fixed_broken_links"`
+}
+
+// IsEmpty сообщает, что отчёты не отличаются
+func (d ReportDiff) IsEmpty() bool {
+	return len(d.NewPages) == 0 && len(d.RemovedPages) == 0 && len(d.StatusChanges) == 0 &&
+		len(d.NewBrokenLinks) == 0 && len(d.FixedBrokenLinks) == 0
+}
+
+// Load читает JSON-отчёт
+func Load(r io.Reader) (*Report, error) {
+	var rep Report
+	if err := json.NewDecoder(r).Decode(&rep); err != nil {
+		return nil, fmt.Errorf("failed to decode report: %w", err)
+	}
+	return &rep, nil
+}
+
+// Diff сравнивает предыдущий отчёт с текущим.
+// Все списки отсортированы по URL, чтобы результат был детерминированным.
+func Diff(prev, curr *Report) ReportDiff {
+	diff := ReportDiff{
+		NewPages:         []string{},
+		RemovedPages:     []string{},
+		StatusChanges:    []StatusChange{},
+		NewBrokenLinks:   []BrokenLinkChange{},
+		FixedBrokenLinks: []BrokenLinkChange{},
+	}
+
+	prevPages := pagesByURL(prev)
+	currPages := pagesByURL(curr)
+
+	for pageURL, page := range currPages {
+		old, existed := prevPages[pageURL]
+		if !existed {
+			diff.NewPages = append(diff.NewPages, pageURL)
+			diff.NewBrokenLinks = append(diff.NewBrokenLinks, brokenLinkChanges(pageURL, page, Page{})...)
+			continue
+		}
+
+		if old.Status != page.Status || old.HTTPStatus != page.HTTPStatus {
+			diff.StatusChanges = append(diff.StatusChanges, StatusChange{
+				URL:           pageURL,
+				OldStatus:     old.Status,
+				NewStatus:     page.Status,
+				OldHTTPStatus: old.HTTPStatus,
+				NewHTTPStatus: page.HTTPStatus,
+			})
+		}
+
+		diff.NewBrokenLinks = append(diff.NewBrokenLinks, brokenLinkChanges(pageURL, page, old)...)
+		diff.FixedBrokenLinks = append(diff.FixedBrokenLinks, brokenLinkChanges(pageURL, old, page)...)
+	}
+
+	for pageURL := range prevPages {
+		if _, exists := currPages[pageURL]; !exists {
+			diff.RemovedPages = append(diff.RemovedPages, pageURL)
+		}
+	}
+
+	sort.Strings(diff.NewPages)
+	sort.Strings(diff.RemovedPages)
+	sort.Slice(diff.StatusChanges, func(i, j int) bool {
+		return diff.StatusChanges[i].URL < diff.StatusChanges[j].URL
+	})
+	sortLinkChanges(diff.NewBrokenLinks)
+	sortLinkChanges(diff.FixedBrokenLinks)
+
+	return diff
+}
+
+func pagesByURL(rep *Report) map[string]Page {
+	pages := make(map[string]Page)
+	if rep == nil {
+		return pages
+	}
+	for _, page := range rep.Pages {
+		pages[page.URL] = page
+	}
+	return pages
+}
+
+// brokenLinkChanges возвращает битые ссылки из page, которых нет в other
+func brokenLinkChanges(pageURL string, page, other Page) []BrokenLinkChange {
+	known := make(map[string]bool, len(other.BrokenLinks))
+	for _, link := range other.BrokenLinks {
+		known[link.URL] = true
+	}
+
+	changes := []BrokenLinkChange{}
+	for _, link := range page.BrokenLinks {
+		if !known[link.URL] {
+			changes = append(changes, BrokenLinkChange{PageURL: pageURL, URL: link.URL})
+		}
+	}
+	return changes
+}
+
+func sortLinkChanges(changes []BrokenLinkChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].PageURL != changes[j].PageURL {
+			return changes[i].PageURL < changes[j].PageURL
+		}
+		return changes[i].URL < changes[j].URL
+	})
+}
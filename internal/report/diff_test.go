@@ -0,0 +1,45 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"code/internal/checker"
+)
+
+func TestDiff(t *testing.T) {
+	prev := &Report{Pages: []Page{
+		{URL: "https://example.com", Status: "ok", HTTPStatus: 200,
+			BrokenLinks: []checker.BrokenLink{{URL: "https://example.com/old-missing", StatusCode: 404}}},
+		{URL: "https://example.com/about", Status: "ok", HTTPStatus: 200},
+		{URL: "https://example.com/removed", Status: "ok", HTTPStatus: 200},
+	}}
+	curr := &Report{Pages: []Page{
+		{URL: "https://example.com", Status: "ok", HTTPStatus: 200,
+			BrokenLinks: []checker.BrokenLink{{URL: "https://example.com/new-missing", StatusCode: 404}}},
+		{URL: "https://example.com/about", Status: "server_error", HTTPStatus: 500},
+		{URL: "https://example.com/new", Status: "ok", HTTPStatus: 200},
+	}}
+
+	diff := Diff(prev, curr)
+
+	if strings.Join(diff.NewPages, ",") != "https://example.com/new" {
+		t.Errorf("unexpected new pages: %v", diff.NewPages)
+	}
+	if strings.Join(diff.RemovedPages, ",") != "https://example.com/removed" {
+		t.Errorf("unexpected removed pages: %v", diff.RemovedPages)
+	}
+	if len(diff.StatusChanges) != 1 || diff.StatusChanges[0].NewHTTPStatus != 500 {
+		t.Errorf("unexpected status changes: %+v", diff.StatusChanges)
+	}
+	if len(diff.NewBrokenLinks) != 1 || diff.NewBrokenLinks[0].URL != "https://example.com/new-missing" {
+		t.Errorf("unexpected new broken links: %+v", diff.NewBrokenLinks)
+	}
+	if len(diff.FixedBrokenLinks) != 1 || diff.FixedBrokenLinks[0].URL != "https://example.com/old-missing" {
+		t.Errorf("unexpected fixed broken links: %+v", diff.FixedBrokenLinks)
+	}
+
+	if !Diff(curr, curr).IsEmpty() {
+		t.Error("expected no changes when comparing a report with itself")
+	}
+}
@@ -0,0 +1,102 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"code/internal/urlutil"
+)
+
+// dotColors — цвет узла графа по статусу страницы
+var dotColors = map[string]string{
+	"ok":               "palegreen",
+	"redirect":         "lightblue",
+	StatusRedirectLoop: "tomato",
+	"client_error":     "orange",
+	StatusSoft404:      "orange",
+	"server_error":     "tomato",
+	"error":            "tomato",
+	StatusDuplicate:    "lightgray",
+	StatusNotModified:  "lightgray",
+}
+
+// RenderDOT отображает отчёт в виде ориентированного графа Graphviz: узлы —
+// страницы (цвет по статусу), рёбра — ссылки между страницами отчёта.
+// Рёбра берутся из Page.Links, поэтому отчёт должен быть собран с
+// IncludeAllLinks. Узлы и рёбра отсортированы, вывод детерминирован.
+func RenderDOT(r *Report) []byte {
+	pages := make([]Page, len(r.Pages))
+	copy(pages, r.Pages)
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].URL < pages[j].URL
+	})
+
+	// Ссылки нормализованы (корень — без "/"), а URL страницы может быть
+	// записан как задан, поэтому узлы сопоставляются по нормализованному URL
+	nodes := make(map[string]string, len(pages))
+	for _, page := range pages {
+		nodes[dotNodeKey(page.URL)] = page.URL
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("digraph crawl {\n")
+	buf.WriteString("  node [shape=box, style=filled, fillcolor=white];\n")
+
+	for _, page := range pages {
+		color, ok := dotColors[page.Status]
+		if !ok {
+			color = "white"
+		}
+		fmt.Fprintf(&buf, "  %s [fillcolor=%s];\n", dotQuote(page.URL), color)
+	}
+
+	for _, page := range pages {
+		targets := []string{}
+		seen := make(map[string]bool, len(page.Links))
+		for _, link := range page.Links {
+			// Рёбра только между узлами: внешние и не обойдённые ссылки не рисуются
+			target, ok := nodes[dotNodeKey(link)]
+			if !ok || target == page.URL || seen[target] {
+				continue
+			}
+			seen[target] = true
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			fmt.Fprintf(&buf, "  %s -> %s;\n", dotQuote(page.URL), dotQuote(target))
+		}
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// EncodeDOT кодирует отчёт в формат DOT (см. RenderDOT)
+func (rb *Builder) EncodeDOT() ([]byte, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.sortPages()
+
+	return RenderDOT(rb.report), nil
+}
+
+// dotNodeKey — нормализованный URL для сопоставления ссылок со страницами
+func dotNodeKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return urlutil.NormalizeURL(u)
+}
+
+// dotQuote заключает строку в кавычки DOT, экранируя \, " и переводы строк
+func dotQuote(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + replacer.Replace(s) + `"`
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeDOT(t *testing.T) {
+	rb := newTestBuilder()
+	rb.AddPage(Page{
+		URL:    "https://example.com/",
+		Status: "ok",
+		// Корень по ссылкам нормализован без "/", повторы и внешние ссылки рёбер не дают
+		Links: []string{"https://example.com/b", "https://example.com/a", "https://example.com/a", "https://other.com/x"},
+	})
+	rb.AddPage(Page{URL: "https://example.com/b", Status: "client_error", HTTPStatus: 404, Error: "404 Not Found"})
+	rb.AddPage(Page{
+		URL:    "https://example.com/a",
+		Status: "ok",
+		Links:  []string{"https://example.com", "https://example.com/a", "https://example.com/missing"},
+	})
+
+	out, err := rb.EncodeDOT()
+	if err != nil {
+		t.Fatalf("EncodeDOT failed: %v", err)
+	}
+
+	expected := `digraph crawl {
+  node [shape=box, style=filled, fillcolor=white];
+  "https://example.com/" [fillcolor=palegreen];
+  "https://example.com/a" [fillcolor=palegreen];
+  "https://example.com/b" [fillcolor=orange];
+  "https://example.com/" -> "https://example.com/a";
+  "https://example.com/" -> "https://example.com/b";
+  "https://example.com/a" -> "https://example.com/";
+}
+`
+	if string(out) != expected {
+		t.Errorf("unexpected DOT output:\n%s\nwant:\n%s", out, expected)
+	}
+}
+
+func TestEncodeDOTEscapesNodeIDs(t *testing.T) {
+	rb := newTestBuilder()
+	rb.AddPage(Page{URL: `https://example.com/q?name="x"\y`, Status: "error"})
+
+	out, err := rb.EncodeDOT()
+	if err != nil {
+		t.Fatalf("EncodeDOT failed: %v", err)
+	}
+	if !strings.Contains(string(out), `"https://example.com/q?name=\"x\"\\y" [fillcolor=tomato];`) {
+		t.Errorf("expected quotes and backslashes escaped, got %s", out)
+	}
+}
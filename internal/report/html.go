@@ -0,0 +1,37 @@
+package report
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+)
+
+//go:embed html.tmpl
+var htmlTemplateText string
+
+// htmlTemplate — самодостаточная HTML-страница отчёта со встроенным CSS.
+// html/template экранирует URL и тексты ошибок, поэтому данные страниц
+// сайта не могут внедрить разметку или скрипты в отчёт.
+var htmlTemplate = template.Must(template.New("report").Parse(htmlTemplateText))
+
+// RenderHTML отображает отчёт в виде HTML-страницы: сводка и таблица страниц
+// со статусами, битыми ссылками и ассетами
+func RenderHTML(r *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeHTML кодирует отчёт в HTML. Порядок страниц и сводка те же, что и в Encode.
+func (rb *Builder) EncodeHTML() ([]byte, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.sortPages()
+	rb.computeSummary()
+	rb.stamp()
+
+	return RenderHTML(rb.report)
+}
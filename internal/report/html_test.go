@@ -0,0 +1,87 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"code/internal/checker"
+	"code/internal/seo"
+)
+
+func TestEncodeHTML(t *testing.T) {
+	rb := newTestBuilder()
+	rb.AddPage(Page{
+		URL:        "https://example.com/ok",
+		HTTPStatus: 200,
+		Status:     "ok",
+		SEO:        &seo.SEO{Title: "Fine page"},
+		BrokenLinks: []checker.BrokenLink{
+			{URL: "https://example.com/missing", StatusCode: 404},
+		},
+		Assets: []checker.Asset{
+			{URL: "https://example.com/logo.png", Type: "image", StatusCode: 200, SizeBytes: 42},
+		},
+	})
+	rb.AddPage(Page{
+		URL:        "https://example.com/down",
+		HTTPStatus: 500,
+		Status:     "server_error",
+		Error:      "HTTP 500",
+	})
+
+	out, err := rb.EncodeHTML()
+	if err != nil {
+		t.Fatalf("EncodeHTML failed: %v", err)
+	}
+	html := string(out)
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<style>",
+		`<b>2</b>pages`,
+		`<span class="badge status-ok">ok</span>`,
+		`<span class="badge status-server_error">server_error</span>`,
+		"Fine page",
+		"<details><summary>1</summary>",
+		"https://example.com/missing",
+		"https://example.com/logo.png",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected HTML to contain %q", want)
+		}
+	}
+
+	// Страницы в том же порядке, что и в JSON
+	if strings.Index(html, "https://example.com/down") > strings.Index(html, "https://example.com/ok") {
+		t.Errorf("expected pages sorted by URL")
+	}
+}
+
+func TestEncodeHTMLEscapesContent(t *testing.T) {
+	rb := newTestBuilder()
+	rb.AddPage(Page{
+		URL:        `https://example.com/"><script>alert(1)</script>`,
+		HTTPStatus: 0,
+		Status:     "error",
+		Error:      `<img src=x onerror=alert(2)>`,
+		SEO:        &seo.SEO{Title: "<b>bold</b>"},
+		BrokenLinks: []checker.BrokenLink{
+			{URL: "javascript:alert(3)", Error: "<script>x</script>"},
+		},
+	})
+
+	out, err := rb.EncodeHTML()
+	if err != nil {
+		t.Fatalf("EncodeHTML failed: %v", err)
+	}
+	html := string(out)
+
+	for _, unsafe := range []string{"<script>alert(1)", "<img src=x", "<b>bold</b>", `href="javascript:`, "<script>x"} {
+		if strings.Contains(html, unsafe) {
+			t.Errorf("expected %q to be escaped", unsafe)
+		}
+	}
+	if !strings.Contains(html, "&lt;img src=x onerror=alert(2)&gt;") {
+		t.Errorf("expected error text to be shown escaped")
+	}
+}
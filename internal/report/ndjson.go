@@ -0,0 +1,52 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Значения поля type строк NDJSON
+const (
+	NDJSONTypePage    = "page"
+	NDJSONTypeSummary = "summary"
+)
+
+// NDJSONSink — PageSink, который пишет каждую страницу отдельной строкой
+// JSON с type = "page" сразу по мере обхода
+type NDJSONSink struct {
+	encoder *json.Encoder
+}
+
+func NewNDJSONSink(w io.Writer) *NDJSONSink {
+	return &NDJSONSink{encoder: json.NewEncoder(w)}
+}
+
+type ndjsonPage struct {
+	Type string `json:"type"`
+	Page
+}
+
+// AddPage записывает страницу строкой NDJSON
+func (s *NDJSONSink) AddPage(page Page) error {
+	return s.encoder.Encode(ndjsonPage{Type: NDJSONTypePage, Page: page})
+}
+
+type ndjsonSummary struct {
+	Type string `json:"type"`
+	*Report
+	// Pages скрывает Report.Pages: страницы уже выведены отдельными строками
+	Pages []Page `json:"pages,omitempty"`
+}
+
+// EncodeNDJSONSummary пишет завершающую строку NDJSON: отчёт без списка
+// страниц (сводка, stop_reason, stats...) с type = "summary"
+func (rb *Builder) EncodeNDJSONSummary(w io.Writer) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.sortPages()
+	rb.computeSummary()
+	rb.stamp()
+
+	return json.NewEncoder(w).Encode(ndjsonSummary{Type: NDJSONTypeSummary, Report: rb.report})
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	rb := newTestBuilder()
+	rb.SetSink(NewNDJSONSink(&buf))
+	rb.AddPage(Page{URL: "https://example.com/b", Status: "ok", HTTPStatus: 200})
+	rb.AddPage(Page{URL: "https://example.com/a", Depth: 1, Status: "client_error", HTTPStatus: 404})
+	rb.SetStopReason("max_requests")
+	if err := rb.EncodeNDJSONSummary(&buf); err != nil {
+		t.Fatalf("EncodeNDJSONSummary failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 2 page lines and a summary line, got %q", buf.String())
+	}
+
+	// Страницы — в порядке обхода, каждая строка — самостоятельный JSON
+	for i, url := range []string{"https://example.com/b", "https://example.com/a"} {
+		var page struct {
+			Type string `json:"type"`
+			Page
+		}
+		if err := json.Unmarshal([]byte(lines[i]), &page); err != nil {
+			t.Fatalf("line %d is not valid JSON: %v", i, err)
+		}
+		if page.Type != NDJSONTypePage || page.URL != url || page.SEOIssues == nil {
+			t.Errorf("line %d: unexpected page %+v", i, page)
+		}
+	}
+
+	var summary map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(lines[2]), &summary); err != nil {
+		t.Fatalf("summary line is not valid JSON: %v", err)
+	}
+	if string(summary["type"]) != `"summary"` || string(summary["stop_reason"]) != `"max_requests"` {
+		t.Errorf("unexpected summary line %s", lines[2])
+	}
+	if _, ok := summary["pages"]; ok {
+		t.Errorf("expected no pages in the summary line, got %s", lines[2])
+	}
+	var s Summary
+	if err := json.Unmarshal(summary["summary"], &s); err != nil || s.TotalPages != 2 || s.ErrorPages != 1 {
+		t.Errorf("expected summary over streamed pages, got %+v (%v)", s, err)
+	}
+}
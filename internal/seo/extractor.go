@@ -1,9 +1,13 @@
 package seo
 
 import (
+	"net/url"
 	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/net/html"
+
+	"code/internal/urlutil"
 )
 
 // SEO содержит базовые SEO параметры страницы
@@ -13,6 +17,39 @@ type SEO struct {
 	HasDescription bool   `json:"has_description"`
 	Description    string `json:"description"`
 	HasH1          bool   `json:"has_h1"`
+	// H1Count — число заголовков <h1> на странице
+	H1Count int `json:"h1_count"`
+	// TitleLength и DescriptionLength — длины title и description в символах
+	// (рунах), а не в байтах
+	TitleLength       int `json:"title_length"`
+	DescriptionLength int `json:"description_length"`
+	// OpenGraph — теги og:* и twitter:* из <meta property> / <meta name>
+	OpenGraph map[string]string `json:"open_graph"`
+	// Hreflang — альтернативные языковые версии страницы из
+	// <link rel="alternate" hreflang>: язык (в нижнем регистре) → абсолютный URL
+	Hreflang map[string]string `json:"hreflang"`
+	// WordCount — число слов видимого текста <body> (без <script> и <style>)
+	WordCount int `json:"word_count"`
+	// TextRatio — длина видимого текста, делённая на длину HTML
+	TextRatio float64 `json:"text_ratio"`
+	// MixedContentCount — число ассетов и ссылок страницы, открытой по https,
+	// ведущих на http. Заполняется краулером после разрешения URL.
+	MixedContentCount int `json:"mixed_content_count"`
+}
+
+// openGraphKeys — извлекаемые теги Open Graph и Twitter Card
+var openGraphKeys = map[string]bool{
+	"og:title":       true,
+	"og:description": true,
+	"og:image":       true,
+	"og:type":        true,
+	"twitter:card":   true,
+	"twitter:image":  true,
+}
+
+// Complete сообщает, что у страницы есть title, description и H1
+func (s *SEO) Complete() bool {
+	return s != nil && s.HasTitle && s.HasDescription && s.HasH1
 }
 
 // Extractor извлекает SEO данные из HTML
@@ -22,12 +59,16 @@ func NewExtractor() *Extractor {
 	return &Extractor{}
 }
 
-// Extract извлекает title, description и проверяет наличие H1
-func (e *Extractor) Extract(htmlContent string) *SEO {
+// Extract извлекает title, description и проверяет наличие H1.
+// pageURL — адрес страницы для разрешения относительных ссылок hreflang;
+// при nil разрешаются только абсолютные ссылки.
+func (e *Extractor) Extract(htmlContent string, pageURL *url.URL) *SEO {
 	seo := &SEO{
 		HasTitle:       false,
 		HasDescription: false,
 		HasH1:          false,
+		OpenGraph:      map[string]string{},
+		Hreflang:       map[string]string{},
 	}
 
 	doc, err := html.Parse(strings.NewReader(htmlContent))
@@ -38,6 +79,12 @@ func (e *Extractor) Extract(htmlContent string) *SEO {
 	e.extractTitle(doc, seo)
 	e.extractDescription(doc, seo)
 	e.extractH1(doc, seo)
+	e.extractOpenGraph(doc, seo)
+	e.extractHreflang(doc, seo, pageURL)
+	e.extractBodyText(doc, seo, len(htmlContent))
+
+	seo.TitleLength = utf8.RuneCountInString(seo.Title)
+	seo.DescriptionLength = utf8.RuneCountInString(seo.Description)
 
 	return seo
 }
@@ -70,22 +117,65 @@ func (e *Extractor) extractDescription(doc *html.Node, seo *SEO) {
 		}
 
 		if n.Type == html.ElementNode && n.Data == "meta" {
-			name := ""
-			content := ""
+			if name, content := metaNameContent(n); name == "description" {
+				seo.HasDescription = true
+				seo.Description = content
+				return
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+}
+
+func (e *Extractor) extractOpenGraph(doc *html.Node, seo *SEO) {
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			name, content := metaNameContent(n)
+			// Как и для description, побеждает первое значение тега
+			if _, seen := seo.OpenGraph[name]; openGraphKeys[name] && !seen {
+				seo.OpenGraph[name] = content
+			}
+		}
 
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+}
+
+func (e *Extractor) extractHreflang(doc *html.Node, seo *SEO, pageURL *url.URL) {
+	if pageURL == nil {
+		pageURL = &url.URL{}
+	}
+
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "link" && hasRel(n, "alternate") {
+			lang := ""
+			href := ""
 			for _, attr := range n.Attr {
-				if attr.Key == "name" && attr.Val == "description" {
-					name = "description"
-				}
-				if attr.Key == "content" {
-					content = attr.Val
+				switch attr.Key {
+				case "hreflang":
+					lang = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "href":
+					href = strings.TrimSpace(attr.Val)
 				}
 			}
 
-			if name == "description" {
-				seo.HasDescription = true
-				seo.Description = content
-				return
+			// Как и для Open Graph, побеждает первая ссылка языка
+			if _, seen := seo.Hreflang[lang]; lang != "" && !seen {
+				if resolved := urlutil.ResolveURL(href, pageURL); resolved != "" {
+					u, err := url.Parse(resolved)
+					if err == nil && u.IsAbs() {
+						seo.Hreflang[lang] = resolved
+					}
+				}
 			}
 		}
 
@@ -96,15 +186,67 @@ func (e *Extractor) extractDescription(doc *html.Node, seo *SEO) {
 	find(doc)
 }
 
+// hasRel сообщает, содержит ли атрибут rel узла значение value
+func hasRel(n *html.Node, value string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key != "rel" {
+			continue
+		}
+		for _, rel := range strings.Fields(attr.Val) {
+			if strings.EqualFold(rel, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// metaNameContent возвращает имя meta-тега (из name или property,
+// в нижнем регистре) и его content
+func metaNameContent(n *html.Node) (string, string) {
+	name := ""
+	content := ""
+
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "name", "property":
+			if name == "" {
+				name = strings.ToLower(strings.TrimSpace(attr.Val))
+			}
+		case "content":
+			content = attr.Val
+		}
+	}
+
+	return name, content
+}
+
 func (e *Extractor) extractH1(doc *html.Node, seo *SEO) {
 	var find func(*html.Node)
 	find = func(n *html.Node) {
-		if seo.HasH1 {
+		if n.Type == html.ElementNode && n.Data == "h1" {
+			seo.HasH1 = true
+			seo.H1Count++
 			return
 		}
 
-		if n.Type == html.ElementNode && n.Data == "h1" {
-			seo.HasH1 = true
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+}
+
+func (e *Extractor) extractBodyText(doc *html.Node, seo *SEO, htmlLength int) {
+	var body *html.Node
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if body != nil {
+			return
+		}
+
+		if n.Type == html.ElementNode && n.Data == "body" {
+			body = n
 			return
 		}
 
@@ -113,8 +255,20 @@ func (e *Extractor) extractH1(doc *html.Node, seo *SEO) {
 		}
 	}
 	find(doc)
+
+	text := extractTextContent(body)
+	if text == "" {
+		return
+	}
+
+	seo.WordCount = len(strings.Fields(text))
+	if htmlLength > 0 {
+		seo.TextRatio = float64(len(text)) / float64(htmlLength)
+	}
 }
 
+// extractTextContent собирает текст узла через пробел; содержимое
+// <script> и <style> не считается текстом
 func extractTextContent(n *html.Node) string {
 	if n == nil {
 		return ""
@@ -123,6 +277,9 @@ func extractTextContent(n *html.Node) string {
 	var parts []string
 	var extract func(*html.Node)
 	extract = func(node *html.Node) {
+		if node.Type == html.ElementNode && (node.Data == "script" || node.Data == "style") {
+			return
+		}
 		if node.Type == html.TextNode {
 			words := strings.Fields(node.Data)
 			parts = append(parts, words...)
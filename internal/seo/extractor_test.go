@@ -1,6 +1,9 @@
 package seo
 
 import (
+	"encoding/json"
+	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -8,7 +11,7 @@ func TestExtractor_NoElements(t *testing.T) {
 	extractor := NewExtractor()
 	html := `<html><body><p>No SEO elements</p></body></html>`
 
-	seo := extractor.Extract(html)
+	seo := extractor.Extract(html, nil)
 
 	if seo.HasTitle {
 		t.Error("HasTitle should be false when no title element")
@@ -43,7 +46,7 @@ func TestExtractor_EmptyElements(t *testing.T) {
         </html>
     `
 
-	seo := extractor.Extract(html)
+	seo := extractor.Extract(html, nil)
 
 	// Title
 	if !seo.HasTitle {
@@ -81,7 +84,7 @@ func TestExtractor_WithContent(t *testing.T) {
         </html>
     `
 
-	seo := extractor.Extract(html)
+	seo := extractor.Extract(html, nil)
 
 	// Title
 	if !seo.HasTitle {
@@ -122,7 +125,7 @@ func TestExtractor_WhitespaceHandling(t *testing.T) {
         </html>
     `
 
-	seo := extractor.Extract(html)
+	seo := extractor.Extract(html, nil)
 
 	// Title должен быть без пробелов по краям
 	if seo.Title != "Spaced Title" {
@@ -150,7 +153,7 @@ func TestExtractor_MultipleElements(t *testing.T) {
         </html>
     `
 
-	seo := extractor.Extract(html)
+	seo := extractor.Extract(html, nil)
 
 	// Должен быть взят первый элемент
 	if seo.Title != "First Title" {
@@ -173,7 +176,7 @@ func TestExtractor_NestedText(t *testing.T) {
         </html>
     `
 
-	seo := extractor.Extract(html)
+	seo := extractor.Extract(html, nil)
 
 	// H1 флаг должен быть установлен
 	if !seo.HasH1 {
@@ -185,10 +188,153 @@ func TestExtractor_InvalidHTML(t *testing.T) {
 	extractor := NewExtractor()
 	html := `<html><head><title>Valid Title`
 
-	seo := extractor.Extract(html)
+	seo := extractor.Extract(html, nil)
 
 	// Даже с невалидным HTML парсер должен извлечь что может
 	if !seo.HasTitle {
 		t.Error("Should handle invalid HTML gracefully")
 	}
 }
+
+func TestExtractor_OpenGraph(t *testing.T) {
+	extractor := NewExtractor()
+	html := `
+        <html>
+        <head>
+            <meta property="og:title" content="OG Title">
+            <meta name="og:description" content="OG Description">
+            <meta property="og:image" content="https://example.com/og.png">
+            <meta property="og:type" content="article">
+            <meta name="twitter:card" content="summary_large_image">
+            <meta property="twitter:image" content="https://example.com/tw.png">
+            <meta property="og:title" content="Second title">
+            <meta property="og:locale" content="en_US">
+            <meta property="description" content="Description via property">
+        </head>
+        <body></body>
+        </html>
+    `
+
+	seo := extractor.Extract(html, nil)
+
+	expected := map[string]string{
+		"og:title":       "OG Title",
+		"og:description": "OG Description",
+		"og:image":       "https://example.com/og.png",
+		"og:type":        "article",
+		"twitter:card":   "summary_large_image",
+		"twitter:image":  "https://example.com/tw.png",
+	}
+	if len(seo.OpenGraph) != len(expected) {
+		t.Errorf("Expected %d open graph tags, got %v", len(expected), seo.OpenGraph)
+	}
+	for key, value := range expected {
+		if seo.OpenGraph[key] != value {
+			t.Errorf("Expected %s = %q, got %q", key, value, seo.OpenGraph[key])
+		}
+	}
+
+	if !seo.HasDescription || seo.Description != "Description via property" {
+		t.Errorf("Expected description from property attribute, got %q", seo.Description)
+	}
+}
+
+func TestExtractor_OpenGraphEmpty(t *testing.T) {
+	seo := NewExtractor().Extract(`<html><head><title>T</title></head></html>`, nil)
+
+	data, err := json.Marshal(seo)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"open_graph":{}`) {
+		t.Errorf("Expected empty open_graph object, got %s", data)
+	}
+}
+
+func TestExtractor_WordCountExcludesScripts(t *testing.T) {
+	extractor := NewExtractor()
+	html := `<html><head><title>Ignored title words</title><style>body { color: red; }</style></head>` +
+		`<body><h1>Hello world</h1><script>var hidden = "not counted at all";</script>` +
+		`<p>Three more words</p><style>.x { display: none; }</style></body></html>`
+
+	seo := extractor.Extract(html, nil)
+
+	if seo.WordCount != 5 {
+		t.Errorf("Expected 5 words, got %d", seo.WordCount)
+	}
+
+	expectedRatio := float64(len("Hello world Three more words")) / float64(len(html))
+	if seo.TextRatio != expectedRatio {
+		t.Errorf("Expected text ratio %v, got %v", expectedRatio, seo.TextRatio)
+	}
+}
+
+func TestExtractor_H1Count(t *testing.T) {
+	extractor := NewExtractor()
+
+	seo := extractor.Extract(`<html><body><h1>One</h1><div><h1>Two</h1></div></body></html>`, nil)
+	if !seo.HasH1 || seo.H1Count != 2 {
+		t.Errorf("Expected HasH1 and 2 headings, got %v and %d", seo.HasH1, seo.H1Count)
+	}
+
+	seo = extractor.Extract(`<html><body><h2>Not a heading one</h2></body></html>`, nil)
+	if seo.HasH1 || seo.H1Count != 0 {
+		t.Errorf("Expected no h1, got %v and %d", seo.HasH1, seo.H1Count)
+	}
+}
+
+func TestExtractor_TitleAndDescriptionLength(t *testing.T) {
+	extractor := NewExtractor()
+	html := `<html><head><title>東京の天気予報</title>` +
+		`<meta name="description" content="Прогноз погоды"></head><body></body></html>`
+
+	seo := extractor.Extract(html, nil)
+
+	if seo.TitleLength != 7 {
+		t.Errorf("Expected title length 7 runes, got %d (%d bytes)", seo.TitleLength, len(seo.Title))
+	}
+	if seo.DescriptionLength != 14 {
+		t.Errorf("Expected description length 14 runes, got %d", seo.DescriptionLength)
+	}
+
+	seo = extractor.Extract(`<html><head></head><body></body></html>`, nil)
+	if seo.TitleLength != 0 || seo.DescriptionLength != 0 {
+		t.Errorf("Expected zero lengths without title and description, got %d and %d", seo.TitleLength, seo.DescriptionLength)
+	}
+}
+
+func TestExtractor_Hreflang(t *testing.T) {
+	extractor := NewExtractor()
+	pageURL, _ := url.Parse("https://example.com/en/page")
+	html := `<html><head>` +
+		`<link rel="alternate" hreflang="en" href="https://example.com/en/page">` +
+		`<link rel="alternate" hreflang="de-DE" href="/de/seite">` +
+		`<link rel="alternate" hreflang="fr" href="../fr/page">` +
+		`<link rel="alternate" hreflang="x-default" href="https://example.com/">` +
+		`<link rel="alternate" hreflang="en" href="https://example.com/duplicate">` +
+		`<link rel="alternate" type="application/rss+xml" href="/feed.xml">` +
+		`<link rel="canonical" hreflang="es" href="/es/pagina">` +
+		`</head><body></body></html>`
+
+	seo := extractor.Extract(html, pageURL)
+
+	expected := map[string]string{
+		"en":        "https://example.com/en/page",
+		"de-de":     "https://example.com/de/seite",
+		"fr":        "https://example.com/fr/page",
+		"x-default": "https://example.com",
+	}
+	if len(seo.Hreflang) != len(expected) {
+		t.Fatalf("Expected %d hreflang entries, got %v", len(expected), seo.Hreflang)
+	}
+	for lang, want := range expected {
+		if got := seo.Hreflang[lang]; got != want {
+			t.Errorf("Hreflang[%q] = %q, want %q", lang, got, want)
+		}
+	}
+
+	seo = extractor.Extract(`<html><head><link rel="alternate" hreflang="de" href="/de/"></head></html>`, nil)
+	if len(seo.Hreflang) != 0 {
+		t.Errorf("Expected relative hreflang to be skipped without page URL, got %v", seo.Hreflang)
+	}
+}
@@ -0,0 +1,162 @@
+package sitemap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+
+	"code/internal/httputil"
+)
+
+// maxIndexDepth ограничивает вложенность sitemap index, чтобы не зациклиться
+const maxIndexDepth = 3
+
+type urlSet struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+}
+
+type sitemapIndex struct {
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// Loader загружает sitemap.xml и sitemap index
+type Loader struct {
+	fetcher *httputil.Fetcher
+	// semaphore — общий для всех уровней вложенности лимит одновременных загрузок
+	semaphore chan struct{}
+}
+
+func NewLoader(fetcher *httputil.Fetcher, workers int) *Loader {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Loader{
+		fetcher:   fetcher,
+		semaphore: make(chan struct{}, workers),
+	}
+}
+
+// Load возвращает все <loc> из sitemap. Для sitemap index дочерние sitemap
+// загружаются параллельно (не более workers одновременно на всех уровнях
+// вложенности). Sitemap запрашивается через GET и в режиме DryRun. Недоступные или
+// битые дочерние sitemap пропускаются. Ошибка возвращается только если
+// не удалось загрузить сам sitemapURL.
+func (l *Loader) Load(ctx context.Context, sitemapURL string) ([]string, error) {
+	return l.load(ctx, sitemapURL, 0)
+}
+
+func (l *Loader) load(ctx context.Context, sitemapURL string, level int) ([]string, error) {
+	content, err := l.fetch(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	children, locs, err := parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sitemap %s: %w", sitemapURL, err)
+	}
+
+	if len(children) == 0 || level >= maxIndexDepth {
+		return locs, nil
+	}
+
+	return append(locs, l.loadChildren(ctx, children, level+1)...), nil
+}
+
+// loadChildren загружает дочерние sitemap. Слот семафора занимает только
+// сама загрузка (fetch): вложенный index, ожидающий своих детей, не держит
+// слот, поэтому общий лимит не приводит к взаимной блокировке.
+func (l *Loader) loadChildren(ctx context.Context, children []string, level int) []string {
+	results := make([][]string, len(children))
+	var wg sync.WaitGroup
+
+	for i, child := range children {
+		wg.Add(1)
+
+		go func(index int, childURL string) {
+			defer wg.Done()
+
+			// Битый дочерний sitemap пропускаем, остальные продолжают загружаться
+			if locs, err := l.load(ctx, childURL, level); err == nil {
+				results[index] = locs
+			}
+		}(i, child)
+	}
+
+	wg.Wait()
+
+	locs := []string{}
+	for _, result := range results {
+		locs = append(locs, result...)
+	}
+	return locs
+}
+
+func (l *Loader) fetch(ctx context.Context, sitemapURL string) (string, error) {
+	select {
+	case l.semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return "", fmt.Errorf("failed to fetch sitemap %s: %w", sitemapURL, ctx.Err())
+	}
+	defer func() { <-l.semaphore }()
+
+	result := l.fetcher.Get(ctx, sitemapURL)
+	if result.Error != nil {
+		return "", fmt.Errorf("failed to fetch sitemap %s: %w", sitemapURL, result.Error)
+	}
+	if result.StatusCode < 200 || result.StatusCode >= 300 {
+		return "", fmt.Errorf("failed to fetch sitemap %s: HTTP %d", sitemapURL, result.StatusCode)
+	}
+	return result.HTMLContent, nil
+}
+
+// parse возвращает ссылки на дочерние sitemap (для index) и URL страниц (для urlset)
+func parse(content string) ([]string, []string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "sitemapindex":
+			var index sitemapIndex
+			if err := decoder.DecodeElement(&index, &start); err != nil {
+				return nil, nil, err
+			}
+			children := []string{}
+			for _, s := range index.Sitemaps {
+				if loc := strings.TrimSpace(s.Loc); loc != "" {
+					children = append(children, loc)
+				}
+			}
+			return children, nil, nil
+		case "urlset":
+			var set urlSet
+			if err := decoder.DecodeElement(&set, &start); err != nil {
+				return nil, nil, err
+			}
+			locs := []string{}
+			for _, u := range set.URLs {
+				if loc := strings.TrimSpace(u.Loc); loc != "" {
+					locs = append(locs, loc)
+				}
+			}
+			return nil, locs, nil
+		default:
+			return nil, nil, fmt.Errorf("unexpected root element <%s>", start.Name.Local)
+		}
+	}
+}
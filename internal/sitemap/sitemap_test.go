@@ -0,0 +1,171 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"code/internal/httputil"
+)
+
+type mockClient struct {
+	doFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockClient) Do(req *http.Request) (*http.Response, error) {
+	return m.doFunc(req)
+}
+
+func xmlResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/xml"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestLoadSitemapIndexInParallel(t *testing.T) {
+	index := `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`
+	for i := 1; i <= 4; i++ {
+		index += fmt.Sprintf("<sitemap><loc>https://example.com/sitemap-%d.xml</loc></sitemap>", i)
+	}
+	index += `<sitemap><loc>https://example.com/broken.xml</loc></sitemap></sitemapindex>`
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+
+	client := &mockClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/sitemap.xml":
+			return xmlResponse(200, index), nil
+		case "/broken.xml":
+			return xmlResponse(500, ""), nil
+		}
+
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+
+		time.Sleep(30 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+
+		name := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/sitemap-"), ".xml")
+		return xmlResponse(200, fmt.Sprintf(`<urlset>
+			<url><loc>https://example.com/%s/a</loc></url>
+			<url><loc> https://example.com/%s/b </loc></url>
+		</urlset>`, name, name)), nil
+	}}
+
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: client, Timeout: time.Second}, nil)
+	locs, err := NewLoader(fetcher, 4).Load(context.Background(), "https://example.com/sitemap.xml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(locs) != 8 {
+		t.Fatalf("expected 8 URLs from 4 child sitemaps, got %d: %v", len(locs), locs)
+	}
+
+	sort.Strings(locs)
+	if locs[0] != "https://example.com/1/a" || locs[7] != "https://example.com/4/b" {
+		t.Errorf("unexpected URLs: %v", locs)
+	}
+
+	if maxActive < 2 {
+		t.Errorf("expected child sitemaps to be fetched concurrently, max active was %d", maxActive)
+	}
+	if maxActive > 4 {
+		t.Errorf("expected at most 4 concurrent fetches, got %d", maxActive)
+	}
+}
+
+func TestLoadSitemapRootFailure(t *testing.T) {
+	client := &mockClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		return xmlResponse(404, ""), nil
+	}}
+
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: client, Timeout: time.Second}, nil)
+	if _, err := NewLoader(fetcher, 2).Load(context.Background(), "https://example.com/sitemap.xml"); err == nil {
+		t.Fatal("expected error when the sitemap itself is unavailable")
+	}
+}
+
+func TestLoadNestedIndexSharesLimit(t *testing.T) {
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+
+	client := &mockClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		// /index.xml → /index-N.xml → /set-N-M.xml
+		var body strings.Builder
+		switch path := strings.TrimSuffix(req.URL.Path, ".xml"); {
+		case path == "/index":
+			body.WriteString("<sitemapindex>")
+			for i := 1; i <= 3; i++ {
+				fmt.Fprintf(&body, "<sitemap><loc>https://example.com/index-%d.xml</loc></sitemap>", i)
+			}
+			body.WriteString("</sitemapindex>")
+		case strings.HasPrefix(path, "/index-"):
+			body.WriteString("<sitemapindex>")
+			for i := 1; i <= 3; i++ {
+				fmt.Fprintf(&body, "<sitemap><loc>https://example.com/set-%s-%d.xml</loc></sitemap>", strings.TrimPrefix(path, "/index-"), i)
+			}
+			body.WriteString("</sitemapindex>")
+		default:
+			fmt.Fprintf(&body, "<urlset><url><loc>https://example.com%s</loc></url></urlset>", path)
+		}
+		return xmlResponse(200, body.String()), nil
+	}}
+
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: client, Timeout: time.Second}, nil)
+	locs, err := NewLoader(fetcher, 2).Load(context.Background(), "https://example.com/index.xml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(locs) != 9 {
+		t.Fatalf("expected 9 URLs from nested sitemaps, got %v", locs)
+	}
+	if maxActive > 2 {
+		t.Errorf("expected at most 2 concurrent fetches across nesting levels, got %d", maxActive)
+	}
+}
+
+func TestLoadSitemapDryRun(t *testing.T) {
+	var methods []string
+	client := &mockClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		methods = append(methods, req.Method)
+		return xmlResponse(200, `<urlset><url><loc>https://example.com/a</loc></url></urlset>`), nil
+	}}
+
+	fetcher := httputil.NewFetcher(httputil.FetcherConfig{Client: client, Timeout: time.Second, DryRun: true}, nil)
+	locs, err := NewLoader(fetcher, 1).Load(context.Background(), "https://example.com/sitemap.xml")
+	if err != nil {
+		t.Fatalf("expected sitemap to load in dry-run mode, got %v", err)
+	}
+	if len(locs) != 1 || len(methods) != 1 || methods[0] != http.MethodGet {
+		t.Errorf("expected a single GET for the sitemap, got %v (methods %v)", locs, methods)
+	}
+}
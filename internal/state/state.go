@@ -1,6 +1,8 @@
 package state
 
 import (
+	"container/heap"
+	"context"
 	"net/url"
 	"sync"
 
@@ -13,22 +15,170 @@ type URLWithDepth struct {
 	Depth int
 }
 
-// URLQueue — потокобезопасная очередь URL для обхода
+// Политики переполнения очереди с ограниченным размером (URLQueue.SetLimit)
+const (
+	// QueueFullDrop отбрасывает самые глубокие URL (при равной глубине —
+	// добавленные последними), чтобы очередь не превышала размер
+	QueueFullDrop = "drop"
+	// QueueFullBlock заставляет EnqueueWait ждать, пока в очереди
+	// не освободится место
+	QueueFullBlock = "block"
+)
+
+// URLQueue — потокобезопасная очередь URL для обхода с приоритетом по
+// глубине: Dequeue всегда выдаёт самый неглубокий URL, а URL одной глубины —
+// в порядке добавления. Так обход идёт строго в ширину, даже когда воркеры
+// добавляют ссылки вперемешку, и при досрочной остановке (MaxRequests)
+// обойдены самые близкие к корню страницы.
 type URLQueue struct {
-	items []URLWithDepth
-	mu    sync.Mutex
+	items depthHeap
+	// seq — порядковый номер следующего добавляемого URL
+	seq uint64
+	// limit и policy — размер очереди и политика переполнения (0 — без предела)
+	limit  int
+	policy string
+	// changed закрывается и заменяется новым при каждом изменении очереди
+	changed chan struct{}
+	mu      sync.Mutex
 }
 
 func NewURLQueue(rootURL string) *URLQueue {
 	return &URLQueue{
-		items: []URLWithDepth{{URL: rootURL, Depth: 0}},
+		items:   depthHeap{{URLWithDepth: URLWithDepth{URL: rootURL, Depth: 0}}},
+		seq:     1,
+		changed: make(chan struct{}),
+	}
+}
+
+// queueItem — URL в очереди с порядковым номером добавления
+type queueItem struct {
+	URLWithDepth
+	seq uint64
+}
+
+// depthHeap — куча (container/heap) URL: сначала меньшая глубина,
+// при равной — добавленный раньше
+type depthHeap []queueItem
+
+func (h depthHeap) Len() int { return len(h) }
+
+func (h depthHeap) Less(i, j int) bool {
+	if h[i].Depth != h[j].Depth {
+		return h[i].Depth < h[j].Depth
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h depthHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *depthHeap) Push(x any) { *h = append(*h, x.(queueItem)) }
+
+func (h *depthHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// push добавляет URL в кучу. Вызывается под q.mu.
+func (q *URLQueue) push(urls []URLWithDepth) {
+	for _, u := range urls {
+		heap.Push(&q.items, queueItem{URLWithDepth: u, seq: q.seq})
+		q.seq++
+	}
+}
+
+// SetLimit ограничивает очередь limit URL (0 — без предела) с политикой
+// переполнения QueueFullDrop или QueueFullBlock
+func (q *URLQueue) SetLimit(limit int, policy string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limit = limit
+	q.policy = policy
+}
+
+// Enqueue добавляет URL в очередь и возвращает отброшенные политикой
+// QueueFullDrop. При QueueFullBlock Enqueue не ждёт и размер не проверяет
+// (так ставятся стартовые URL, пока очередь никто не разбирает).
+func (q *URLQueue) Enqueue(urls []URLWithDepth) (dropped []URLWithDepth) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.push(urls)
+	if q.limit > 0 && q.policy == QueueFullDrop {
+		dropped = q.dropDeepest()
+	}
+	q.notify()
+	return dropped
+}
+
+// EnqueueWait добавляет URL в очередь. При QueueFullBlock, пока очередь
+// полна, ждёт освобождения места или отмены ctx; onBlock (если не nil)
+// вызывается один раз перед первым ожиданием. После отмены ctx оставшиеся
+// URL не добавляются и возвращается ctx.Err(). При других политиках
+// EnqueueWait не ждёт и работает как Enqueue.
+func (q *URLQueue) EnqueueWait(ctx context.Context, urls []URLWithDepth, onBlock func()) (dropped []URLWithDepth, err error) {
+	blocked := false
+	for {
+		q.mu.Lock()
+		if q.limit <= 0 || q.policy != QueueFullBlock {
+			q.mu.Unlock()
+			return q.Enqueue(urls), nil
+		}
+
+		n := min(max(q.limit-len(q.items), 0), len(urls))
+		if n > 0 {
+			q.push(urls[:n])
+			urls = urls[n:]
+			q.notify()
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		if len(urls) == 0 {
+			return nil, nil
+		}
+		if !blocked && onBlock != nil {
+			onBlock()
+		}
+		blocked = true
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// dropDeepest удаляет из очереди лишние URL, начиная с самых глубоких
+// (при равной глубине — с добавленных последними). Вызывается под q.mu.
+func (q *URLQueue) dropDeepest() []URLWithDepth {
+	var dropped []URLWithDepth
+	for len(q.items) > q.limit {
+		deepest := 0
+		for i := range q.items {
+			if q.items.Less(deepest, i) {
+				deepest = i
+			}
+		}
+		item := heap.Remove(&q.items, deepest).(queueItem)
+		dropped = append(dropped, item.URLWithDepth)
 	}
+	return dropped
+}
+
+// notify будит ждущих изменения очереди. Вызывается под q.mu.
+func (q *URLQueue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
 }
 
-func (q *URLQueue) Enqueue(urls []URLWithDepth) {
+// Changed возвращает канал, который закроется при следующем изменении очереди
+func (q *URLQueue) Changed() <-chan struct{} {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	q.items = append(q.items, urls...)
+	return q.changed
 }
 
 func (q *URLQueue) Dequeue() *URLWithDepth {
@@ -39,9 +189,16 @@ func (q *URLQueue) Dequeue() *URLWithDepth {
 		return nil
 	}
 
-	item := q.items[0]
-	q.items = q.items[1:]
-	return &item
+	item := heap.Pop(&q.items).(queueItem)
+	q.notify()
+	return &item.URLWithDepth
+}
+
+// Len возвращает число URL, ожидающих обхода
+func (q *URLQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
 }
 
 func (q *URLQueue) IsEmpty() bool {
@@ -68,12 +225,55 @@ func (v *VisitedSet) Add(url string) {
 	v.urls[url] = true
 }
 
+// AddNew добавляет URL и возвращает true, если его ещё не было в множестве
+func (v *VisitedSet) AddNew(url string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.urls[url] {
+		return false
+	}
+	v.urls[url] = true
+	return true
+}
+
+// Len возвращает число посещённых URL
+func (v *VisitedSet) Len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.urls)
+}
+
 func (v *VisitedSet) Contains(url string) bool {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 	return v.urls[url]
 }
 
+// ContentIndex — потокобезопасное соответствие хеша содержимого первому URL с ним
+type ContentIndex struct {
+	first map[string]string
+	mu    sync.Mutex
+}
+
+func NewContentIndex() *ContentIndex {
+	return &ContentIndex{
+		first: make(map[string]string),
+	}
+}
+
+// FirstOrAdd возвращает URL, первым добавленный с хешем hash. Если хеш
+// встречается впервые, запоминает url и возвращает seen = false.
+func (i *ContentIndex) FirstOrAdd(hash, url string) (first string, seen bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if first, ok := i.first[hash]; ok {
+		return first, true
+	}
+	i.first[hash] = url
+	return url, false
+}
+
 type CrawlState struct {
 	BaseURL     *url.URL
 	Queue       *URLQueue
@@ -0,0 +1,113 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func queueURLs(q *URLQueue) []string {
+	var urls []string
+	for item := q.Dequeue(); item != nil; item = q.Dequeue() {
+		urls = append(urls, item.URL)
+	}
+	return urls
+}
+
+func TestURLQueueDropDeepest(t *testing.T) {
+	q := NewURLQueue("/")
+	q.SetLimit(3, QueueFullDrop)
+
+	dropped := q.Enqueue([]URLWithDepth{
+		{URL: "/a", Depth: 2},
+		{URL: "/b", Depth: 1},
+		{URL: "/c", Depth: 2},
+		{URL: "/d", Depth: 1},
+	})
+
+	if len(dropped) != 2 || dropped[0].URL != "/c" || dropped[1].URL != "/a" {
+		t.Errorf("expected the deepest URLs /c and /a to be dropped, got %v", dropped)
+	}
+	if urls := queueURLs(q); len(urls) != 3 || urls[0] != "/" || urls[1] != "/b" || urls[2] != "/d" {
+		t.Errorf("expected queue [/ /b /d], got %v", urls)
+	}
+}
+
+func TestURLQueueBlock(t *testing.T) {
+	q := NewURLQueue("/")
+	q.SetLimit(2, QueueFullBlock)
+
+	blocked := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := q.EnqueueWait(context.Background(), []URLWithDepth{{URL: "/a"}, {URL: "/b"}}, func() { close(blocked) })
+		done <- err
+	}()
+
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("expected EnqueueWait to block on a full queue")
+	}
+	if q.Len() != 2 {
+		t.Errorf("expected the queue to be filled up to its limit, got %d", q.Len())
+	}
+
+	q.Dequeue()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected EnqueueWait to finish once space is freed")
+	}
+	if urls := queueURLs(q); len(urls) != 2 || urls[0] != "/a" || urls[1] != "/b" {
+		t.Errorf("expected queue [/a /b], got %v", urls)
+	}
+}
+
+func TestURLQueueBlockCanceled(t *testing.T) {
+	q := NewURLQueue("/")
+	q.SetLimit(1, QueueFullBlock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := q.EnqueueWait(ctx, []URLWithDepth{{URL: "/a"}}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context error, got %v", err)
+	}
+	if q.Len() != 1 {
+		t.Errorf("expected canceled URLs not to be enqueued, got %d items", q.Len())
+	}
+
+	// Enqueue не ждёт: стартовые URL ставятся сверх предела
+	q.Enqueue([]URLWithDepth{{URL: "/seed"}})
+	if q.Len() != 2 {
+		t.Errorf("expected Enqueue to ignore the limit under block policy, got %d items", q.Len())
+	}
+}
+
+func TestURLQueueOrdersByDepth(t *testing.T) {
+	q := NewURLQueue("/")
+	q.Enqueue([]URLWithDepth{{URL: "/a", Depth: 1}, {URL: "/b", Depth: 1}})
+	if item := q.Dequeue(); item == nil || item.URL != "/" {
+		t.Fatalf("expected root first, got %v", item)
+	}
+	q.Enqueue([]URLWithDepth{{URL: "/a/1", Depth: 2}})
+	q.Enqueue([]URLWithDepth{{URL: "/c", Depth: 1}, {URL: "/b/1", Depth: 2}})
+	q.Enqueue([]URLWithDepth{{URL: "/seed", Depth: 0}})
+
+	expected := []string{"/seed", "/a", "/b", "/c", "/a/1", "/b/1"}
+	urls := queueURLs(q)
+	if len(urls) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, urls)
+	}
+	for i := range expected {
+		if urls[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, urls)
+			break
+		}
+	}
+}
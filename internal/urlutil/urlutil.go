@@ -2,8 +2,14 @@ package urlutil
 
 import (
 	"fmt"
+	"net"
 	"net/url"
+	"path"
+	"sort"
 	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
 )
 
 func ParseAndValidateURL(urlStr string) (*url.URL, error) {
@@ -28,20 +34,230 @@ func NormalizeURLString(urlStr string) string {
 	return urlStr
 }
 
-// NormalizeURL убирает fragment и trailing slash для избежания дубликатов
+// NormalizeURL убирает fragment и trailing slash, приводит хост к нижнему
+// регистру и сортирует query-параметры для избежания дубликатов
 func NormalizeURL(u *url.URL) string {
 	normalized := *u
 	normalized.Fragment = ""
+	normalized.RawFragment = ""
+	normalized.Host = strings.ToLower(normalized.Host)
 
 	if normalized.Path == "/" {
 		normalized.Path = ""
+		normalized.RawPath = ""
+	}
+
+	if normalized.RawQuery != "" {
+		normalized.RawQuery = sortQuery(normalized.RawQuery)
 	}
 
 	return normalized.String()
 }
 
+// sortQuery сортирует параметры по имени, не перекодируя их.
+// Порядок значений одного параметра (a=1&a=2) сохраняется.
+func sortQuery(rawQuery string) string {
+	pairs := strings.Split(rawQuery, "&")
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return queryParamName(pairs[i]) < queryParamName(pairs[j])
+	})
+	return strings.Join(pairs, "&")
+}
+
+func queryParamName(pair string) string {
+	name, _, _ := strings.Cut(pair, "=")
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+// DefaultStripParams — типичные параметры отслеживания, не влияющие на содержимое страницы
+var DefaultStripParams = []string{"utm_*", "fbclid", "gclid"}
+
+// DefaultSessionIDParams — параметры, в которых серверы передают идентификатор сессии
+var DefaultSessionIDParams = []string{"jsessionid", "phpsessid", "sid"}
+
+// NormalizeOptions настраивает NormalizeURLWithOpts
+type NormalizeOptions struct {
+	// StripParams — имена query-параметров, удаляемых из URL (без учёта
+	// регистра). Поддерживаются шаблоны path.Match, например "utm_*".
+	StripParams []string
+	// CaseInsensitivePaths приводит путь к нижнему регистру (/About == /about)
+	CaseInsensitivePaths bool
+}
+
+// NormalizeURLWithOpts работает как NormalizeURL, но сначала удаляет
+// query-параметры из opts.StripParams и, если нужно, приводит путь к нижнему регистру
+func NormalizeURLWithOpts(u *url.URL, opts NormalizeOptions) string {
+	normalized := *u
+	if len(opts.StripParams) > 0 && normalized.RawQuery != "" {
+		normalized.RawQuery = stripParams(normalized.RawQuery, opts.StripParams)
+	}
+	if opts.CaseInsensitivePaths {
+		normalized.Path = strings.ToLower(normalized.Path)
+		normalized.RawPath = strings.ToLower(normalized.RawPath)
+	}
+	return NormalizeURL(&normalized)
+}
+
+func stripParams(rawQuery string, patterns []string) string {
+	kept := []string{}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if pair != "" && !matchesParam(name, patterns) {
+			kept = append(kept, pair)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// HasParam сообщает, что в query URL есть параметр, подходящий под один из шаблонов
+func HasParam(u *url.URL, patterns []string) bool {
+	for name := range u.Query() {
+		if matchesParam(name, patterns) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesParam сравнивает имя параметра с шаблонами без учёта регистра
+// (JSESSIONID и jsessionid — один параметр)
+func matchesParam(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if matched, err := path.Match(strings.ToLower(pattern), name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSelfLink сообщает, что ссылка ведёт на ту же страницу: совпадают схема,
+// хост и путь, а query отличается только параметрами из ignoreParams.
+// Fragment не учитывается.
+func IsSelfLink(linkURL, pageURL *url.URL, ignoreParams []string) bool {
+	if linkURL.Scheme != pageURL.Scheme || linkURL.Host != pageURL.Host {
+		return false
+	}
+	if trimSlash(linkURL.Path) != trimSlash(pageURL.Path) {
+		return false
+	}
+	return withoutParams(linkURL.Query(), ignoreParams).Encode() ==
+		withoutParams(pageURL.Query(), ignoreParams).Encode()
+}
+
+func trimSlash(path string) string {
+	if path == "/" {
+		return ""
+	}
+	return path
+}
+
+func withoutParams(query url.Values, params []string) url.Values {
+	for _, param := range params {
+		query.Del(param)
+	}
+	return query
+}
+
+// IsSameDomain сравнивает хосты URL после CanonicalHost: регистр, порт
+// по умолчанию и запись IDN (юникод или punycode) не влияют на результат
 func IsSameDomain(linkURL, baseURL *url.URL) bool {
-	return linkURL.Host == baseURL.Host
+	return CanonicalHost(linkURL) == CanonicalHost(baseURL)
+}
+
+// defaultPorts — порты, которые подразумеваются для схемы
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// CanonicalHost возвращает хост URL для сравнения: в нижнем регистре,
+// IDN в ASCII-форме (пример.рф -> xn--e1afmkfd.xn--p1ai) и без порта
+// по умолчанию для схемы. IPv6-адрес с портом остаётся в скобках.
+func CanonicalHost(u *url.URL) string {
+	host, port := canonicalHostPort(u)
+	if port != "" {
+		return net.JoinHostPort(host, port)
+	}
+	return host
+}
+
+// canonicalHostPort возвращает имя хоста в нижнем регистре и ASCII-форме
+// и порт (пустой, если он по умолчанию для схемы)
+func canonicalHostPort(u *url.URL) (string, string) {
+	host := u.Hostname()
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		host = ascii
+	}
+	host = strings.ToLower(host)
+
+	port := u.Port()
+	if port == defaultPorts[strings.ToLower(u.Scheme)] {
+		port = ""
+	}
+	return host, port
+}
+
+// IsSameSite сравнивает URL как IsSameDomain, а с includeSubdomains считает
+// одним сайтом и поддомены одного регистрируемого домена (eTLD+1 по списку
+// публичных суффиксов): www.example.co.uk и blog.example.co.uk — один сайт,
+// a.co.uk и b.co.uk — разные. Порты должны совпадать; IP-адреса сравниваются
+// только целиком.
+func IsSameSite(linkURL, baseURL *url.URL, includeSubdomains bool) bool {
+	if IsSameDomain(linkURL, baseURL) {
+		return true
+	}
+	if !includeSubdomains {
+		return false
+	}
+
+	linkHost, linkPort := canonicalHostPort(linkURL)
+	baseHost, basePort := canonicalHostPort(baseURL)
+	if linkPort != basePort || net.ParseIP(linkHost) != nil || net.ParseIP(baseHost) != nil {
+		return false
+	}
+
+	linkSite, err := publicsuffix.EffectiveTLDPlusOne(linkHost)
+	if err != nil {
+		return false
+	}
+	baseSite, err := publicsuffix.EffectiveTLDPlusOne(baseHost)
+	if err != nil {
+		return false
+	}
+	return linkSite == baseSite
+}
+
+// MatchesHost сообщает, что хост URL (без порта, без учёта регистра)
+// подходит под один из шаблонов path.Match, например "*.example.com".
+// Некорректные шаблоны ни с чем не совпадают.
+func MatchesHost(u *url.URL, patterns []string) bool {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if matched, err := path.Match(strings.ToLower(pattern), host); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMixedContent сообщает, что ресурс по адресу resource (уже разрешённому
+// ResolveURL) загружается по http со страницы, открытой по https
+func IsMixedContent(pageURL *url.URL, resource string) bool {
+	if pageURL == nil || !strings.EqualFold(pageURL.Scheme, "https") {
+		return false
+	}
+	u, err := url.Parse(resource)
+	return err == nil && u.Scheme == "http"
 }
 
 // ResolveURL преобразует относительный URL в абсолютный.
@@ -60,3 +60,216 @@ func TestResolveURL(t *testing.T) {
 		t.Fatalf("expected fragment to be stripped, got %s", abs)
 	}
 }
+
+func TestIsSelfLink(t *testing.T) {
+	page, _ := url.Parse("https://example.com/list?sort=asc")
+
+	tests := []struct {
+		link     string
+		expected bool
+	}{
+		{"https://example.com/list?sort=asc#top", true},
+		{"https://example.com/list?sort=asc&ref=nav", true},
+		{"https://example.com/list?sort=desc", false},
+		{"https://example.com/other?sort=asc", false},
+		{"https://other.com/list?sort=asc", false},
+	}
+
+	for _, tt := range tests {
+		link, _ := url.Parse(tt.link)
+		if got := IsSelfLink(link, page, []string{"ref"}); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.link, tt.expected, got)
+		}
+	}
+}
+
+func TestNormalizeURLWithOpts(t *testing.T) {
+	opts := NormalizeOptions{StripParams: DefaultStripParams}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"wildcard", "https://example.com/p?utm_source=x&utm_medium=email", "https://example.com/p"},
+		{"exact", "https://example.com/p?fbclid=abc&gclid=def", "https://example.com/p"},
+		{"exact does not match prefix", "https://example.com/p?fbclid_extra=1", "https://example.com/p?fbclid_extra=1"},
+		{"keeps other params", "https://example.com/p?z=1&utm_source=x&a=2&gclid=y&m=3", "https://example.com/p?a=2&m=3&z=1"},
+		{"no query", "https://example.com/#top", "https://example.com"},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.input)
+		if got := NormalizeURLWithOpts(u, opts); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+
+	u, _ := url.Parse("https://example.com/p?utm_source=x")
+	if got := NormalizeURLWithOpts(u, NormalizeOptions{}); got != "https://example.com/p?utm_source=x" {
+		t.Errorf("expected params to be kept without StripParams, got %s", got)
+	}
+}
+
+func TestNormalizeURLCollapsesEquivalentURLs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://EXAMPLE.com/About", "https://example.com/About"},
+		{"https://example.com/p?b=2&a=1", "https://example.com/p?a=1&b=2"},
+		{"https://example.com/p?tag=x&a=1&tag=y", "https://example.com/p?a=1&tag=x&tag=y"},
+		{"https://example.com/p?q=%20x&a=1", "https://example.com/p?a=1&q=%20x"},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.input)
+		if got := NormalizeURL(u); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	u, _ := url.Parse("https://example.com/About/Team")
+	if got := NormalizeURLWithOpts(u, NormalizeOptions{CaseInsensitivePaths: true}); got != "https://example.com/about/team" {
+		t.Errorf("expected lowercase path, got %s", got)
+	}
+}
+
+func TestHasParam(t *testing.T) {
+	u, _ := url.Parse("https://example.com/p?PHPSESSID=abc&page=2")
+	if !HasParam(u, DefaultSessionIDParams) {
+		t.Errorf("expected PHPSESSID to match case-insensitively")
+	}
+
+	u, _ = url.Parse("https://example.com/p?side=left")
+	if HasParam(u, DefaultSessionIDParams) {
+		t.Errorf("expected side not to match sid")
+	}
+}
+
+func TestIsSameDomain(t *testing.T) {
+	tests := []struct {
+		link string
+		base string
+		want bool
+	}{
+		{link: "https://example.com/a", base: "https://example.com", want: true},
+		{link: "https://EXAMPLE.com/a", base: "https://example.com", want: true},
+		{link: "http://example.com:80/a", base: "http://example.com", want: true},
+		{link: "https://example.com:443/a", base: "https://example.com", want: true},
+		{link: "http://example.com:443/a", base: "http://example.com", want: false},
+		{link: "https://example.com:8443/a", base: "https://example.com", want: false},
+		{link: "https://пример.рф/a", base: "https://xn--e1afmkfd.xn--p1ai", want: true},
+		{link: "https://xn--e1afmkfd.xn--p1ai/a", base: "https://ПРИМЕР.рф", want: true},
+		{link: "http://[::1]:80/a", base: "http://[::1]", want: true},
+		{link: "http://[::1]:8080/a", base: "http://[::1]", want: false},
+		{link: "https://other.com/a", base: "https://example.com", want: false},
+		{link: "https://sub.example.com/a", base: "https://example.com", want: false},
+	}
+
+	for _, tt := range tests {
+		link, _ := url.Parse(tt.link)
+		base, _ := url.Parse(tt.base)
+		if got := IsSameDomain(link, base); got != tt.want {
+			t.Errorf("IsSameDomain(%s, %s) = %v, want %v", tt.link, tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalHost(t *testing.T) {
+	tests := map[string]string{
+		"https://Example.COM:443/":  "example.com",
+		"http://example.com:8080/":  "example.com:8080",
+		"https://пример.рф/":        "xn--e1afmkfd.xn--p1ai",
+		"http://[2001:db8::1]:80/":  "2001:db8::1",
+		"http://[2001:db8::1]:81/":  "[2001:db8::1]:81",
+		"https://under_score.test/": "under_score.test",
+	}
+
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		if got := CanonicalHost(u); got != want {
+			t.Errorf("CanonicalHost(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestIsSameSite(t *testing.T) {
+	tests := []struct {
+		link       string
+		base       string
+		subdomains bool
+		want       bool
+	}{
+		{link: "https://blog.example.com/", base: "https://www.example.com", subdomains: false, want: false},
+		{link: "https://blog.example.com/", base: "https://www.example.com", subdomains: true, want: true},
+		{link: "https://example.com/", base: "https://shop.example.com", subdomains: true, want: true},
+		{link: "https://a.b.example.com/", base: "https://example.com", subdomains: true, want: true},
+		{link: "https://www.example.co.uk/", base: "https://blog.example.co.uk", subdomains: true, want: true},
+		{link: "https://other.co.uk/", base: "https://example.co.uk", subdomains: true, want: false},
+		{link: "https://example.org/", base: "https://example.com", subdomains: true, want: false},
+		{link: "https://blog.example.com:8080/", base: "https://www.example.com", subdomains: true, want: false},
+		{link: "https://blog.пример.рф/", base: "https://xn--e1afmkfd.xn--p1ai", subdomains: true, want: true},
+		{link: "http://10.0.0.1/", base: "http://10.0.0.2", subdomains: true, want: false},
+		{link: "https://www.example.com/", base: "https://www.example.com", subdomains: false, want: true},
+	}
+
+	for _, tt := range tests {
+		link, _ := url.Parse(tt.link)
+		base, _ := url.Parse(tt.base)
+		if got := IsSameSite(link, base, tt.subdomains); got != tt.want {
+			t.Errorf("IsSameSite(%s, %s, %v) = %v, want %v", tt.link, tt.base, tt.subdomains, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesHost(t *testing.T) {
+	patterns := []string{"*.example.com", "cdn.example.org"}
+	tests := []struct {
+		link string
+		want bool
+	}{
+		{link: "https://blog.example.com/", want: true},
+		{link: "https://a.b.example.com/", want: true},
+		{link: "https://BLOG.Example.COM/", want: true},
+		{link: "https://blog.example.com:8443/", want: true},
+		{link: "https://cdn.example.org/file", want: true},
+		{link: "https://example.com/", want: false},
+		{link: "https://static.example.org/", want: false},
+		{link: "https://example.com.evil.net/", want: false},
+		{link: "/relative", want: false},
+	}
+
+	for _, tt := range tests {
+		link, _ := url.Parse(tt.link)
+		if got := MatchesHost(link, patterns); got != tt.want {
+			t.Errorf("MatchesHost(%s) = %v, want %v", tt.link, got, tt.want)
+		}
+	}
+
+	link, _ := url.Parse("https://blog.example.com/")
+	if !MatchesHost(link, []string{"[", "blog.example.com"}) {
+		t.Error("expected an invalid pattern to be skipped, not to stop matching")
+	}
+}
+
+func TestIsMixedContent(t *testing.T) {
+	tests := []struct {
+		page     string
+		resource string
+		want     bool
+	}{
+		{page: "https://example.com/", resource: "http://cdn.example.com/a.png", want: true},
+		{page: "HTTPS://example.com/", resource: "http://example.com/a.png", want: true},
+		{page: "https://example.com/", resource: "https://cdn.example.com/a.png", want: false},
+		{page: "http://example.com/", resource: "http://cdn.example.com/a.png", want: false},
+		{page: "https://example.com/", resource: "", want: false},
+	}
+
+	for _, tt := range tests {
+		page, _ := url.Parse(tt.page)
+		if got := IsMixedContent(page, tt.resource); got != tt.want {
+			t.Errorf("IsMixedContent(%s, %q) = %v, want %v", tt.page, tt.resource, got, tt.want)
+		}
+	}
+}